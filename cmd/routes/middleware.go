@@ -3,31 +3,61 @@ package routes
 import (
 	"net/http"
 	"os"
+	"project/internal/item_detail/utils"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
 
+// DefaultActor es la identidad de la key de API_KEY.
+const DefaultActor = "default"
+
+// apiKeys arma el mapa clave → identidad a partir de API_KEY (identidad
+// "default") y de API_KEYS, una lista "nombre:clave" separada por comas.
+func apiKeys() map[string]string {
+	keys := map[string]string{}
+
+	if key := os.Getenv("API_KEY"); key != "" {
+		keys[key] = DefaultActor
+	}
+
+	for _, entry := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+
+		if ok && name != "" && key != "" {
+			keys[key] = name
+		}
+	}
+
+	return keys
+}
+
 func ApiKeyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	API_KEY := os.Getenv("API_KEY")
+	keys := apiKeys()
 
 	return func(c echo.Context) error {
 
 		key := c.Request().Header.Get("X-API-Key")
 
 		// Si el header no está → bloquear
-		if key == "" || API_KEY == "" {
+		if key == "" || len(keys) == 0 {
 			return c.JSON(http.StatusUnauthorized, map[string]string{
 				"error": "Missing X-API-Key header",
 			})
 		}
 
+		actor, ok := keys[key]
+
 		// Si no coincide → bloquear
-		if key != API_KEY {
+		if !ok {
 			return c.JSON(http.StatusUnauthorized, map[string]string{
 				"error": "API key inválida",
 			})
 		}
 
+		// Las escrituras de la request quedan firmadas con esta identidad.
+		utils.SetActor(c, actor)
+
 		return next(c)
 	}
 }
@@ -2,46 +2,56 @@ package routes
 
 import (
 	"net/http"
+	"os"
 	"project/internal/item_detail/repo/datasource/dal"
 	"project/internal/item_detail/repo/datasource/dao"
 	"project/internal/item_detail/rest"
 	"project/internal/item_detail/service"
+	"sync"
 
 	"github.com/labstack/echo-contrib/echoprometheus"
 	"github.com/labstack/echo/v4"
 )
 
-var (
-	productDal  = dal.NewProductDAL()
-	sellerDal   = dal.NewSellerDAL()
-	categoryDal = dal.NewCategoryDAL()
-	imageDal    = dal.NewImageDAL()
-)
-
-func crud[T any](group *echo.Group, dal dao.CrudDAO[T]) {
+func crud[T any](group *echo.Group, dal dao.CrudDAO[T]) *rest.CrudHandler[T] {
 	crudService := service.NewCrudService(dal)
 	crudHandler := rest.NewCrudHandler(crudService)
 
 	group.POST("", crudHandler.CreateEntity)
 	group.GET("", crudHandler.GetAllEntities)
+	group.GET("/trash", crudHandler.GetTrash)
 	group.GET("/:id", crudHandler.GetEntityByID)
 	group.PATCH("/:id", crudHandler.UpdateEntity)
 	group.DELETE("/:id", crudHandler.DeleteEntity)
+	group.POST("/:id/restore", crudHandler.RestoreEntity)
+	group.GET("/:id/revisions", crudHandler.GetRevisions)
+	group.GET("/:id/revisions/diff", crudHandler.GetRevisionDiff)
+	group.GET("/:id/revisions/:rev", crudHandler.GetRevision)
+	group.POST("/:id/revisions/:rev/revert", crudHandler.RevertRevision)
+
+	return crudHandler
 }
 
-func productRouter(r *echo.Group) {
+func productRouter(r *echo.Group, st *dal.Storage, integrity *service.Integrity) {
 	productGroup := r.Group("/products")
 
-	crud(productGroup, productDal)
+	facets := productFacets(integrity)
+
+	crud(productGroup, integrity.Products()).WithFacets(facets).WithExpander(productExpander(integrity))
 
 	productService := service.NewProductService(
-		productDal,
-		sellerDal,
-		categoryDal,
-		imageDal,
-	)
+		integrity.Products(),
+		st.Sellers,
+		st.Categories,
+		st.Images,
+	).WithTransactor(st)
 
 	productHandler := rest.NewProductHandler(productService)
+	searchHandler := rest.NewSearchHandler(service.NewProductSearch(integrity.Products(), integrity.Categories()), facets)
+
+	productGroup.POST("/with-images", productHandler.CreateWithImages)
+	productGroup.GET("/search", searchHandler.Search)
+	productGroup.GET("/suggest", searchHandler.Suggest)
 
 	productGroup.GET("/:id/category", productHandler.GetCategories)
 	productGroup.GET("/:id/seller", productHandler.GetSellers)
@@ -54,25 +64,64 @@ func productRouter(r *echo.Group) {
 	productGroup.PATCH("/:id/seller", productHandler.ChangeSellers)
 }
 
-func imageRouter(r *echo.Group) {
+func imageRouter(r *echo.Group, integrity *service.Integrity) {
 	imageGroup := r.Group("/images")
 
-	crud(imageGroup, imageDal)
+	crud(imageGroup, integrity.Images())
 }
 
-func categoryRouter(r *echo.Group) {
+func categoryRouter(r *echo.Group, integrity *service.Integrity) {
 	categoryGroup := r.Group("/categories")
 
-	crud(categoryGroup, categoryDal)
+	crud(categoryGroup, integrity.Categories())
+
+	categoryGroup.GET("/:id/products", productsOf(integrity, "categoryId", integrity.Categories()))
 }
 
-func sellerRouter(r *echo.Group) {
+func sellerRouter(r *echo.Group, integrity *service.Integrity) {
 	sellerGroup := r.Group("/sellers")
 
-	crud(sellerGroup, sellerDal)
+	crud(sellerGroup, integrity.Sellers())
+
+	sellerGroup.GET("/:id/products", productsOf(integrity, "sellerId", integrity.Sellers()))
+}
+
+// productsOf lista los productos que apuntan a una entidad de parent por
+// field, usando el índice del campo.
+func productsOf[T any](integrity *service.Integrity, field string, parent dao.CrudDAO[T]) echo.HandlerFunc {
+	handler := rest.NewCrudHandler(service.NewCrudService(integrity.Products())).
+		WithFacets(productFacets(integrity)).
+		WithExpander(productExpander(integrity))
+
+	return handler.ListBy(field, func(id string) error {
+		_, err := parent.GetByID(id)
+		return err
+	})
+}
+
+func productFacets(integrity *service.Integrity) *service.ProductFacets {
+	return service.NewProductFacets(integrity.Products(), integrity.Categories(), integrity.Sellers())
+}
+
+func productExpander(integrity *service.Integrity) *service.ProductExpander {
+	return service.NewProductExpander(integrity.Categories(), integrity.Sellers(), integrity.Images())
 }
 
-func Routes(r *echo.Echo) *echo.Echo {
+// deletePolicy lee DELETE_POLICY (restrict, cascade o nullify). Un valor
+// desconocido usa restrict, que no pierde datos.
+func deletePolicy() service.DeletePolicy {
+	policy, _ := service.ParseDeletePolicy(os.Getenv("DELETE_POLICY"))
+
+	return policy
+}
+
+// metricsMiddleware se crea una sola vez: registra sus métricas en el registry
+// global de Prometheus y hacerlo dos veces (por ejemplo, un Routes por test) hace panic.
+var metricsMiddleware = sync.OnceValue(func() echo.MiddlewareFunc {
+	return echoprometheus.NewMiddleware("item_detail")
+})
+
+func Routes(r *echo.Echo, st *dal.Storage) *echo.Echo {
 	r.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "UP")
 	})
@@ -80,16 +129,20 @@ func Routes(r *echo.Echo) *echo.Echo {
 	api := r.Group("/api/v1")
 
 	// Metricas de prometheus
-	r.Use(echoprometheus.NewMiddleware("item_detail"))
+	r.Use(metricsMiddleware())
 	r.GET("/metrics", echoprometheus.NewHandler())
 
 	api.Use(ApiKeyMiddleware)
 
+	// Los productos no pueden apuntar a categorías, vendedores o imágenes que
+	// no existen.
+	integrity := service.NewIntegrity(st.Products, st.Sellers, st.Categories, st.Images, deletePolicy()).WithTransactor(st)
+
 	// Routes
-	productRouter(api)
-	categoryRouter(api)
-	sellerRouter(api)
-	imageRouter(api)
+	productRouter(api, st, integrity)
+	categoryRouter(api, integrity)
+	sellerRouter(api, integrity)
+	imageRouter(api, integrity)
 
 	return r
 }
@@ -1,16 +1,39 @@
 package dal
 
 import (
+	"database/sql"
+	"path/filepath"
 	"project/internal/item_detail/repo/datasource/dao"
 	models "project/pkg"
 )
 
 type categoryDAL struct {
-	*CrudDAL[models.Category]
+	dao.CrudDAO[models.Category]
 }
 
+// NewCategoryDAL usa Category.json en el directorio de trabajo.
 func NewCategoryDAL() dao.CategoryDAO {
+	return NewJSONCategoryDAL(".")
+}
+
+// NewJSONCategoryDAL usa Category.json dentro de dir.
+func NewJSONCategoryDAL(dir string) dao.CategoryDAO {
 	return &categoryDAL{
-		CrudDAL: &CrudDAL[models.Category]{Filename: "Category"},
+		CrudDAO: &CrudDAL[models.Category]{Filename: filepath.Join(dir, "Category")},
+	}
+}
+
+// NewMemoryCategoryDAL guarda las categorías en memoria.
+func NewMemoryCategoryDAL() dao.CategoryDAO {
+	return &categoryDAL{CrudDAO: NewMemoryDAL[models.Category]()}
+}
+
+// NewSQLiteCategoryDAL usa la tabla categories de db.
+func NewSQLiteCategoryDAL(db *sql.DB) (dao.CategoryDAO, error) {
+	crud, err := NewSQLiteDAL[models.Category](db, "categories")
+	if err != nil {
+		return nil, err
 	}
+
+	return &categoryDAL{CrudDAO: crud}, nil
 }
@@ -0,0 +1,696 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// recordStore es la parte específica de cada backend: cómo se leen y se
+// escriben los registros de una colección. La semántica CRUD (Init, filtro
+// "q", paginación, merge de updates) vive una sola vez en collection, así
+// todos los backends se comportan igual.
+type recordStore[T any] interface {
+	// view ejecuta fn sobre una vista de solo lectura de la colección.
+	view(fn func(tx recordTx[T]) error) error
+	// update ejecuta fn y persiste los cambios sólo si fn no devuelve error.
+	update(fn func(tx recordTx[T]) error) error
+	// lastChange identifica la última escritura confirmada.
+	lastChange() (dao.Change, error)
+	// history devuelve, en orden de escritura, las versiones archivadas de id.
+	history(id string) ([][]byte, error)
+}
+
+// recordTx son las operaciones que un backend expone dentro de view/update.
+// El orden de scan es el orden de inserción. Los punteros que entrega scan
+// pueden ser memoria compartida del backend: son de solo lectura, y collection
+// clona los que devuelve al caller.
+type recordTx[T any] interface {
+	get(id string) (*T, error) // nil, nil si no existe
+	scan(fn func(item *T) bool) error
+	insert(item *T) error
+	replace(id string, item *T) error
+	remove(id string) (bool, error)
+	// archive guarda item (la versión que se va a reemplazar) en el historial
+	// cuando se confirma la escritura.
+	archive(item *T) error
+	// forget borra el historial de id (una entidad purgada) cuando se
+	// confirma la escritura.
+	forget(id string) error
+}
+
+// searcher es opcional: los backends que pueden resolver los filtros de
+// ListQuery y la paginación por su cuenta (por ejemplo con índices) lo
+// implementan. query llega con Limit/Offset ya normalizados.
+type searcher[T any] interface {
+	search(query dao.ListQuery, filter listFilter[T]) (dao.Page[T], error)
+}
+
+type Initializable interface {
+	Init()
+}
+
+// collection implementa dao.CrudDAO[T] sobre cualquier recordStore. Todos los
+// errores que devuelve pertenecen a alguna categoría de dao (ErrNotFound,
+// ErrConflict, ...): lo que el backend no clasifica es ErrStorageUnavailable.
+type collection[T any] struct {
+	store recordStore[T]
+	// actor es quien firma las escrituras (ver As).
+	actor string
+}
+
+// As devuelve una vista de la misma colección que registra actor como autor
+// de las escrituras.
+func (c *collection[T]) As(actor string) dao.CrudDAO[T] {
+	return &collection[T]{store: c.store, actor: actor}
+}
+
+// Create agrega una nueva entidad a la colección. Un ID que ya existe es
+// ErrConflict.
+func (c *collection[T]) Create(entity *T) (*T, error) {
+	initEntity(entity)
+
+	err := c.store.update(func(tx recordTx[T]) error {
+		if id := entityID(entity); id != "" {
+			existing, err := tx.get(id)
+			if err != nil {
+				return fmt.Errorf("error reading entity: %w", err)
+			}
+
+			if existing != nil && isDeleted(existing) {
+				return dao.Errorf(dao.ErrConflict, "Entity with ID %s is deleted; restore it instead", id)
+			}
+
+			if existing != nil {
+				return dao.Errorf(dao.ErrConflict, "Entity with ID %s already exists", id)
+			}
+		}
+
+		// Lo que mande el cliente en los datos de control se descarta.
+		if m := meta(entity); m != nil {
+			*m = models.Metadata{}
+			c.touch(m)
+			m.CreatedAt, m.CreatedBy = m.UpdatedAt, m.UpdatedBy
+		}
+
+		if err := checkUnique(tx, entity, nil); err != nil {
+			return err
+		}
+
+		return tx.insert(entity)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Can't save the entity with error: %w", unavailable(err))
+	}
+
+	return entity, nil
+}
+
+// GetByID busca una entidad con el campo `ID` igual al solicitado.
+func (c *collection[T]) GetByID(uid string) (*T, error) {
+	return c.Find(uid, dao.ExcludeDeleted)
+}
+
+// Find busca una entidad por ID; deleted decide si se ven las borradas.
+func (c *collection[T]) Find(uid string, deleted dao.DeletedFilter) (*T, error) {
+	var found *T
+
+	err := c.store.view(func(tx recordTx[T]) error {
+		item, err := tx.get(uid)
+		found = item
+		return err
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading entity: %w", unavailable(err))
+	}
+
+	if found == nil || !visible(found, deleted) {
+		return nil, dao.Errorf(dao.ErrNotFound, "Can't find entity with UID %s", uid)
+	}
+
+	initEntity(found)
+
+	return found, nil
+}
+
+// GetAll devuelve las entidades filtradas por "q" (sobre `Name`) y paginadas.
+func (c *collection[T]) GetAll(q string, limit int, offset int) ([]*T, error) {
+	return c.List(dao.ListQuery{Q: q, Limit: limit, Offset: offset})
+}
+
+// List devuelve las entidades que cumplen los filtros de query, paginadas.
+func (c *collection[T]) List(query dao.ListQuery) ([]*T, error) {
+	page, err := c.Page(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+// Page devuelve la página de query con el total y los cursores vecinos.
+func (c *collection[T]) Page(query dao.ListQuery) (dao.Page[T], error) {
+	// Default pagination values
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = 10
+	}
+
+	var page dao.Page[T]
+
+	filter, err := compileListFilter[T](query)
+	if err != nil {
+		return page, err
+	}
+
+	err = c.store.view(func(tx recordTx[T]) error {
+		if s, ok := tx.(searcher[T]); ok {
+			var err error
+			page, err = s.search(query, filter)
+			return err
+		}
+
+		filtered := []ranked[T]{}
+
+		keep := func(r ranked[T]) {
+			item := r.item
+			if visible(item, query.Deleted) && matchesQuery(item, query.Q) &&
+				updatedSince(item, query.UpdatedSince) && matchesEquals(item, query.Equals) &&
+				filter.match(item) {
+				filtered = append(filtered, r)
+			}
+		}
+
+		candidates, indexed, err := lookupEquals(tx, query.Equals)
+		if indexed {
+			for _, r := range candidates {
+				keep(r)
+			}
+		} else if err == nil {
+			// scan recorre en orden de alta: la posición es la cuenta.
+			var seq int64
+			err = tx.scan(func(item *T) bool {
+				keep(ranked[T]{item: item, seq: seq})
+				seq++
+				return true
+			})
+		}
+
+		filter.apply(filtered)
+		page = filter.page(filtered, query)
+
+		return err
+	})
+
+	if err != nil {
+		return dao.Page[T]{}, fmt.Errorf("error reading entities: %w", unavailable(err))
+	}
+
+	for i, item := range page.Items {
+		if page.Items[i], err = cloneEntity(item); err != nil {
+			return dao.Page[T]{}, fmt.Errorf("error reading entities: %w", err)
+		}
+		initEntity(page.Items[i])
+	}
+
+	return page, nil
+}
+
+// Update reemplaza los campos no vacíos de una entidad existente (por ID).
+func (c *collection[T]) Update(entity *T, id string) (*T, error) {
+	var updated *T
+
+	err := c.store.update(func(tx recordTx[T]) error {
+		existing, err := tx.get(id)
+		if err != nil {
+			return fmt.Errorf("error reading entity: %w", err)
+		}
+
+		if existing == nil || isDeleted(existing) {
+			return dao.Errorf(dao.ErrNotFound, "Can't find entity with ID %v", id)
+		}
+
+		if err := checkVersion(entity, existing); err != nil {
+			return err
+		}
+
+		if err := archive(tx, existing); err != nil {
+			return err
+		}
+
+		before := *existing
+		merged, wasUpdated := updateData(entity, existing)
+
+		if !wasUpdated {
+			return dao.Errorf(dao.ErrInvalidUpdate, "Update failed: invalid parameters or no parameters provided.")
+		}
+
+		if err := checkUnique(tx, merged, &before); err != nil {
+			return err
+		}
+
+		if m := meta(merged); m != nil {
+			c.touch(m)
+		}
+
+		if err := tx.replace(id, merged); err != nil {
+			return fmt.Errorf("error writing entity: %w", err)
+		}
+
+		updated = merged
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	initEntity(updated)
+
+	return updated, nil
+}
+
+// Modify lee la entidad, le aplica mutate y la guarda dentro de la misma
+// escritura, así nadie puede modificarla entre la lectura y el guardado. Si
+// mutate rechaza el cambio con un error sin categoría, se toma como
+// ErrInvalidUpdate.
+func (c *collection[T]) Modify(id string, mutate func(entity *T) error) (*T, error) {
+	var modified *T
+
+	err := c.store.update(func(tx recordTx[T]) error {
+		existing, err := tx.get(id)
+		if err != nil {
+			return fmt.Errorf("error reading entity: %w", err)
+		}
+
+		if existing == nil || isDeleted(existing) {
+			return dao.Errorf(dao.ErrNotFound, "Can't find entity with ID %v", id)
+		}
+
+		var saved models.Metadata
+		if m := meta(existing); m != nil {
+			saved = *m
+		}
+
+		original, err := json.Marshal(existing)
+		if err != nil {
+			return fmt.Errorf("error encoding entity: %w", err)
+		}
+
+		before := *existing
+
+		if err := mutate(existing); err != nil {
+			if dao.Classified(err) {
+				return err
+			}
+			return dao.Errorf(dao.ErrInvalidUpdate, "%w", err)
+		}
+
+		// mutate no decide los datos de control: se parte de los guardados.
+		if m := meta(existing); m != nil {
+			*m = saved
+		}
+
+		// Si mutate no cambió nada no se escribe: ni versión nueva ni
+		// revisión, y el ETag sigue valiendo.
+		if mutated, err := json.Marshal(existing); err == nil && bytes.Equal(mutated, original) {
+			modified = existing
+			return nil
+		}
+
+		if m := meta(existing); m != nil {
+			c.touch(m)
+		}
+
+		if err := checkUnique(tx, existing, &before); err != nil {
+			return err
+		}
+
+		previous, err := decodeRecord[T](original)
+		if err != nil {
+			return err
+		}
+
+		if err := archive(tx, previous); err != nil {
+			return err
+		}
+
+		if err := tx.replace(id, existing); err != nil {
+			return fmt.Errorf("error writing entity: %w", err)
+		}
+
+		modified = existing
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	initEntity(modified)
+
+	return modified, nil
+}
+
+// LastChange identifica la última escritura sobre la colección.
+func (c *collection[T]) LastChange() (dao.Change, error) {
+	change, err := c.store.lastChange()
+	if err != nil {
+		return dao.Change{}, fmt.Errorf("error reading collection state: %w", unavailable(err))
+	}
+
+	return change, nil
+}
+
+// Delete manda la entidad a la papelera (DeletedAt/DeletedBy). Las entidades
+// sin datos de control se eliminan directamente. Una entidad ya borrada es
+// ErrNotFound.
+func (c *collection[T]) Delete(id string) (bool, error) {
+	err := c.store.update(func(tx recordTx[T]) error {
+		existing, err := tx.get(id)
+		if err != nil {
+			return fmt.Errorf("error reading entity: %w", err)
+		}
+
+		if existing == nil || isDeleted(existing) {
+			return dao.Errorf(dao.ErrNotFound, "Can't find entity with ID %v", id)
+		}
+
+		m := meta(existing)
+
+		if m == nil {
+			if _, err := tx.remove(id); err != nil {
+				return fmt.Errorf("error writing entity: %w", err)
+			}
+
+			if err := tx.forget(id); err != nil {
+				return fmt.Errorf("error writing history: %w", err)
+			}
+
+			return nil
+		}
+
+		if err := archive(tx, existing); err != nil {
+			return err
+		}
+
+		c.touch(m)
+		deletedAt := m.UpdatedAt
+		m.DeletedAt, m.DeletedBy = &deletedAt, c.actor
+
+		if err := tx.replace(id, existing); err != nil {
+			return fmt.Errorf("error writing entity: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return false, unavailable(err)
+	}
+
+	return true, nil
+}
+
+// Restore saca la entidad de la papelera. Si no está borrada es ErrConflict.
+func (c *collection[T]) Restore(id string) (*T, error) {
+	var restored *T
+
+	err := c.store.update(func(tx recordTx[T]) error {
+		existing, err := tx.get(id)
+		if err != nil {
+			return fmt.Errorf("error reading entity: %w", err)
+		}
+
+		if existing == nil {
+			return dao.Errorf(dao.ErrNotFound, "Can't find entity with ID %v", id)
+		}
+
+		if !isDeleted(existing) {
+			return dao.Errorf(dao.ErrConflict, "Entity with ID %v is not deleted", id)
+		}
+
+		if err := archive(tx, existing); err != nil {
+			return err
+		}
+
+		m := meta(existing)
+		m.DeletedAt, m.DeletedBy = nil, ""
+		c.touch(m)
+
+		// Mientras estuvo en la papelera otra entidad pudo tomar sus valores.
+		if err := checkUnique(tx, existing, nil); err != nil {
+			return err
+		}
+
+		if err := tx.replace(id, existing); err != nil {
+			return fmt.Errorf("error writing entity: %w", err)
+		}
+
+		restored = existing
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	initEntity(restored)
+
+	return restored, nil
+}
+
+// Purge elimina definitivamente las entidades borradas antes de before. Si
+// no hay ninguna no escribe nada (la colección no cambia).
+func (c *collection[T]) Purge(before time.Time) (int, error) {
+	expired := func(tx recordTx[T]) ([]string, error) {
+		ids := []string{}
+
+		err := tx.scan(func(item *T) bool {
+			if m := meta(item); m != nil && m.Deleted() && m.DeletedAt.Before(before) {
+				ids = append(ids, entityID(item))
+			}
+			return true
+		})
+
+		return ids, err
+	}
+
+	var pending []string
+
+	err := c.store.view(func(tx recordTx[T]) error {
+		ids, err := expired(tx)
+		pending = ids
+		return err
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("error reading entities: %w", unavailable(err))
+	}
+
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	purged := 0
+
+	err = c.store.update(func(tx recordTx[T]) error {
+		// Se vuelve a mirar dentro de la escritura: alguna pudo restaurarse.
+		ids, err := expired(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if _, err := tx.remove(id); err != nil {
+				return fmt.Errorf("error writing entity: %w", err)
+			}
+
+			if err := tx.forget(id); err != nil {
+				return fmt.Errorf("error writing history: %w", err)
+			}
+		}
+
+		purged = len(ids)
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, unavailable(err)
+	}
+
+	return purged, nil
+}
+
+// unavailable clasifica como ErrStorageUnavailable los errores que el backend
+// no categorizó (archivo ilegible, disco lleno, base caída, ...).
+func unavailable(err error) error {
+	if err == nil || dao.Classified(err) {
+		return err
+	}
+
+	return dao.Errorf(dao.ErrStorageUnavailable, "%w", err)
+}
+
+// checkVersion compara la versión que trae el update (si trae una) con la guardada.
+func checkVersion[T any](entity *T, existing *T) error {
+	expected := meta(entity)
+	if expected == nil || expected.Version == 0 {
+		return nil
+	}
+
+	if current := meta(existing); expected.Version != current.Version {
+		return dao.Errorf(dao.ErrVersionMismatch, "Version mismatch: expected %d, current is %d", expected.Version, current.Version)
+	}
+
+	return nil
+}
+
+// meta devuelve los datos de control de la entidad, o nil si no los lleva.
+func meta[T any](item *T) *models.Metadata {
+	if tracked, ok := any(item).(dao.Tracked); ok {
+		return tracked.Meta()
+	}
+
+	return nil
+}
+
+// touch registra una escritura: sube la versión y firma UpdatedAt/UpdatedBy.
+func (c *collection[T]) touch(m *models.Metadata) {
+	m.Version++
+	m.UpdatedAt = time.Now().UTC()
+	m.UpdatedBy = c.actor
+}
+
+// initEntity llama a Init() si la entidad lo implementa.
+func initEntity[T any](item *T) {
+	if v, ok := any(item).(Initializable); ok {
+		v.Init()
+	}
+}
+
+// cloneEntity devuelve una copia profunda de la entidad, pasando por JSON
+// igual que si se hubiera leído del storage.
+func cloneEntity[T any](item *T) (*T, error) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding entity: %w", err)
+	}
+
+	return decodeRecord[T](raw)
+}
+
+// entityID devuelve el valor del campo `ID` (o "" si la entidad no lo tiene).
+func entityID[T any](item *T) string {
+	idField := reflect.ValueOf(item).Elem().FieldByName("ID")
+
+	if idField.IsValid() && idField.Kind() == reflect.String {
+		return idField.String()
+	}
+
+	return ""
+}
+
+// entityName devuelve el valor del campo `Name`; ok es false si no existe.
+func entityName[T any](item *T) (string, bool) {
+	nameField := reflect.ValueOf(item).Elem().FieldByName("Name")
+
+	if !nameField.IsValid() || nameField.Kind() != reflect.String {
+		return "", false
+	}
+
+	return nameField.String(), true
+}
+
+// matchesQuery aplica el filtro por substring "q" sobre `Name`, sin distinguir mayúsculas.
+func matchesQuery[T any](item *T, q string) bool {
+	if q == "" {
+		return true
+	}
+
+	name, ok := entityName(item)
+	if !ok {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(name), strings.ToLower(q))
+}
+
+// isDeleted indica si la entidad está en la papelera.
+func isDeleted[T any](item *T) bool {
+	m := meta(item)
+
+	return m != nil && m.Deleted()
+}
+
+// visible aplica el DeletedFilter a una entidad.
+func visible[T any](item *T, deleted dao.DeletedFilter) bool {
+	switch deleted {
+	case dao.IncludeDeleted:
+		return true
+	case dao.OnlyDeleted:
+		return isDeleted(item)
+	default:
+		return !isDeleted(item)
+	}
+}
+
+// updatedSince aplica el filtro UpdatedSince (inclusivo). Con el filtro
+// activo, las entidades sin datos de control no pasan.
+func updatedSince[T any](item *T, since time.Time) bool {
+	if since.IsZero() {
+		return true
+	}
+
+	m := meta(item)
+
+	return m != nil && !m.UpdatedAt.Before(since)
+}
+
+// protectedFields son los campos que un update nunca pisa: el ID y los datos
+// de control que mantiene el storage.
+var protectedFields = map[string]bool{
+	"ID":       true,
+	"Metadata": true,
+}
+
+// updateData reemplaza los campos no vacíos o no cero del origen en el destino.
+func updateData[T any](entity *T, existingEntity *T) (*T, bool) {
+	valSrc := reflect.ValueOf(entity).Elem()
+	valDst := reflect.ValueOf(existingEntity).Elem()
+
+	wasUpdated := false
+
+	for i := 0; i < valSrc.NumField(); i++ {
+
+		fieldName := valSrc.Type().Field(i).Name
+
+		if protectedFields[fieldName] {
+			continue
+		}
+
+		srcField := valSrc.Field(i)
+		dstField := valDst.Field(i)
+
+		if srcField.IsValid() && !srcField.IsZero() && dstField.CanSet() {
+			dstField.Set(srcField)
+			wasUpdated = true
+		}
+	}
+
+	return existingEntity, wasUpdated
+}
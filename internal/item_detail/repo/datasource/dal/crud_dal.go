@@ -1,222 +1,438 @@
 package dal
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"project/internal/item_detail/repo/datasource/dao"
 	"project/internal/item_detail/utils"
-	"reflect"
-	"strings"
+	"time"
 )
 
 // CrudDAL es una implementación genérica CRUD basada en archivos JSON.
 // El archivo almacena un array de entidades del tipo T.
 type CrudDAL[T any] struct {
 	Filename string // Ruta al archivo JSON donde se guardan las entidades
+	actor    string
 }
 
-type Initializable interface {
-	Init()
+func (u *CrudDAL[T]) collection() *collection[T] {
+	return &collection[T]{store: &jsonStore[T]{filename: u.Filename}, actor: u.actor}
+}
+
+// As devuelve un CrudDAL sobre el mismo archivo que firma las escrituras con actor.
+func (u *CrudDAL[T]) As(actor string) dao.CrudDAO[T] {
+	return &CrudDAL[T]{Filename: u.Filename, actor: actor}
 }
 
 // Create agrega una nueva entidad al archivo JSON.
 func (u *CrudDAL[T]) Create(entity *T) (*T, error) {
-	var data []T
+	return u.collection().Create(entity)
+}
 
-	if v, ok := any(entity).(Initializable); ok {
-		v.Init()
-	}
+// GetByID busca una entidad con el campo `ID` igual al solicitado.
+func (u *CrudDAL[T]) GetByID(uid string) (*T, error) {
+	return u.collection().GetByID(uid)
+}
 
-	if err := utils.ReadJSON(u.Filename, &data); err != nil {
-		data = []T{}
-	}
+// Find busca una entidad por ID; deleted decide si se ven las borradas.
+func (u *CrudDAL[T]) Find(uid string, deleted dao.DeletedFilter) (*T, error) {
+	return u.collection().Find(uid, deleted)
+}
 
-	data = append(data, *entity)
+// GetAll devuelve todas las entidades del JSON.
+func (u *CrudDAL[T]) GetAll(q string, limit int, offset int) ([]*T, error) {
+	return u.collection().GetAll(q, limit, offset)
+}
 
-	if err := utils.WriteJSON(u.Filename, data); err != nil {
-		return nil, fmt.Errorf("Can't save the entity with error: %w", err)
-	}
+// List devuelve las entidades del JSON que cumplen los filtros de query.
+func (u *CrudDAL[T]) List(query dao.ListQuery) ([]*T, error) {
+	return u.collection().List(query)
+}
 
-	return entity, nil
+// Page devuelve una página del JSON con el total y los cursores vecinos.
+func (u *CrudDAL[T]) Page(query dao.ListQuery) (dao.Page[T], error) {
+	return u.collection().Page(query)
 }
 
-// GetByID busca una entidad con el campo `ID` igual al solicitado.
-func (u *CrudDAL[T]) GetByID(uid string) (*T, error) {
-	var data []T
+// Update reemplaza los campos no vacíos de una entidad existente (por ID).
+func (u *CrudDAL[T]) Update(entity *T, id string) (*T, error) {
+	return u.collection().Update(entity, id)
+}
 
-	if err := utils.ReadJSON(u.Filename, &data); err != nil {
-		return nil, fmt.Errorf("error writing JSON: %w", err)
-	}
+// Modify aplica mutate sobre la entidad guardada y la persiste atómicamente.
+func (u *CrudDAL[T]) Modify(id string, mutate func(entity *T) error) (*T, error) {
+	return u.collection().Modify(id, mutate)
+}
 
-	for _, item := range data {
-		v := reflect.ValueOf(item)
+// Delete manda una entidad a la papelera.
+func (u *CrudDAL[T]) Delete(id string) (bool, error) {
+	return u.collection().Delete(id)
+}
 
-		if v, ok := any(&item).(Initializable); ok {
-			v.Init()
-		}
+// Restore saca una entidad de la papelera.
+func (u *CrudDAL[T]) Restore(id string) (*T, error) {
+	return u.collection().Restore(id)
+}
 
-		idField := v.FieldByName("ID")
+// Purge elimina del archivo las entidades borradas antes de before.
+func (u *CrudDAL[T]) Purge(before time.Time) (int, error) {
+	return u.collection().Purge(before)
+}
 
-		if idField.IsValid() && idField.Kind() == reflect.String && idField.String() == uid {
-			return &item, nil
-		}
-	}
+// Revisions devuelve las versiones de una entidad, de la más vieja a la actual.
+func (u *CrudDAL[T]) Revisions(id string) ([]dao.Revision[T], error) {
+	return u.collection().Revisions(id)
+}
 
-	return nil, fmt.Errorf("Can't find entity with UID %s", uid)
+// Revision devuelve una versión de una entidad.
+func (u *CrudDAL[T]) Revision(id string, rev int64) (*dao.Revision[T], error) {
+	return u.collection().Revision(id, rev)
 }
 
-// GetAll devuelve todas las entidades del JSON.
-func (u *CrudDAL[T]) GetAll(q string, limit int, offset int) ([]*T, error) {
-	var data []T
+// Revert vuelve una entidad al contenido de una versión anterior.
+func (u *CrudDAL[T]) Revert(id string, rev int64, expected int64) (*T, error) {
+	return u.collection().Revert(id, rev, expected)
+}
+
+// LastChange identifica la última escritura sobre el archivo.
+func (u *CrudDAL[T]) LastChange() (dao.Change, error) {
+	return u.collection().LastChange()
+}
+
+// jsonStore guarda la colección completa como un array en <filename>.json.
+// Las lecturas se resuelven con la copia decodificada que guarda fileState
+// mientras el archivo no cambie en disco.
+type jsonStore[T any] struct {
+	filename string
+}
 
-	if err := utils.ReadJSON(u.Filename, &data); err != nil {
-		return nil, fmt.Errorf("error writing JSON: %w", err)
+func (s *jsonStore[T]) view(fn func(tx recordTx[T]) error) error {
+	state := stateFor(s.filename)
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	unlock, err := utils.LockFile(s.filename, false)
+	if errors.Is(err, fs.ErrNotExist) {
+		// El directorio todavía no existe: no hay nada que leer ni que proteger.
+		unlock = func() error { return nil }
+	} else if err != nil {
+		return err
 	}
+	defer unlock()
+
+	snap, err := s.load(state)
+	if err != nil {
+		return fmt.Errorf("error reading JSON: %w", err)
+	}
+
+	return fn(newJSONTx(snap))
+}
 
-	// Default pagination values
-	if offset < 0 {
-		offset = 0
+func (s *jsonStore[T]) update(fn func(tx recordTx[T]) error) error {
+	if err := s.settle(); err != nil {
+		return err
 	}
 
-	if limit <= 0 {
-		limit = 10
+	state := stateFor(s.filename)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// Lock exclusivo entre procesos durante todo el ciclo leer-modificar-escribir.
+	unlock, err := utils.LockFile(s.filename, true)
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	filtered := []*T{}
+	snap, err := s.readForWrite(state)
+	if err != nil {
+		return err
+	}
 
-	// === 1) Apply filtering ===
-	for i := range data {
-		item := &data[i]
+	tx := newJSONTx(snap)
 
-		v := reflect.ValueOf(item).Elem()
-		nameField := v.FieldByName("Name")
+	if err := fn(tx); err != nil {
+		return err
+	}
 
-		// Apply substring filter "q"
-		if q != "" {
-			if !nameField.IsValid() || nameField.Kind() != reflect.String {
-				continue
-			}
+	if !tx.dirty {
+		return nil
+	}
 
-			name := strings.ToLower(nameField.String())
-			search := strings.ToLower(q)
+	return s.write(state, tx)
+}
 
-			if !strings.Contains(name, search) {
-				continue
-			}
-		}
+// write guarda lo que escribió tx. Se llama con los locks de s.filename tomados.
+func (s *jsonStore[T]) write(state *fileState, tx *jsonTx[T]) error {
+	// El historial va antes que el archivo (ver historyBuffer).
+	if err := historyFor(s.filename+".history.jsonl").save(tx.archived, tx.forgotten); err != nil {
+		return err
+	}
 
-		// Initialize entity if needed
-		if vi, ok := any(item).(Initializable); ok {
-			vi.Init()
-		}
+	if err := utils.WriteJSON(s.filename, tx.data); err != nil {
+		return fmt.Errorf("error writing JSON: %w", err)
+	}
 
-		filtered = append(filtered, item)
+	// Lo que acabamos de escribir pasa a ser la copia en memoria, así la
+	// próxima lectura no vuelve a decodificar el archivo.
+	if info, err := os.Stat(s.filename + ".json"); err == nil {
+		snap := newSnapshot(info, tx.data)
+		snap.refs = tx.indexes.publish()
+		storeSnapshot(state, snap)
 	}
 
-	// === 2) Apply pagination ===
-	total := len(filtered)
+	return nil
+}
 
-	if offset >= total {
-		// Nothing to return
-		return []*T{}, nil
+// history lee <filename>.history.jsonl con el mismo lock que una lectura: las
+// escrituras agregan versiones con el lock exclusivo tomado.
+func (s *jsonStore[T]) history(id string) ([][]byte, error) {
+	state := stateFor(s.filename)
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	unlock, err := utils.LockFile(s.filename, false)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
-	end := offset + limit
-	if end > total {
-		end = total
+	return historyFor(s.filename + ".history.jsonl").read(id)
+}
+
+// lastChange usa la info del archivo: cada escritura (de este u otro proceso)
+// lo reemplaza, así que mtime y tamaño cambian.
+func (s *jsonStore[T]) lastChange() (dao.Change, error) {
+	info, err := os.Stat(s.filename + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return dao.Change{Token: "0"}, nil
 	}
 
-	paginated := filtered[offset:end]
+	if err != nil {
+		return dao.Change{}, err
+	}
 
-	return paginated, nil
+	return dao.Change{
+		Token: fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()),
+		At:    info.ModTime().UTC(),
+	}, nil
 }
 
-// Update reemplaza los campos no vacíos de una entidad existente (por ID).
-func (u *CrudDAL[T]) Update(entity *T, id string) (*T, error) {
+// load devuelve el contenido del archivo: la copia en memoria si el archivo no
+// cambió desde la última lectura o escritura, o si no lo decodifica de nuevo.
+// Se llama con los locks de s.filename tomados.
+func (s *jsonStore[T]) load(state *fileState) (*jsonSnapshot[T], error) {
+	info, err := os.Stat(s.filename + ".json")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	name := filepath.Base(s.filename)
+
+	if snap := cached[T](state); snap != nil && snap.fresh(info) {
+		cacheHits.WithLabelValues(name).Inc()
+		return snap, nil
+	}
+
+	cacheMisses.WithLabelValues(name).Inc()
+
 	var data []T
 
-	if err := utils.ReadJSON(u.Filename, &data); err != nil {
-		return nil, fmt.Errorf("error writing JSON: %w", err)
+	info, err = utils.ReadJSONStat(s.filename, &data)
+	if err != nil {
+		return nil, err
 	}
 
-	for i := range data {
-		v := reflect.ValueOf(data[i])
-		idField := v.FieldByName("ID")
-		if idField.IsValid() && idField.Kind() == reflect.String && idField.String() == id {
-			updated, wasUpdated := updateData(entity, &data[i])
+	snap := newSnapshot(info, data)
+	storeSnapshot(state, snap)
 
-			if !wasUpdated {
-				return nil, fmt.Errorf("Update failed: invalid parameters or no parameters provided.")
-			}
+	return snap, nil
+}
 
-			if err := utils.WriteJSON(u.Filename, data); err != nil {
-				return nil, fmt.Errorf("error writing JSON: %w", err)
-			}
+// readForWrite lee el archivo antes de una escritura. Si el contenido no se
+// puede decodificar, el archivo se pone en cuarentena y la escritura se rechaza:
+// nunca se pisa un catálogo existente partiendo de un array vacío.
+func (s *jsonStore[T]) readForWrite(state *fileState) (*jsonSnapshot[T], error) {
+	snap, err := s.load(state)
 
-			if v, ok := any(updated).(Initializable); ok {
-				v.Init()
-			}
+	if err == nil {
+		return snap, nil
+	}
 
-			return updated, nil
-		}
+	if !errors.Is(err, utils.ErrCorruptJSON) {
+		return nil, fmt.Errorf("error reading JSON: %w", err)
+	}
+
+	quarantined, qErr := utils.QuarantineJSON(s.filename)
+	if qErr != nil {
+		return nil, fmt.Errorf("refusing to write over unreadable file: %w (quarantine failed: %v)", err, qErr)
 	}
 
-	return nil, fmt.Errorf("Can't find entity with ID %v", id)
+	return nil, fmt.Errorf("refusing to write over unreadable file, moved to %s: %w", quarantined, err)
 }
 
-// Delete elimina una entidad por ID.
-func (u *CrudDAL[T]) Delete(id string) (bool, error) {
-	var data []T
+// jsonTx opera sobre el array de un snapshot. El snapshot puede estar
+// compartido con otras lecturas, así que nunca se modifica: la primera
+// escritura copia el array (copy-on-write) y get devuelve copias profundas.
+type jsonTx[T any] struct {
+	historyBuffer[T]
+	data   []T
+	ids    map[string]int // nil cuando hay que reconstruirlo
+	refs   *lazyIndex     // índices secundarios del snapshot; nil si data cambió
+	shared bool
+	dirty  bool
+	// indexes son los índices de data después de las escrituras de tx.
+	indexes indexUpdate[T]
+}
 
-	if err := utils.ReadJSON(u.Filename, &data); err != nil {
-		return false, fmt.Errorf("error reading JSON: %w", err)
-	}
+func newJSONTx[T any](snap *jsonSnapshot[T]) *jsonTx[T] {
+	return &jsonTx[T]{data: snap.data, ids: snap.index, refs: snap.refs, shared: true, indexes: indexUpdate[T]{base: snap.refs}}
+}
 
-	found := false
-	newData := make([]T, 0)
+// savepoint arranca sobre el array de tx sin copiarlo: el copy-on-write del
+// hijo protege al padre.
+func (tx *jsonTx[T]) savepoint() (recordTx[T], func(keep bool) error, error) {
+	child := &jsonTx[T]{data: tx.data, ids: tx.ids, shared: true, indexes: tx.indexes.fork()}
 
-	for _, item := range data {
-		v := reflect.ValueOf(item)
-		idField := v.FieldByName("ID")
-		if idField.IsValid() && idField.Kind() == reflect.String && idField.String() == id {
-			found = true
-			continue // salta el eliminado
+	return child, func(keep bool) error {
+		if keep && child.dirty {
+			tx.data, tx.ids, tx.shared, tx.dirty, tx.refs = child.data, child.ids, child.shared, true, nil
+			tx.keep(&child.historyBuffer)
+			tx.indexes.adopt(child.indexes)
 		}
-		newData = append(newData, item)
+
+		return nil
+	}, nil
+}
+
+func (tx *jsonTx[T]) index(id string) int {
+	if tx.ids == nil {
+		tx.ids = newSnapshot(nil, tx.data).index
 	}
 
-	if !found {
-		return false, fmt.Errorf("Can't find entity with ID %v", id)
+	if i, ok := tx.ids[id]; ok {
+		return i
 	}
 
-	if err := utils.WriteJSON(u.Filename, newData); err != nil {
-		return false, fmt.Errorf("error writing JSON: %w", err)
+	return -1
+}
+
+// own copia el array antes de la primera modificación.
+func (tx *jsonTx[T]) own() {
+	if tx.shared {
+		tx.data = append(make([]T, 0, len(tx.data)+1), tx.data...)
+		tx.shared = false
 	}
 
-	return true, nil
+	tx.dirty = true
+	tx.refs = nil
 }
 
-// updateData reemplaza los campos no vacíos o no cero del origen en el destino.
-func updateData[T any](entity *T, existingEntity *T) (*T, bool) {
-	valSrc := reflect.ValueOf(entity).Elem()
-	valDst := reflect.ValueOf(existingEntity).Elem()
+func (tx *jsonTx[T]) lookup(field string, value string) ([]ranked[T], bool, error) {
+	if tx.refs == nil {
+		return nil, false, nil
+	}
 
-	wasUpdated := false
+	index, err := tx.refs.get(func() (secondaryIndex, error) {
+		return buildIndex(len(tx.data), func(i int) (*T, error) { return &tx.data[i], nil })
+	})
+	if err != nil {
+		return nil, false, err
+	}
 
-	for i := 0; i < valSrc.NumField(); i++ {
+	positions, ok := index.positions(field, value)
+	if !ok {
+		return nil, false, nil
+	}
 
-		fieldName := valSrc.Type().Field(i).Name
+	items := make([]ranked[T], 0, len(positions))
+	for _, i := range positions {
+		items = append(items, ranked[T]{item: &tx.data[i], seq: int64(i)})
+	}
 
-		if fieldName == "ID" {
-			continue
+	return items, true, nil
+}
+
+func (tx *jsonTx[T]) get(id string) (*T, error) {
+	i := tx.index(id)
+	if i < 0 {
+		return nil, nil
+	}
+
+	return cloneEntity(&tx.data[i])
+}
+
+func (tx *jsonTx[T]) scan(fn func(item *T) bool) error {
+	for i := range tx.data {
+		if !fn(&tx.data[i]) {
+			break
 		}
+	}
+
+	return nil
+}
+
+func (tx *jsonTx[T]) insert(item *T) error {
+	// Se guarda una copia: el caller conserva su puntero y no debe poder
+	// tocar lo que queda en memoria.
+	stored, err := cloneEntity(item)
+	if err != nil {
+		return err
+	}
+
+	tx.own()
+	tx.indexes.insert(len(tx.data), stored)
+	tx.data = append(tx.data, *stored)
+	tx.ids = nil
+
+	return nil
+}
 
-		srcField := valSrc.Field(i)
-		dstField := valDst.Field(i)
+func (tx *jsonTx[T]) replace(id string, item *T) error {
+	i := tx.index(id)
+	if i < 0 {
+		return dao.Errorf(dao.ErrNotFound, "Can't find entity with ID %v", id)
+	}
+
+	stored, err := cloneEntity(item)
+	if err != nil {
+		return err
+	}
+
+	tx.own()
+	tx.indexes.replace(i, &tx.data[i], stored)
+	tx.data[i] = *stored
+
+	return nil
+}
+
+func (tx *jsonTx[T]) remove(id string) (bool, error) {
+	i := tx.index(id)
+	if i < 0 {
+		return false, nil
+	}
+
+	tx.indexes.remove(i, &tx.data[i])
 
-		if srcField.IsValid() && !srcField.IsZero() && dstField.CanSet() {
-			dstField.Set(srcField)
-			wasUpdated = true
+	newData := make([]T, 0, len(tx.data))
+
+	for _, item := range tx.data {
+		if entityID(&item) == id {
+			continue // salta el eliminado
 		}
+		newData = append(newData, item)
 	}
 
-	return existingEntity, wasUpdated
+	tx.data = newData
+	tx.shared = false
+	tx.ids = nil
+	tx.refs = nil
+	tx.dirty = true
+
+	return true, nil
 }
@@ -0,0 +1,519 @@
+package dal
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"project/internal/item_detail/repo/datasource/dao"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Filtros y orden genéricos (ListQuery.Filters y ListQuery.Sort): se resuelven
+// contra los campos del modelo por su nombre JSON, incluidos los de structs
+// embebidos (Metadata). Se pueden filtrar y ordenar los campos string, bool,
+// numéricos y fechas, o punteros a ellos; un puntero nil no cumple ningún
+// filtro y ordena antes que cualquier valor. Los campos con el tag filter:"-"
+// (por ejemplo, los calculados) quedan afuera.
+
+type fieldKind int
+
+const (
+	kindUnsupported fieldKind = iota
+	kindString
+	kindNumber
+	kindBool
+	kindTime
+)
+
+func (k fieldKind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindNumber:
+		return "number"
+	case kindBool:
+		return "bool"
+	case kindTime:
+		return "date"
+	}
+
+	return "unsupported"
+}
+
+type filterField struct {
+	path    []int // para reflect.Value.FieldByIndex
+	name    string
+	kind    fieldKind
+	pointer bool
+}
+
+var filterFieldsCache sync.Map // reflect.Type -> map[string]filterField
+
+// filterFields devuelve, por nombre JSON, los campos de T. Los que no se
+// pueden filtrar quedan con kindUnsupported, así el error lo dice.
+func filterFields[T any]() map[string]filterField {
+	t := reflect.TypeFor[T]()
+
+	if cached, ok := filterFieldsCache.Load(t); ok {
+		return cached.(map[string]filterField)
+	}
+
+	fields := map[string]filterField{}
+	collectFilterFields(t, nil, fields)
+
+	filterFieldsCache.Store(t, fields)
+
+	return fields
+}
+
+func collectFilterFields(t reflect.Type, path []int, fields map[string]filterField) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(slices.Clone(path), i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			collectFilterFields(field.Type, index, fields)
+			continue
+		}
+
+		if !field.IsExported() || strings.HasPrefix(field.Tag.Get("json"), "-") {
+			continue
+		}
+
+		f := filterField{path: index, name: jsonName(field)}
+
+		if field.Tag.Get("filter") != "-" {
+			typ := field.Type
+			if typ.Kind() == reflect.Pointer {
+				typ = typ.Elem()
+				f.pointer = true
+			}
+
+			f.kind = kindOf(typ)
+		}
+
+		fields[f.name] = f
+	}
+}
+
+func kindOf(t reflect.Type) fieldKind {
+	if t == reflect.TypeFor[time.Time]() {
+		return kindTime
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return kindString
+	case reflect.Bool:
+		return kindBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return kindNumber
+	}
+
+	return kindUnsupported
+}
+
+// value devuelve el valor del campo normalizado (string, float64, bool o
+// time.Time); ok es false si es un puntero nil.
+func (f filterField) value(item reflect.Value) (any, bool) {
+	v := item.FieldByIndex(f.path)
+
+	if f.pointer {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+
+	switch f.kind {
+	case kindString:
+		return v.String(), true
+	case kindBool:
+		return v.Bool(), true
+	case kindTime:
+		return v.Interface().(time.Time), true
+	}
+
+	switch {
+	case v.CanInt():
+		return float64(v.Int()), true
+	case v.CanUint():
+		return float64(v.Uint()), true
+	}
+
+	return v.Float(), true
+}
+
+// parse interpreta raw según el tipo del campo.
+func (f filterField) parse(raw string) (any, error) {
+	switch f.kind {
+	case kindNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, dao.Errorf(dao.ErrInvalidQuery, "invalid value %q for %s: must be a number", raw, f.name)
+		}
+		return n, nil
+	case kindBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, dao.Errorf(dao.ErrInvalidQuery, "invalid value %q for %s: must be true or false", raw, f.name)
+		}
+		return b, nil
+	case kindTime:
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, dao.Errorf(dao.ErrInvalidQuery, "invalid value %q for %s: must be an RFC 3339 date", raw, f.name)
+		}
+		return t, nil
+	}
+
+	return raw, nil
+}
+
+// compareValues compara dos valores ya normalizados del mismo tipo.
+func compareValues(a any, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case bool:
+		switch {
+		case a == b.(bool):
+			return 0
+		case a:
+			return 1
+		}
+		return -1
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+
+	return 0
+}
+
+type fieldFilter struct {
+	field  filterField
+	op     dao.FilterOp
+	values []any // uno, salvo en OpIn
+}
+
+func (f fieldFilter) match(item reflect.Value) bool {
+	got, ok := f.field.value(item)
+	if !ok {
+		return false
+	}
+
+	switch f.op {
+	case dao.OpContains:
+		return strings.Contains(strings.ToLower(got.(string)), strings.ToLower(f.values[0].(string)))
+	case dao.OpIn:
+		return slices.ContainsFunc(f.values, func(want any) bool {
+			return compareValues(got, want) == 0
+		})
+	}
+
+	c := compareValues(got, f.values[0])
+
+	switch f.op {
+	case dao.OpEq:
+		return c == 0
+	case dao.OpNe:
+		return c != 0
+	case dao.OpGt:
+		return c > 0
+	case dao.OpGte:
+		return c >= 0
+	case dao.OpLt:
+		return c < 0
+	}
+
+	return c <= 0 // dao.OpLte
+}
+
+type sortField struct {
+	field filterField
+	desc  bool
+}
+
+// compare compara dos valores del campo según la dirección; ok es false si
+// el valor es nil, que ordena primero.
+func (s sortField) compare(x any, okX bool, y any, okY bool) int {
+	var c int
+
+	switch {
+	case !okX || !okY:
+		c = cmp.Compare(boolRank(okX), boolRank(okY))
+	default:
+		c = compareValues(x, y)
+	}
+
+	if s.desc {
+		return -c
+	}
+
+	return c
+}
+
+// listFilter es Filters, Sort y Cursor de un ListQuery ya validados contra T.
+type listFilter[T any] struct {
+	filters   []fieldFilter
+	sort      []sortField
+	signature string // sortSignature(query.Sort), para los cursores
+	cursor    *pageCursor
+}
+
+// compileListFilter valida los filtros y el orden de query contra los campos
+// de T. Los errores son ErrInvalidQuery.
+func compileListFilter[T any](query dao.ListQuery) (listFilter[T], error) {
+	var compiled listFilter[T]
+
+	fields := filterFields[T]()
+
+	resolve := func(name string) (filterField, error) {
+		field, ok := fields[name]
+
+		switch {
+		case !ok:
+			return field, dao.Errorf(dao.ErrInvalidQuery, "unknown field %q", name)
+		case field.kind == kindUnsupported:
+			return field, dao.Errorf(dao.ErrInvalidQuery, "field %q can't be used to filter or sort", name)
+		}
+
+		return field, nil
+	}
+
+	for _, filter := range query.Filters {
+		field, err := resolve(filter.Field)
+		if err != nil {
+			return compiled, err
+		}
+
+		switch filter.Op {
+		case dao.OpEq, dao.OpNe, dao.OpIn:
+		case dao.OpGt, dao.OpGte, dao.OpLt, dao.OpLte:
+			if field.kind == kindBool {
+				return compiled, dao.Errorf(dao.ErrInvalidQuery, "operator %q doesn't apply to %s field %q", filter.Op, field.kind, field.name)
+			}
+		case dao.OpContains:
+			if field.kind != kindString {
+				return compiled, dao.Errorf(dao.ErrInvalidQuery, "operator %q doesn't apply to %s field %q", filter.Op, field.kind, field.name)
+			}
+		default:
+			return compiled, dao.Errorf(dao.ErrInvalidQuery, "unknown operator %q", filter.Op)
+		}
+
+		raws := []string{filter.Value}
+
+		switch {
+		case filter.Values != nil && filter.Op != dao.OpIn:
+			return compiled, dao.Errorf(dao.ErrInvalidQuery, "operator %q doesn't take a list of values", filter.Op)
+		case filter.Values != nil:
+			raws = filter.Values
+		case filter.Op == dao.OpIn:
+			raws = strings.Split(filter.Value, ",")
+		}
+
+		compiledFilter := fieldFilter{field: field, op: filter.Op}
+
+		for _, raw := range raws {
+			value, err := field.parse(raw)
+			if err != nil {
+				return compiled, err
+			}
+
+			compiledFilter.values = append(compiledFilter.values, value)
+		}
+
+		compiled.filters = append(compiled.filters, compiledFilter)
+	}
+
+	for _, key := range query.Sort {
+		field, err := resolve(key.Field)
+		if err != nil {
+			return compiled, err
+		}
+
+		compiled.sort = append(compiled.sort, sortField{field: field, desc: key.Desc})
+	}
+
+	compiled.signature = sortSignature(query.Sort)
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor, query, compiled.sort)
+		if err != nil {
+			return compiled, err
+		}
+
+		compiled.cursor = cursor
+	}
+
+	return compiled, nil
+}
+
+// match indica si item cumple todos los filtros.
+func (l listFilter[T]) match(item *T) bool {
+	if len(l.filters) == 0 {
+		return true
+	}
+
+	value := reflect.ValueOf(item).Elem()
+
+	for _, filter := range l.filters {
+		if !filter.match(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apply ordena items según Sort. El orden es estable: los empates quedan en
+// orden de alta.
+func (l listFilter[T]) apply(items []ranked[T]) {
+	if len(l.sort) == 0 {
+		return
+	}
+
+	slices.SortStableFunc(items, func(a ranked[T], b ranked[T]) int {
+		va, vb := reflect.ValueOf(a.item).Elem(), reflect.ValueOf(b.item).Elem()
+
+		for _, key := range l.sort {
+			x, okX := key.field.value(va)
+			y, okY := key.field.value(vb)
+
+			if c := key.compare(x, okX, y, okY); c != 0 {
+				return c
+			}
+		}
+
+		return 0
+	})
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// pushdown indica si sqlite puede resolver los filtros, el orden y la
+// página en SQL con el mismo resultado. Las fechas (texto con fracciones de
+// largo variable), contains (lower de SQLite sólo entiende ASCII) y los
+// cursores se resuelven en Go.
+func (l listFilter[T]) pushdown() bool {
+	if l.cursor != nil {
+		return false
+	}
+
+	for _, filter := range l.filters {
+		if filter.field.kind == kindTime || filter.op == dao.OpContains {
+			return false
+		}
+	}
+
+	for _, key := range l.sort {
+		if key.field.kind == kindTime {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sqlField es la expresión SQL del campo. Los campos que no son punteros
+// valen su cero cuando faltan en el JSON (omitempty), igual que en Go.
+func (f filterField) sqlField() string {
+	if f.pointer {
+		return jsonPath(f.name)
+	}
+
+	switch f.kind {
+	case kindString:
+		return "coalesce(" + jsonPath(f.name) + ", '')"
+	default:
+		return "coalesce(" + jsonPath(f.name) + ", 0)"
+	}
+}
+
+// sqlArg adapta un valor normalizado a lo que devuelve json_extract.
+func sqlArg(value any) any {
+	if b, ok := value.(bool); ok {
+		return boolRank(b)
+	}
+
+	return value
+}
+
+var sqlOperators = map[dao.FilterOp]string{
+	dao.OpEq: "=", dao.OpNe: "!=", dao.OpGt: ">", dao.OpGte: ">=", dao.OpLt: "<", dao.OpLte: "<=",
+}
+
+// sqlInChunk es la mayor lista de OpIn que va con una variable por valor.
+const sqlInChunk = 100
+
+// where devuelve las condiciones SQL de los filtros (sólo si pushdown).
+func (l listFilter[T]) where() ([]string, []any, error) {
+	var conditions []string
+	var args []any
+
+	for _, filter := range l.filters {
+		if filter.op == dao.OpIn && len(filter.values) > sqlInChunk {
+			// Una lista larga (los IDs de una búsqueda) pasaría el límite de
+			// variables de SQLite: va como un solo array JSON.
+			values := make([]any, len(filter.values))
+			for i, value := range filter.values {
+				values[i] = sqlArg(value)
+			}
+
+			list, err := json.Marshal(values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error encoding filter values: %w", err)
+			}
+
+			conditions = append(conditions, filter.field.sqlField()+" IN (SELECT value FROM json_each(?))")
+			args = append(args, string(list))
+			continue
+		}
+
+		if filter.op == dao.OpIn {
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)",
+				filter.field.sqlField(), strings.TrimSuffix(strings.Repeat("?, ", len(filter.values)), ", ")))
+			for _, value := range filter.values {
+				args = append(args, sqlArg(value))
+			}
+			continue
+		}
+
+		conditions = append(conditions, filter.field.sqlField()+" "+sqlOperators[filter.op]+" ?")
+		args = append(args, sqlArg(filter.values[0]))
+	}
+
+	return conditions, args, nil
+}
+
+// orderBy devuelve las expresiones ORDER BY del orden (sólo si pushdown).
+// NULL ordena primero en ASC, como los punteros nil en Go.
+func (l listFilter[T]) orderBy() []string {
+	var terms []string
+
+	for _, key := range l.sort {
+		term := key.field.sqlField()
+		if key.desc {
+			term += " DESC"
+		}
+
+		terms = append(terms, term)
+	}
+
+	return terms
+}
@@ -0,0 +1,480 @@
+package dal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/utils"
+	"sort"
+	"sync"
+)
+
+// historyBuffer acumula las versiones anteriores que archiva una escritura.
+// El store las guarda al confirmarla, antes (o dentro) de la escritura de la
+// entidad: si la escritura falla, queda archivada una versión que sigue siendo
+// la actual, y collection descarta esas al armar las revisiones.
+type historyBuffer[T any] struct {
+	archived  []memoryRecord
+	forgotten []string // IDs purgados: su historial se borra al confirmar
+}
+
+// archive serializa item en el momento, así los cambios que se le hagan
+// después no alteran la versión archivada.
+func (b *historyBuffer[T]) archive(item *T) error {
+	rec, err := encodeRecord(item)
+	if err != nil {
+		return err
+	}
+
+	b.archived = append(b.archived, rec)
+
+	return nil
+}
+
+func (b *historyBuffer[T]) forget(id string) error {
+	b.forgotten = append(b.forgotten, id)
+
+	return nil
+}
+
+// keep incorpora lo que acumuló child (una escritura anidada).
+func (b *historyBuffer[T]) keep(child *historyBuffer[T]) {
+	b.archived = append(b.archived, child.archived...)
+	b.forgotten = append(b.forgotten, child.forgotten...)
+}
+
+// historyLine es una línea de los archivos <nombre>.history.jsonl.
+type historyLine struct {
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// historyFile es un archivo <nombre>.history.jsonl con un índice de dónde
+// empieza cada línea, por ID: leer las versiones de una entidad no recorre
+// el archivo entero. El índice se extiende con lo que se agregó desde la
+// última lectura (también por otro proceso) y se rearma si el archivo se
+// reemplazó (ver prune).
+type historyFile struct {
+	path string
+
+	mu      sync.Mutex
+	info    os.FileInfo        // archivo que describe offsets; nil sin leer
+	size    int64              // hasta dónde llega offsets
+	offsets map[string][]int64 // inicio de cada línea, por ID
+}
+
+var historyFiles sync.Map
+
+// historyFor devuelve el historial de path, compartido por todos los stores
+// del proceso que lo usan.
+func historyFor(path string) *historyFile {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+
+	h, _ := historyFiles.LoadOrStore(key, &historyFile{path: path})
+
+	return h.(*historyFile)
+}
+
+// append agrega records al final del archivo (una línea JSON por versión) y
+// hace fsync. Si una escritura anterior quedó cortada, arranca en una línea
+// nueva para no pegarse a la basura.
+func (h *historyFile) append(records []memoryRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(h.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening history %s: %w", h.path, err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+
+	for _, rec := range records {
+		line, err := json.Marshal(historyLine{ID: rec.id, Data: rec.raw})
+		if err != nil {
+			return fmt.Errorf("error encoding history: %w", err)
+		}
+
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("error writing history %s: %w", h.path, err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("error writing history %s: %w", h.path, err)
+	}
+
+	return nil
+}
+
+// read devuelve, en orden de escritura, las versiones de id. Las líneas
+// ilegibles (escrituras cortadas por una caída) se saltean.
+func (h *historyFile) read(id string) ([][]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.Open(h.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		h.info = nil
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading history %s: %w", h.path, err)
+	}
+	defer f.Close()
+
+	if err := h.index(f); err != nil {
+		return nil, fmt.Errorf("error reading history %s: %w", h.path, err)
+	}
+
+	var versions [][]byte
+
+	for _, offset := range h.offsets[id] {
+		line, err := bufio.NewReader(io.NewSectionReader(f, offset, h.size-offset)).ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("error reading history %s: %w", h.path, err)
+		}
+
+		var entry historyLine
+		if json.Unmarshal(line, &entry) == nil && entry.ID == id {
+			versions = append(versions, entry.Data)
+		}
+	}
+
+	return versions, nil
+}
+
+// invalidate descarta el índice: el archivo se restauró a un estado anterior
+// (ver restoreHistory) y los offsets pueden no servir aunque no haya cambiado
+// de archivo ni achicado respecto de la última lectura.
+func (h *historyFile) invalidate() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.info = nil
+}
+
+// index pone offsets al día con f. Se llama con h.mu tomado.
+func (h *historyFile) index(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if h.info == nil || !os.SameFile(h.info, info) || info.Size() < h.size {
+		h.size, h.offsets = 0, map[string][]int64{}
+	}
+
+	h.info = info
+
+	reader := bufio.NewReader(io.NewSectionReader(f, h.size, info.Size()-h.size))
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		// Una línea sin terminar se vuelve a mirar en la próxima lectura.
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var entry struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(line, &entry) == nil {
+			h.offsets[entry.ID] = append(h.offsets[entry.ID], h.size)
+		}
+
+		h.size += int64(len(line))
+	}
+}
+
+// prune reescribe el archivo sin las versiones de ids (las entidades que se
+// purgaron). Como WriteJSON, escribe un temporal y lo renombra encima.
+func (h *historyFile) prune(ids []string) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// El archivo nuevo invalida el índice.
+	h.info = nil
+
+	src, err := os.Open(h.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("error reading history %s: %w", h.path, err)
+	}
+	defer src.Close()
+
+	forgotten := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		forgotten[id] = true
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error pruning history %s: %w", h.path, err)
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	reader := bufio.NewReader(src)
+	writer := bufio.NewWriter(tmp)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		var entry struct {
+			ID string `json:"id"`
+		}
+
+		// Las líneas ilegibles también se van: nadie las puede leer.
+		if len(line) > 0 && json.Unmarshal(line, &entry) == nil && !forgotten[entry.ID] {
+			writer.Write(line)
+
+			if line[len(line)-1] != '\n' {
+				writer.WriteByte('\n')
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("error reading history %s: %w", h.path, err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error pruning history %s: %w", h.path, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("error pruning history %s: %w", h.path, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error pruning history %s: %w", h.path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("error pruning history %s: %w", h.path, err)
+	}
+
+	committed = true
+
+	return utils.SyncDir(filepath.Dir(h.path))
+}
+
+// save guarda lo que acumuló una escritura: primero borra las versiones de
+// las entidades purgadas y después agrega las archivadas. Va antes de la
+// escritura de la entidad: si ésta falla, una entidad que se iba a purgar ya
+// perdió su historial, que es lo que iba a pasar de todos modos.
+func (h *historyFile) save(archived []memoryRecord, forgotten []string) error {
+	if err := h.prune(forgotten); err != nil {
+		return err
+	}
+
+	return h.append(archived)
+}
+
+// Revisions devuelve las versiones anteriores guardadas en el historial más la
+// actual, de la más vieja a la más nueva. Sólo cuentan las de la misma alta
+// (mismo CreatedAt): si la entidad se purgó y se volvió a crear con el mismo
+// ID, su historial arranca de nuevo.
+func (c *collection[T]) Revisions(id string) ([]dao.Revision[T], error) {
+	current, err := c.Find(id, dao.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	m := meta(current)
+	if m == nil {
+		return nil, dao.Errorf(dao.ErrNotFound, "Entity with ID %v has no revision history", id)
+	}
+
+	raws, err := c.store.history(id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", unavailable(err))
+	}
+
+	byRev := map[int64]*T{}
+
+	for _, raw := range raws {
+		item, err := decodeRecord[T](raw)
+		if err != nil {
+			return nil, fmt.Errorf("error reading history: %w", unavailable(err))
+		}
+
+		old := meta(item)
+
+		if old.Version < m.Version && old.CreatedAt.Equal(m.CreatedAt) {
+			initEntity(item)
+			byRev[old.Version] = item
+		}
+	}
+
+	byRev[m.Version] = current
+
+	revisions := make([]dao.Revision[T], 0, len(byRev))
+
+	for _, item := range byRev {
+		revisions = append(revisions, revisionOf(item))
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Rev < revisions[j].Rev })
+
+	return revisions, nil
+}
+
+// Revision devuelve la versión rev de la entidad.
+func (c *collection[T]) Revision(id string, rev int64) (*dao.Revision[T], error) {
+	revisions, err := c.Revisions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range revisions {
+		if revisions[i].Rev == rev {
+			return &revisions[i], nil
+		}
+	}
+
+	return nil, dao.Errorf(dao.ErrNotFound, "Can't find revision %d of entity with ID %v", rev, id)
+}
+
+// Revert guarda el contenido de la versión rev como una versión nueva. Los
+// datos de control siguen siendo los de la entidad actual. Una entidad borrada
+// primero se tiene que restaurar.
+func (c *collection[T]) Revert(id string, rev int64, expected int64) (*T, error) {
+	// Las versiones archivadas no cambian: se pueden leer antes de escribir.
+	target, err := c.Revision(id, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted *T
+
+	err = c.store.update(func(tx recordTx[T]) error {
+		existing, err := tx.get(id)
+		if err != nil {
+			return fmt.Errorf("error reading entity: %w", err)
+		}
+
+		if existing == nil || isDeleted(existing) {
+			return dao.Errorf(dao.ErrNotFound, "Can't find entity with ID %v", id)
+		}
+
+		current := meta(existing)
+
+		if expected != 0 && current.Version != expected {
+			return dao.Errorf(dao.ErrVersionMismatch, "Version mismatch: expected %d, current is %d", expected, current.Version)
+		}
+
+		if !current.CreatedAt.Equal(meta(target.Entity).CreatedAt) {
+			return dao.Errorf(dao.ErrNotFound, "Can't find revision %d of entity with ID %v", rev, id)
+		}
+
+		if current.Version == rev {
+			return dao.Errorf(dao.ErrInvalidUpdate, "Entity with ID %v is already at revision %d", id, rev)
+		}
+
+		if err := archive(tx, existing); err != nil {
+			return err
+		}
+
+		restored, err := cloneEntity(target.Entity)
+		if err != nil {
+			return err
+		}
+
+		m := meta(restored)
+		*m = *current
+		c.touch(m)
+
+		if err := checkUnique(tx, restored, existing); err != nil {
+			return err
+		}
+
+		if err := tx.replace(id, restored); err != nil {
+			return fmt.Errorf("error writing entity: %w", err)
+		}
+
+		reverted = restored
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	initEntity(reverted)
+
+	return reverted, nil
+}
+
+// archive guarda en el historial la versión actual de item antes de
+// reemplazarla. Las entidades sin datos de control no tienen historial.
+func archive[T any](tx recordTx[T], item *T) error {
+	if meta(item) == nil {
+		return nil
+	}
+
+	if err := tx.archive(item); err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+
+	return nil
+}
+
+func revisionOf[T any](item *T) dao.Revision[T] {
+	m := meta(item)
+
+	return dao.Revision[T]{
+		Rev:       m.Version,
+		UpdatedAt: m.UpdatedAt,
+		UpdatedBy: m.UpdatedBy,
+		Deleted:   m.Deleted(),
+		Entity:    item,
+	}
+}
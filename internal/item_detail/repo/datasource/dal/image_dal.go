@@ -1,16 +1,39 @@
 package dal
 
 import (
+	"database/sql"
+	"path/filepath"
 	"project/internal/item_detail/repo/datasource/dao"
 	models "project/pkg"
 )
 
 type imageDAL struct {
-	*CrudDAL[models.Image]
+	dao.CrudDAO[models.Image]
 }
 
+// NewImageDAL usa Image.json en el directorio de trabajo.
 func NewImageDAL() dao.ImageDAO {
+	return NewJSONImageDAL(".")
+}
+
+// NewJSONImageDAL usa Image.json dentro de dir.
+func NewJSONImageDAL(dir string) dao.ImageDAO {
 	return &imageDAL{
-		CrudDAL: &CrudDAL[models.Image]{Filename: "Image"},
+		CrudDAO: &CrudDAL[models.Image]{Filename: filepath.Join(dir, "Image")},
+	}
+}
+
+// NewMemoryImageDAL guarda las imágenes en memoria.
+func NewMemoryImageDAL() dao.ImageDAO {
+	return &imageDAL{CrudDAO: NewMemoryDAL[models.Image]()}
+}
+
+// NewSQLiteImageDAL usa la tabla images de db.
+func NewSQLiteImageDAL(db *sql.DB) (dao.ImageDAO, error) {
+	crud, err := NewSQLiteDAL[models.Image](db, "images")
+	if err != nil {
+		return nil, err
 	}
+
+	return &imageDAL{CrudDAO: crud}, nil
 }
@@ -0,0 +1,375 @@
+package dal
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Índices secundarios: un campo string del modelo con el tag index:"true" se
+// indexa por valor, y List con ListQuery.Equals sobre ese campo lee sólo las
+// entidades que lo tienen en lugar de recorrer la colección. Los drivers en
+// memoria (json, memory, journal) arman el índice la primera vez que se
+// consulta y después lo mantienen en cada escritura (ver indexUpdate); sqlite
+// usa un índice de la base.
+
+// indexLookup es opcional: los recordTx que mantienen los índices lo
+// implementan. Devuelve las entidades con su posición, como scan. ok es false si el campo no está indexado o el índice no está
+// disponible (por ejemplo, dentro de una escritura); el caller recorre.
+type indexLookup[T any] interface {
+	lookup(field string, value string) (items []ranked[T], ok bool, err error)
+}
+
+// secondaryIndex guarda, por campo (nombre JSON) y valor, las posiciones de
+// las entidades en orden de inserción.
+type secondaryIndex map[string]map[string][]int
+
+// lazyIndex arma el índice de una versión de la colección la primera vez que
+// se lo pide. Las lecturas concurrentes lo comparten.
+type lazyIndex struct {
+	once  sync.Once
+	index secondaryIndex
+	err   error
+	built atomic.Bool
+}
+
+func (l *lazyIndex) get(build func() (secondaryIndex, error)) (secondaryIndex, error) {
+	l.once.Do(func() {
+		l.index, l.err = build()
+		l.built.Store(l.err == nil)
+	})
+
+	return l.index, l.err
+}
+
+// ready devuelve el índice sólo si ya está armado.
+func (l *lazyIndex) ready() (secondaryIndex, bool) {
+	if l == nil || !l.built.Load() {
+		return nil, false
+	}
+
+	return l.index, true
+}
+
+// builtIndex es un lazyIndex que ya tiene index.
+func builtIndex(index secondaryIndex) *lazyIndex {
+	l := &lazyIndex{}
+	l.get(func() (secondaryIndex, error) { return index, nil })
+
+	return l
+}
+
+// indexUpdate arma, durante una escritura, el índice de la versión nueva de
+// la colección a partir del de la versión publicada (base), sin volver a leer
+// las entidades. base nunca se toca, porque lo siguen usando las lecturas:
+// se copian los mapas y sólo las listas de posiciones que cambian.
+//
+// Si base todavía no se armó no hay nada que mantener: la versión nueva lo
+// arma en su primera consulta.
+type indexUpdate[T any] struct {
+	base    *lazyIndex
+	index   secondaryIndex     // nil hasta la primera escritura (o si base no está armado)
+	owned   map[[2]string]bool // listas de index que ya son copias propias
+	touched bool               // hubo escrituras
+}
+
+// tracking indica si hay un índice que mantener: si no, las escrituras no
+// necesitan la versión anterior de la entidad.
+func (u *indexUpdate[T]) tracking() bool {
+	if u.index != nil {
+		return true
+	}
+
+	_, ok := u.base.ready()
+
+	return ok && len(indexedFields[T]()) > 0
+}
+
+// writable devuelve el índice que se está armando (nil si no hay).
+func (u *indexUpdate[T]) writable() secondaryIndex {
+	u.touched = true
+
+	if u.index == nil {
+		base, ok := u.base.ready()
+		if !ok || len(indexedFields[T]()) == 0 {
+			return nil
+		}
+
+		u.index = make(secondaryIndex, len(base))
+		for field, byValue := range base {
+			u.index[field] = maps.Clone(byValue)
+		}
+
+		u.owned = map[[2]string]bool{}
+	}
+
+	return u.index
+}
+
+// posting devuelve la lista de posiciones de value en field, ya copiada.
+func (u *indexUpdate[T]) posting(field string, value string) []int {
+	key := [2]string{field, value}
+
+	if !u.owned[key] {
+		u.index[field][value] = slices.Clone(u.index[field][value])
+		u.owned[key] = true
+	}
+
+	return u.index[field][value]
+}
+
+func (u *indexUpdate[T]) set(field string, value string, positions []int) {
+	if len(positions) == 0 {
+		delete(u.index[field], value)
+		return
+	}
+
+	u.index[field][value] = positions
+}
+
+// insert registra item en la posición pos, la última.
+func (u *indexUpdate[T]) insert(pos int, item *T) {
+	if u.writable() == nil {
+		return
+	}
+
+	for _, field := range indexedFields[T]() {
+		value := fieldValue(item, field)
+		u.set(field.name, value, append(u.posting(field.name, value), pos))
+	}
+}
+
+// replace registra que old, en la posición pos, pasó a ser item.
+func (u *indexUpdate[T]) replace(pos int, old *T, item *T) {
+	if u.writable() == nil {
+		return
+	}
+
+	for _, field := range indexedFields[T]() {
+		before, after := fieldValue(old, field), fieldValue(item, field)
+		if before == after {
+			continue
+		}
+
+		u.set(field.name, before, deletePosition(u.posting(field.name, before), pos))
+
+		positions := u.posting(field.name, after)
+		i, _ := slices.BinarySearch(positions, pos)
+		u.set(field.name, after, slices.Insert(positions, i, pos))
+	}
+}
+
+// remove registra que se sacó old de la posición pos: las posiciones
+// siguientes bajan uno.
+func (u *indexUpdate[T]) remove(pos int, old *T) {
+	if u.writable() == nil {
+		return
+	}
+
+	for _, field := range indexedFields[T]() {
+		value := fieldValue(old, field)
+		u.set(field.name, value, deletePosition(u.posting(field.name, value), pos))
+
+		for other, positions := range u.index[field.name] {
+			if i, _ := slices.BinarySearch(positions, pos); i < len(positions) {
+				positions = u.posting(field.name, other)
+				for j := i; j < len(positions); j++ {
+					positions[j]--
+				}
+			}
+		}
+	}
+}
+
+// fork devuelve un indexUpdate para una escritura anidada, que parte de lo
+// que lleva u.
+func (u *indexUpdate[T]) fork() indexUpdate[T] {
+	child := indexUpdate[T]{base: u.base}
+
+	if u.index != nil {
+		child.index = make(secondaryIndex, len(u.index))
+		for field, byValue := range u.index {
+			child.index[field] = maps.Clone(byValue)
+		}
+
+		child.owned = map[[2]string]bool{}
+	}
+
+	return child
+}
+
+// adopt se queda con lo que armó child (una escritura anidada confirmada).
+func (u *indexUpdate[T]) adopt(child indexUpdate[T]) {
+	if child.touched {
+		u.index, u.owned, u.touched = child.index, child.owned, true
+	}
+}
+
+// publish devuelve el índice de la versión nueva.
+func (u *indexUpdate[T]) publish() *lazyIndex {
+	switch {
+	case !u.touched:
+		return u.base
+	case u.index != nil:
+		return builtIndex(u.index)
+	default:
+		return &lazyIndex{}
+	}
+}
+
+func deletePosition(positions []int, pos int) []int {
+	if i, found := slices.BinarySearch(positions, pos); found {
+		return slices.Delete(positions, i, i+1)
+	}
+
+	return positions
+}
+
+func fieldValue[T any](item *T, field modelField) string {
+	return reflect.ValueOf(item).Elem().Field(field.index).String()
+}
+
+// buildIndex indexa los campos con tag index de las n entidades que da at.
+func buildIndex[T any](n int, at func(i int) (*T, error)) (secondaryIndex, error) {
+	fields := indexedFields[T]()
+	index := make(secondaryIndex, len(fields))
+
+	for _, field := range fields {
+		index[field.name] = map[string][]int{}
+	}
+
+	for i := 0; i < n; i++ {
+		item, err := at(i)
+		if err != nil {
+			return nil, err
+		}
+
+		value := reflect.ValueOf(item).Elem()
+
+		for _, field := range fields {
+			key := value.Field(field.index).String()
+			index[field.name][key] = append(index[field.name][key], i)
+		}
+	}
+
+	return index, nil
+}
+
+// positions devuelve las posiciones de value en field; ok es false si el
+// campo no tiene índice.
+func (idx secondaryIndex) positions(field string, value string) ([]int, bool) {
+	byValue, ok := idx[field]
+	if !ok {
+		return nil, false
+	}
+
+	return byValue[value], true
+}
+
+type modelField struct {
+	index int
+	name  string // nombre JSON
+}
+
+var (
+	indexedFieldsCache sync.Map // reflect.Type -> []modelField
+	stringFieldsCache  sync.Map // reflect.Type -> map[string]int
+)
+
+// indexedFields devuelve los campos de T con tag index.
+func indexedFields[T any]() []modelField {
+	t := reflect.TypeFor[T]()
+
+	if cached, ok := indexedFieldsCache.Load(t); ok {
+		return cached.([]modelField)
+	}
+
+	var fields []modelField
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if option, ok := field.Tag.Lookup("index"); !ok || option == "false" {
+			continue
+		}
+
+		if field.Type.Kind() != reflect.String {
+			panic(fmt.Sprintf("dal: index tag on non-string field %s.%s", t.Name(), field.Name))
+		}
+
+		fields = append(fields, modelField{index: i, name: jsonName(field)})
+	}
+
+	indexedFieldsCache.Store(t, fields)
+
+	return fields
+}
+
+// stringFields devuelve, por nombre JSON, la posición de los campos string de T.
+func stringFields[T any]() map[string]int {
+	t := reflect.TypeFor[T]()
+
+	if cached, ok := stringFieldsCache.Load(t); ok {
+		return cached.(map[string]int)
+	}
+
+	fields := map[string]int{}
+
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.Type.Kind() == reflect.String && field.IsExported() {
+			fields[jsonName(field)] = i
+		}
+	}
+
+	stringFieldsCache.Store(t, fields)
+
+	return fields
+}
+
+// matchesEquals aplica ListQuery.Equals. Un campo que no existe (o no es
+// string) no coincide con nada.
+func matchesEquals[T any](item *T, equals map[string]string) bool {
+	if len(equals) == 0 {
+		return true
+	}
+
+	fields := stringFields[T]()
+	value := reflect.ValueOf(item).Elem()
+
+	for name, want := range equals {
+		i, ok := fields[name]
+		if !ok || value.Field(i).String() != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lookupEquals resuelve Equals con el índice de tx, si lo tiene. Las
+// entidades devueltas todavía tienen que pasar el resto de los filtros.
+func lookupEquals[T any](tx recordTx[T], equals map[string]string) ([]ranked[T], bool, error) {
+	indexed, ok := tx.(indexLookup[T])
+	if !ok || len(equals) == 0 {
+		return nil, false, nil
+	}
+
+	// Orden fijo: la misma query siempre usa el mismo índice.
+	fields := make([]string, 0, len(equals))
+	for field := range equals {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	for _, field := range fields {
+		items, ok, err := indexed.lookup(field, equals[field])
+		if err != nil || ok {
+			return items, ok, err
+		}
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,601 @@
+package dal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/utils"
+	"project/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// DefaultJournalCompactEvery es la cantidad de escrituras en el journal que
+// disparan una compactación si JournalOptions no dice otra cosa.
+const DefaultJournalCompactEvery = 1000
+
+// JournalOptions configura un JournalDAL.
+type JournalOptions struct {
+	// CompactEvery es la cantidad de escrituras acumuladas en el journal a
+	// partir de la cual se compacta en segundo plano (<= 0 usa el default).
+	CompactEvery int
+}
+
+// JournalDAL es una implementación CRUD que nunca reescribe el archivo
+// completo: cada escritura agrega una línea al final de <path>.journal y se
+// sincroniza a disco antes de confirmarse. Al abrir, el estado se reconstruye
+// desde el último snapshot (<path>.snapshot.json) más el journal. Cada
+// CompactEvery escrituras una goroutine vuelca el estado a un snapshot nuevo
+// y descarta el journal ya incluido en él.
+//
+// El proceso que lo abre toma un lock exclusivo sobre los archivos hasta Close.
+type JournalDAL[T any] struct {
+	*collection[T]
+	store *journalStore[T]
+}
+
+// OpenJournalDAL abre (o crea) la colección guardada en path.
+func OpenJournalDAL[T any](path string, opts JournalOptions) (*JournalDAL[T], error) {
+	if opts.CompactEvery <= 0 {
+		opts.CompactEvery = DefaultJournalCompactEvery
+	}
+
+	store := &journalStore[T]{path: path, compactEvery: opts.CompactEvery}
+
+	if err := store.open(); err != nil {
+		return nil, err
+	}
+
+	return &JournalDAL[T]{collection: &collection[T]{store: store}, store: store}, nil
+}
+
+// Compact vuelca el estado actual a un snapshot y vacía el journal.
+func (d *JournalDAL[T]) Compact() error {
+	return d.store.compact()
+}
+
+// Close espera a que terminen las compactaciones en curso y libera los archivos.
+func (d *JournalDAL[T]) Close() error {
+	return d.store.close()
+}
+
+// journalEntry es una línea del journal: las operaciones de una escritura,
+// que se aplican todas o ninguna.
+type journalEntry struct {
+	Seq uint64      `json:"seq"`
+	Ops []journalOp `json:"ops"`
+	// Tx, en la escritura de una transacción sobre varias colecciones, trae
+	// las partes de todas (ver journal_transaction.go).
+	Tx []journalTxPart `json:"tx,omitempty"`
+}
+
+type journalOp struct {
+	Op   string          `json:"op"` // insert, replace o remove
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// journalSnapshot es el estado completo hasta la escritura Seq inclusive.
+type journalSnapshot struct {
+	Seq     uint64          `json:"seq"`
+	Records []snapshotEntry `json:"records"`
+}
+
+type snapshotEntry struct {
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// journalStore guarda el estado en memoria con el mismo formato que
+// memoryStore y registra cada escritura en el journal antes de confirmarla.
+type journalStore[T any] struct {
+	path         string
+	compactEvery int
+
+	mu      sync.RWMutex
+	records []memoryRecord
+	refs    *lazyIndex // índices secundarios de records
+	file    *os.File   // journal activo, abierto en modo append
+	size    int64      // tamaño confirmado del journal activo
+	seq     uint64     // última escritura confirmada
+	at      time.Time  // momento de la última escritura confirmada
+	pending int        // escrituras en el journal activo
+	broken  error      // el journal quedó en un estado desconocido: no se escribe más
+	closed  bool
+	lastTx  []journalTxPart // última transacción leída al abrir (ver redo)
+
+	compactMu  sync.Mutex
+	compacting bool
+	wg         sync.WaitGroup
+	unlock     func() error
+}
+
+func (s *journalStore[T]) journalPath() string    { return s.path + ".journal" }
+func (s *journalStore[T]) compactingPath() string { return s.path + ".journal.compacting" }
+func (s *journalStore[T]) snapshotName() string   { return s.path + ".snapshot" }
+func (s *journalStore[T]) historyPath() string    { return s.path + ".history.jsonl" }
+
+func (s *journalStore[T]) open() error {
+	unlock, err := utils.TryLockFile(s.journalPath())
+	if err != nil {
+		return fmt.Errorf("error opening journal %s: %w", s.journalPath(), err)
+	}
+
+	if err := s.recover(); err != nil {
+		unlock()
+		return err
+	}
+
+	file, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err == nil {
+		err = utils.SyncDir(filepath.Dir(s.journalPath()))
+	}
+
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		unlock()
+		return fmt.Errorf("error opening journal %s: %w", s.journalPath(), err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		unlock()
+		return fmt.Errorf("error opening journal %s: %w", s.journalPath(), err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.unlock = unlock
+
+	if s.seq > 0 {
+		s.at = info.ModTime().UTC()
+	}
+
+	return nil
+}
+
+// recover reconstruye el estado: snapshot, luego el journal que quedó a
+// medio compactar (si lo hay) y por último el journal activo. Las entradas
+// que el snapshot ya incluye se saltean por número de secuencia.
+func (s *journalStore[T]) recover() error {
+	var snap journalSnapshot
+
+	if err := utils.ReadJSON(s.snapshotName(), &snap); err != nil {
+		return fmt.Errorf("error reading journal snapshot: %w", err)
+	}
+
+	s.seq = snap.Seq
+	s.records = make([]memoryRecord, 0, len(snap.Records))
+	s.refs = &lazyIndex{}
+
+	for _, rec := range snap.Records {
+		s.records = append(s.records, memoryRecord{id: rec.ID, raw: rec.Data})
+	}
+
+	if _, err := s.replay(s.compactingPath()); err != nil {
+		return err
+	}
+
+	pending, err := s.replay(s.journalPath())
+	if err != nil {
+		return err
+	}
+
+	s.pending = pending
+
+	return nil
+}
+
+// replay aplica las entradas de un journal y devuelve cuántas tiene. Una
+// última línea incompleta o ilegible es una escritura que nunca se confirmó
+// (el proceso murió en el medio): se descarta y se recorta del archivo.
+func (s *journalStore[T]) replay(path string) (int, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("error opening journal %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	var (
+		offset  int64
+		entries int
+	)
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+
+		if readErr != nil && readErr != io.EOF {
+			return 0, fmt.Errorf("error reading journal %s: %w", path, readErr)
+		}
+
+		if len(line) == 0 {
+			break
+		}
+
+		var entry journalEntry
+
+		complete := line[len(line)-1] == '\n'
+		decodeErr := json.Unmarshal(bytes.TrimSpace(line), &entry)
+
+		if !complete || decodeErr != nil {
+			if _, err := reader.Peek(1); err == nil {
+				// Hay más datos después: no es una cola cortada sino un journal dañado.
+				return 0, fmt.Errorf("corrupt journal %s at offset %d: %v", path, offset, decodeErr)
+			}
+
+			if err := file.Truncate(offset); err != nil {
+				return 0, fmt.Errorf("error truncating journal %s: %w", path, err)
+			}
+
+			if err := file.Sync(); err != nil {
+				return 0, fmt.Errorf("error truncating journal %s: %w", path, err)
+			}
+
+			break
+		}
+
+		offset += int64(len(line))
+		entries++
+
+		if entry.Tx != nil {
+			s.lastTx = entry.Tx
+		}
+
+		if entry.Seq <= s.seq {
+			continue // ya está en el snapshot
+		}
+
+		if err := s.apply(entry); err != nil {
+			return 0, fmt.Errorf("corrupt journal %s at seq %d: %w", path, entry.Seq, err)
+		}
+
+		s.seq = entry.Seq
+	}
+
+	return entries, nil
+}
+
+func (s *journalStore[T]) apply(entry journalEntry) error {
+	tx := &memoryTx[T]{records: s.records}
+
+	for _, op := range entry.Ops {
+		rec := memoryRecord{id: op.ID, raw: op.Data}
+
+		switch op.Op {
+		case "insert":
+			tx.records = append(tx.records, rec)
+		case "replace":
+			i := tx.index(op.ID)
+			if i < 0 {
+				return fmt.Errorf("replace of unknown entity %s", op.ID)
+			}
+			tx.records[i] = rec
+		case "remove":
+			tx.remove(op.ID)
+		default:
+			return fmt.Errorf("unknown operation %q", op.Op)
+		}
+	}
+
+	s.records = tx.records
+	s.refs = &lazyIndex{}
+
+	return nil
+}
+
+func (s *journalStore[T]) view(fn func(tx recordTx[T]) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(&memoryTx[T]{records: s.records, refs: s.refs})
+}
+
+func (s *journalStore[T]) update(fn func(tx recordTx[T]) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("journal %s is closed", s.journalPath())
+	}
+
+	if s.broken != nil {
+		return s.broken
+	}
+
+	// Igual que memoryStore: se trabaja sobre una copia y sólo se confirma
+	// si fn no falla y la entrada llegó a disco.
+	tx := &journalTx[T]{memoryTx: memoryTx[T]{records: append([]memoryRecord(nil), s.records...), indexes: indexUpdate[T]{base: s.refs}}}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	// El historial va antes que el journal (ver historyBuffer).
+	if err := historyFor(s.historyPath()).save(tx.archived, tx.forgotten); err != nil {
+		return err
+	}
+
+	if err := s.append(tx.ops, nil); err != nil {
+		return err
+	}
+
+	s.records = tx.records
+	s.refs = tx.indexes.publish()
+	s.compactIfDue()
+
+	return nil
+}
+
+// compactIfDue lanza una compactación en segundo plano si el journal activo
+// ya juntó CompactEvery escrituras. Se llama con s.mu tomado.
+func (s *journalStore[T]) compactIfDue() {
+	if s.pending >= s.compactEvery && !s.compacting {
+		s.compacting = true
+		s.wg.Add(1)
+
+		go func() {
+			defer s.wg.Done()
+
+			s.compactMu.Lock()
+			err := s.writeSnapshot()
+			s.compactMu.Unlock()
+
+			if err != nil && logger.Log != nil {
+				logger.Log.Error("journal compaction failed", zap.String("journal", s.journalPath()), zap.Error(err))
+			}
+
+			s.mu.Lock()
+			s.compacting = false
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *journalStore[T]) history(id string) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return historyFor(s.historyPath()).read(id)
+}
+
+func (s *journalStore[T]) lastChange() (dao.Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return dao.Change{Token: strconv.FormatUint(s.seq, 10), At: s.at}, nil
+}
+
+// append escribe una entrada (tx: las partes de la transacción, si la hay) y
+// la sincroniza a disco. Se llama con s.mu tomado.
+func (s *journalStore[T]) append(ops []journalOp, tx []journalTxPart) error {
+	line, err := json.Marshal(journalEntry{Seq: s.seq + 1, Ops: ops, Tx: tx})
+	if err != nil {
+		return fmt.Errorf("error encoding journal entry: %w", err)
+	}
+
+	line = append(line, '\n')
+
+	_, err = s.file.Write(line)
+	if err == nil {
+		err = s.file.Sync()
+	}
+
+	if err != nil {
+		// Se saca lo que haya quedado escrito a medias para que la próxima
+		// entrada no quede pegada a basura.
+		if tErr := s.file.Truncate(s.size); tErr != nil {
+			s.broken = fmt.Errorf("journal %s left in an unknown state: %w", s.journalPath(), tErr)
+		}
+
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+
+	s.size += int64(len(line))
+	s.seq++
+	s.at = time.Now().UTC()
+	s.pending++
+
+	return nil
+}
+
+// compact compacta a pedido. Las compactaciones nunca corren en paralelo.
+func (s *journalStore[T]) compact() error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	s.mu.RLock()
+	closed := s.closed
+	s.mu.RUnlock()
+
+	if closed {
+		return fmt.Errorf("journal %s is closed", s.journalPath())
+	}
+
+	return s.writeSnapshot()
+}
+
+// writeSnapshot rota el journal activo, escribe un snapshot con el estado en
+// ese momento y recién entonces borra el journal rotado. Si el proceso muere
+// en el medio, recover encuentra el journal rotado y lo vuelve a aplicar.
+// Se llama con s.compactMu tomado.
+func (s *journalStore[T]) writeSnapshot() error {
+	s.mu.Lock()
+
+	// Con el journal roto la memoria puede tener escrituras que el journal
+	// todavía no tiene (ver commitJournalTransaction): no se vuelcan.
+	if s.broken != nil {
+		s.mu.Unlock()
+		return s.broken
+	}
+
+	records, seq := s.records, s.seq
+
+	// Si quedó un journal rotado de una compactación fallida, no se pisa:
+	// este snapshot lo incluye y el journal activo sigue como está.
+	_, statErr := os.Stat(s.compactingPath())
+	leftover := statErr == nil
+
+	if !leftover {
+		if err := s.rotate(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+
+	s.mu.Unlock()
+
+	snap := journalSnapshot{Seq: seq, Records: make([]snapshotEntry, 0, len(records))}
+	for _, rec := range records {
+		snap.Records = append(snap.Records, snapshotEntry{ID: rec.id, Data: rec.raw})
+	}
+
+	if err := utils.WriteJSON(s.snapshotName(), snap); err != nil {
+		return fmt.Errorf("error writing journal snapshot: %w", err)
+	}
+
+	if err := os.Remove(s.compactingPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing compacted journal: %w", err)
+	}
+
+	return utils.SyncDir(filepath.Dir(s.journalPath()))
+}
+
+// rotate mueve el journal activo a <path>.journal.compacting y abre uno
+// nuevo vacío. Se llama con s.mu tomado.
+func (s *journalStore[T]) rotate() error {
+	if err := os.Rename(s.journalPath(), s.compactingPath()); err != nil {
+		return fmt.Errorf("error rotating journal: %w", err)
+	}
+
+	file, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0644)
+	if err == nil {
+		err = utils.SyncDir(filepath.Dir(s.journalPath()))
+	}
+
+	if err != nil {
+		if file != nil {
+			file.Close()
+			os.Remove(s.journalPath())
+		}
+
+		if rErr := os.Rename(s.compactingPath(), s.journalPath()); rErr != nil {
+			s.broken = fmt.Errorf("journal %s left in an unknown state: %w", s.journalPath(), rErr)
+		}
+
+		return fmt.Errorf("error rotating journal: %w", err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.size = 0
+	s.pending = 0
+
+	return nil
+}
+
+func (s *journalStore[T]) close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	// Una compactación ya lanzada termina antes de soltar los archivos.
+	s.wg.Wait()
+
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	return errors.Join(s.file.Close(), s.unlock())
+}
+
+// journalTx es un memoryTx que además anota cada operación para el journal.
+type journalTx[T any] struct {
+	memoryTx[T]
+	ops []journalOp
+}
+
+func (tx *journalTx[T]) savepoint() (recordTx[T], func(keep bool) error, error) {
+	child := &journalTx[T]{memoryTx: memoryTx[T]{records: append([]memoryRecord(nil), tx.records...), indexes: tx.indexes.fork()}}
+
+	return child, func(keep bool) error {
+		if keep {
+			tx.records = child.records
+			tx.indexes.adopt(child.indexes)
+			tx.ops = append(tx.ops, child.ops...)
+			tx.keep(&child.historyBuffer)
+		}
+
+		return nil
+	}, nil
+}
+
+func (tx *journalTx[T]) insert(item *T) error {
+	rec, err := encodeRecord(item)
+	if err != nil {
+		return err
+	}
+
+	tx.indexes.insert(len(tx.records), item)
+	tx.records = append(tx.records, rec)
+	tx.ops = append(tx.ops, journalOp{Op: "insert", ID: rec.id, Data: rec.raw})
+
+	return nil
+}
+
+func (tx *journalTx[T]) replace(id string, item *T) error {
+	i := tx.index(id)
+	if i < 0 {
+		return dao.Errorf(dao.ErrNotFound, "Can't find entity with ID %v", id)
+	}
+
+	rec, err := encodeRecord(item)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.reindex(i, item); err != nil {
+		return err
+	}
+
+	rec.id = id
+	tx.records[i] = rec
+	tx.ops = append(tx.ops, journalOp{Op: "replace", ID: id, Data: rec.raw})
+
+	return nil
+}
+
+func (tx *journalTx[T]) remove(id string) (bool, error) {
+	found, err := tx.memoryTx.remove(id)
+
+	if found {
+		tx.ops = append(tx.ops, journalOp{Op: "remove", ID: id})
+	}
+
+	return found, err
+}
@@ -0,0 +1,284 @@
+package dal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"project/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Una transacción del driver journal agrega una línea al journal de cada
+// colección que cambia, y cada línea trae las partes de todas. La primera que
+// llega a disco confirma la transacción: si el proceso muere (o falla una
+// escritura) antes de agregar las demás, al abrir se completan desde esa
+// línea (ver recoverJournalTransaction).
+
+// journalTxPart es lo que una transacción escribe en una colección.
+type journalTxPart struct {
+	Collection string      `json:"collection"` // nombre base del archivo (Product, Seller...)
+	Seq        uint64      `json:"seq"`
+	Ops        []journalOp `json:"ops"`
+}
+
+// begin toma el lock que pide access para una transacción y devuelve su
+// vista de la colección: una copia de trabajo para escribir, o lo publicado
+// para leer. Sin acceso no toma nada y devuelve nil. Si falla, no queda nada
+// tomado; si no, el coordinador suelta el lock con end.
+func (s *journalStore[T]) begin(access txAccess) (*journalTx[T], error) {
+	switch access {
+	case noAccess:
+		return nil, nil
+	case readAccess:
+		s.mu.RLock()
+		return &journalTx[T]{memoryTx: memoryTx[T]{records: s.records, refs: s.refs}}, nil
+	}
+
+	s.mu.Lock()
+
+	if s.closed {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("journal %s is closed", s.journalPath())
+	}
+
+	if s.broken != nil {
+		s.mu.Unlock()
+		return nil, s.broken
+	}
+
+	return &journalTx[T]{memoryTx: memoryTx[T]{records: append([]memoryRecord(nil), s.records...), indexes: indexUpdate[T]{base: s.refs}}}, nil
+}
+
+// end suelta el lock que tomó begin.
+func (s *journalStore[T]) end(access txAccess) {
+	switch access {
+	case writeAccess:
+		s.mu.Unlock()
+	case readAccess:
+		s.mu.RUnlock()
+	}
+}
+
+// txStore ve la colección desde tx. Se usa con s.mu tomado por begin.
+func (s *journalStore[T]) txStore(tx *journalTx[T]) *txStore[T] {
+	return &txStore[T]{
+		tx: tx,
+		change: func() (dao.Change, error) {
+			return dao.Change{Token: strconv.FormatUint(s.seq, 10), At: s.at}, nil
+		},
+		archived: func(id string) ([][]byte, error) {
+			versions, err := historyFor(s.historyPath()).read(id)
+			return pendingHistory(versions, tx.archived, id), err
+		},
+	}
+}
+
+// journalCommit es la parte de una transacción en una colección, vista sin
+// su tipo.
+type journalCommit interface {
+	dirty() bool
+	part() journalTxPart
+	saveHistory() error
+	append(tx []journalTxPart) error
+	publish()
+	breakWith(err error)
+}
+
+type journalTxn[T any] struct {
+	store *journalStore[T]
+	tx    *journalTx[T]
+}
+
+func (t journalTxn[T]) dirty() bool { return t.tx != nil && len(t.tx.ops) > 0 }
+
+func (t journalTxn[T]) part() journalTxPart {
+	return journalTxPart{Collection: filepath.Base(t.store.path), Seq: t.store.seq + 1, Ops: t.tx.ops}
+}
+
+func (t journalTxn[T]) saveHistory() error {
+	return historyFor(t.store.historyPath()).save(t.tx.archived, t.tx.forgotten)
+}
+
+func (t journalTxn[T]) append(tx []journalTxPart) error {
+	return t.store.append(t.tx.ops, tx)
+}
+
+func (t journalTxn[T]) publish() {
+	t.store.records = t.tx.records
+	t.store.refs = t.tx.indexes.publish()
+	t.store.compactIfDue()
+}
+
+func (t journalTxn[T]) breakWith(err error) {
+	if t.store.broken == nil {
+		t.store.broken = err
+	}
+}
+
+// journalTransaction implementa Storage.TransactIn para el driver journal.
+func journalTransaction(
+	products *journalStore[models.Product],
+	sellers *journalStore[models.Seller],
+	categories *journalStore[models.Category],
+	images *journalStore[models.Image],
+) transactFunc {
+	return func(scope txScope, fn func(uow dao.UnitOfWork) error) error {
+		ptx, err := products.begin(scope.products)
+		if err != nil {
+			return unavailable(err)
+		}
+		defer products.end(scope.products)
+
+		stx, err := sellers.begin(scope.sellers)
+		if err != nil {
+			return unavailable(err)
+		}
+		defer sellers.end(scope.sellers)
+
+		ctx, err := categories.begin(scope.categories)
+		if err != nil {
+			return unavailable(err)
+		}
+		defer categories.end(scope.categories)
+
+		itx, err := images.begin(scope.images)
+		if err != nil {
+			return unavailable(err)
+		}
+		defer images.end(scope.images)
+
+		uow := newUnitOfWork(scope, products.txStore(ptx), sellers.txStore(stx), categories.txStore(ctx), images.txStore(itx))
+
+		if err := fn(uow); err != nil {
+			return err
+		}
+
+		return unavailable(commitJournalTransaction([]journalCommit{
+			journalTxn[models.Product]{products, ptx},
+			journalTxn[models.Seller]{sellers, stx},
+			journalTxn[models.Category]{categories, ctx},
+			journalTxn[models.Image]{images, itx},
+		}))
+	}
+}
+
+// commitJournalTransaction escribe las partes que cambiaron. Se llama con los
+// locks de todas las colecciones tomados.
+func commitJournalTransaction(txns []journalCommit) error {
+	var dirty []journalCommit
+
+	for _, t := range txns {
+		if t.dirty() {
+			dirty = append(dirty, t)
+		}
+	}
+
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	// Con una sola colección la línea del journal ya es atómica.
+	var parts []journalTxPart
+
+	if len(dirty) > 1 {
+		for _, t := range dirty {
+			parts = append(parts, t.part())
+		}
+	}
+
+	// El historial va antes que el journal (ver historyBuffer).
+	for _, t := range dirty {
+		if err := t.saveHistory(); err != nil {
+			return err
+		}
+	}
+
+	if err := dirty[0].append(parts); err != nil {
+		return err
+	}
+
+	// Desde acá la transacción está confirmada. Si falla otra línea, la
+	// memoria se actualiza igual y las colecciones dejan de escribir hasta
+	// que al reabrir se completen los journals.
+	for _, t := range dirty[1:] {
+		if err := t.append(parts); err != nil {
+			broken := fmt.Errorf("journal transaction only partially written, reopen the storage to complete it: %w", err)
+
+			for _, t := range dirty {
+				t.breakWith(broken)
+			}
+
+			if logger.Log != nil {
+				logger.Log.Error("journal transaction only partially written", zap.Error(err))
+			}
+
+			break
+		}
+	}
+
+	for _, t := range dirty {
+		t.publish()
+	}
+
+	return nil
+}
+
+// journalRecovery es un journalStore visto sin su tipo, para completar
+// transacciones al abrir.
+type journalRecovery interface {
+	collectionName() string
+	pendingTx() []journalTxPart
+	redo(part journalTxPart) error
+}
+
+func (s *journalStore[T]) collectionName() string     { return filepath.Base(s.path) }
+func (s *journalStore[T]) pendingTx() []journalTxPart { return s.lastTx }
+
+// redo aplica part si es la escritura que le sigue a la última que tiene la
+// colección; si no, ya la tiene (o la transacción es vieja) y no hace nada.
+func (s *journalStore[T]) redo(part journalTxPart) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seq+1 != part.Seq {
+		return nil
+	}
+
+	if err := s.apply(journalEntry{Seq: part.Seq, Ops: part.Ops}); err != nil {
+		return fmt.Errorf("corrupt journal transaction for %s at seq %d: %w", part.Collection, part.Seq, err)
+	}
+
+	if err := s.append(part.Ops, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// recoverJournalTransaction completa la última transacción que alguna de las
+// colecciones tiene en su journal y a otras les falta.
+func recoverJournalTransaction(stores ...journalRecovery) error {
+	byName := make(map[string]journalRecovery, len(stores))
+	for _, store := range stores {
+		byName[store.collectionName()] = store
+	}
+
+	for _, store := range stores {
+		for _, part := range store.pendingTx() {
+			target, ok := byName[part.Collection]
+			if !ok {
+				return fmt.Errorf("journal transaction references unknown collection %q", part.Collection)
+			}
+
+			if err := target.redo(part); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
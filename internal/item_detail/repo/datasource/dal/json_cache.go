@@ -0,0 +1,99 @@
+package dal
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_json_cache_hits_total",
+		Help: "Lecturas del storage JSON resueltas con la copia en memoria.",
+	}, []string{"collection"})
+
+	cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_json_cache_misses_total",
+		Help: "Lecturas del storage JSON que tuvieron que decodificar el archivo.",
+	}, []string{"collection"})
+)
+
+// fileState es lo que comparten todas las instancias de CrudDAL que apuntan al
+// mismo archivo: el RWMutex que serializa las escrituras (las lecturas corren
+// en paralelo; archivos distintos no se bloquean entre sí) y la última copia
+// decodificada del archivo. Entre procesos la coordinación la da utils.LockFile.
+type fileState struct {
+	mu sync.RWMutex
+
+	cacheMu  sync.Mutex
+	snapshot any // *jsonSnapshot[T]
+}
+
+var fileStates sync.Map
+
+func stateFor(filename string) *fileState {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		key = filename
+	}
+
+	state, _ := fileStates.LoadOrStore(key, &fileState{})
+
+	return state.(*fileState)
+}
+
+// jsonSnapshot es el contenido decodificado de un archivo, indexado por ID,
+// junto con la info del archivo del que salió. Es inmutable: las escrituras
+// arman un snapshot nuevo en lugar de tocar éste.
+type jsonSnapshot[T any] struct {
+	info  os.FileInfo // nil si el archivo no existía
+	data  []T
+	index map[string]int
+	refs  *lazyIndex // índices secundarios de data
+}
+
+func newSnapshot[T any](info os.FileInfo, data []T) *jsonSnapshot[T] {
+	index := make(map[string]int, len(data))
+
+	for i := range data {
+		// Ante IDs repetidos gana el primero, igual que el recorrido lineal.
+		if _, ok := index[entityID(&data[i])]; !ok {
+			index[entityID(&data[i])] = i
+		}
+	}
+
+	return &jsonSnapshot[T]{info: info, data: data, index: index, refs: &lazyIndex{}}
+}
+
+// fresh indica si el snapshot sigue representando al archivo descripto por
+// info. Como WriteJSON reemplaza el archivo con un rename, cualquier escritura
+// (de este u otro proceso) cambia el inodo además del mtime y el tamaño.
+func (s *jsonSnapshot[T]) fresh(info os.FileInfo) bool {
+	if s.info == nil || info == nil {
+		return s.info == nil && info == nil
+	}
+
+	return os.SameFile(s.info, info) &&
+		s.info.ModTime().Equal(info.ModTime()) &&
+		s.info.Size() == info.Size()
+}
+
+// cached devuelve el snapshot guardado si es del tipo pedido.
+func cached[T any](state *fileState) *jsonSnapshot[T] {
+	state.cacheMu.Lock()
+	defer state.cacheMu.Unlock()
+
+	snap, _ := state.snapshot.(*jsonSnapshot[T])
+
+	return snap
+}
+
+func storeSnapshot[T any](state *fileState, snap *jsonSnapshot[T]) {
+	state.cacheMu.Lock()
+	defer state.cacheMu.Unlock()
+
+	state.snapshot = snap
+}
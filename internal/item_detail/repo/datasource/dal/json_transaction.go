@@ -0,0 +1,525 @@
+package dal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/utils"
+	models "project/pkg"
+	"strings"
+)
+
+// Una transacción del driver json reemplaza varios archivos, y cada rename es
+// atómico por separado pero no en conjunto. Antes de tocar el primero se
+// escribe un log de deshacer (transaction.undo.<primer archivo>.json) con el
+// contenido anterior de los archivos que cambian; se borra recién cuando
+// están todos escritos. Si el log existe, la transacción no terminó y se
+// deshace: al abrir el storage, al empezar otra transacción que use alguno
+// de sus archivos o antes de una escritura. Cada transacción tiene su log
+// porque dos con alcances distintos pueden confirmar a la vez.
+//
+// Los historiales (<archivo>.history.jsonl) también cambian al confirmar: el
+// log guarda hasta dónde llegaba cada uno, para cortar lo que se agregó, y si
+// la transacción purga versiones, un hard link al historial anterior
+// (<log>.<archivo>.bak), que prune no toca porque renombra uno nuevo encima.
+
+// jsonCollections son los archivos de un DATA_DIR en el orden de los locks
+// (ver transaction.go).
+var jsonCollections = []string{"Product", "Seller", "Category", "Image"}
+
+// undoLogName es el prefijo de los logs de deshacer dentro del directorio
+// (WriteJSON agrega .json).
+const undoLogName = "transaction.undo"
+
+// allCollections es el alcance con el que se deshacen las transacciones.
+var allCollections = txScope{writeAccess, writeAccess, writeAccess, writeAccess}
+
+type undoEntry struct {
+	File    string          `json:"file"`              // nombre del archivo, sin .json
+	Data    json.RawMessage `json:"data"`              // contenido anterior
+	History *historyUndo    `json:"history,omitempty"` // cómo estaba su historial
+}
+
+// historyUndo es el estado de un historial antes de la transacción.
+type historyUndo struct {
+	Size   int64  `json:"size"`             // tamaño anterior (0 si no existía)
+	Backup string `json:"backup,omitempty"` // copia anterior, si la transacción purga
+}
+
+// historyBackupSuffix termina el nombre de las copias de los historiales.
+const historyBackupSuffix = ".bak"
+
+// jsonPart es un archivo tomado por una transacción, visto sin su tipo.
+type jsonPart interface {
+	name() string
+	dirty() bool
+	prunes() bool // la escritura borra versiones del historial
+	previous() (json.RawMessage, error)
+	write() error
+}
+
+// jsonTxn es la parte de una transacción que toca el archivo de un jsonStore.
+type jsonTxn[T any] struct {
+	store *jsonStore[T]
+	state *fileState
+	tx    *jsonTx[T]
+	snap  *jsonSnapshot[T]
+}
+
+// begin lee el archivo para la transacción, según access. Sin acceso no lee
+// nada y devuelve nil. Se llama con sus locks tomados.
+func (s *jsonStore[T]) begin(access txAccess) (*jsonTxn[T], error) {
+	state := stateFor(s.filename)
+
+	var (
+		snap *jsonSnapshot[T]
+		err  error
+	)
+
+	switch access {
+	case noAccess:
+		return nil, nil
+	case readAccess:
+		if snap, err = s.load(state); err != nil {
+			return nil, fmt.Errorf("error reading JSON: %w", err)
+		}
+	default:
+		if snap, err = s.readForWrite(state); err != nil {
+			return nil, err
+		}
+	}
+
+	return &jsonTxn[T]{store: s, state: state, tx: newJSONTx(snap), snap: snap}, nil
+}
+
+func (t *jsonTxn[T]) name() string { return filepath.Base(t.store.filename) }
+func (t *jsonTxn[T]) dirty() bool  { return t != nil && t.tx.dirty }
+func (t *jsonTxn[T]) prunes() bool { return len(t.tx.forgotten) > 0 }
+
+func (t *jsonTxn[T]) previous() (json.RawMessage, error) {
+	raw, err := json.Marshal(t.snap.data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding transaction log: %w", err)
+	}
+
+	return raw, nil
+}
+
+func (t *jsonTxn[T]) write() error {
+	return t.store.write(t.state, t.tx)
+}
+
+func (t *jsonTxn[T]) txStore() *txStore[T] {
+	if t == nil {
+		return nil
+	}
+
+	return &txStore[T]{
+		tx:     t.tx,
+		change: t.store.lastChange,
+		archived: func(id string) ([][]byte, error) {
+			versions, err := historyFor(t.store.filename + ".history.jsonl").read(id)
+			return pendingHistory(versions, t.tx.archived, id), err
+		},
+	}
+}
+
+// jsonTransaction implementa Storage.TransactIn para el driver json.
+func jsonTransaction(dir string) transactFunc {
+	return func(scope txScope, fn func(uow dao.UnitOfWork) error) error {
+		release, err := lockPendingFree(dir, scope)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		products, err := (&jsonStore[models.Product]{filename: filepath.Join(dir, "Product")}).begin(scope.products)
+		if err != nil {
+			return unavailable(err)
+		}
+		sellers, err := (&jsonStore[models.Seller]{filename: filepath.Join(dir, "Seller")}).begin(scope.sellers)
+		if err != nil {
+			return unavailable(err)
+		}
+		categories, err := (&jsonStore[models.Category]{filename: filepath.Join(dir, "Category")}).begin(scope.categories)
+		if err != nil {
+			return unavailable(err)
+		}
+		images, err := (&jsonStore[models.Image]{filename: filepath.Join(dir, "Image")}).begin(scope.images)
+		if err != nil {
+			return unavailable(err)
+		}
+
+		if err := fn(newUnitOfWork(scope, products.txStore(), sellers.txStore(), categories.txStore(), images.txStore())); err != nil {
+			return err
+		}
+
+		if err := commitJSONTransaction(dir, []jsonPart{products, sellers, categories, images}); err != nil {
+			return dao.Errorf(dao.ErrStorageUnavailable, "error committing JSON transaction: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// lockPendingFree toma los archivos de scope (ver lockJSONCollections) sin
+// que quede a medias una transacción que los tocó: si hay un log de deshacer
+// que nombra alguno, se sueltan, se deshace con todos los locks y se vuelve a
+// empezar. Los logs de otros archivos pueden ser de una transacción en curso
+// y no se tocan.
+func lockPendingFree(dir string, scope txScope) (release func(), err error) {
+	for {
+		release, err := lockJSONCollections(dir, scope)
+		if err != nil {
+			return nil, dao.Errorf(dao.ErrStorageUnavailable, "error starting JSON transaction: %w", err)
+		}
+
+		pending, err := pendingJSONTransaction(dir, scope)
+		if err == nil && !pending {
+			return release, nil
+		}
+
+		release()
+
+		if err == nil {
+			err = recoverJSONTransaction(dir)
+		}
+
+		if err != nil {
+			return nil, dao.Errorf(dao.ErrStorageUnavailable, "error recovering JSON transaction: %w", err)
+		}
+	}
+}
+
+// commitJSONTransaction escribe los archivos que cambiaron. Se llama con los
+// locks de todos tomados.
+func commitJSONTransaction(dir string, parts []jsonPart) error {
+	var dirty []jsonPart
+
+	for _, part := range parts {
+		if part.dirty() {
+			dirty = append(dirty, part)
+		}
+	}
+
+	// Un solo archivo ya se reemplaza de forma atómica.
+	if len(dirty) <= 1 {
+		for _, part := range dirty {
+			return part.write()
+		}
+
+		return nil
+	}
+
+	undo := make([]undoEntry, 0, len(dirty))
+	logName := undoLogName + "." + dirty[0].name()
+
+	for _, part := range dirty {
+		raw, err := part.previous()
+		if err != nil {
+			return err
+		}
+
+		history, err := saveHistoryState(dir, logName, part)
+		if err != nil {
+			removeHistoryBackups(dir, undo)
+			return err
+		}
+
+		undo = append(undo, undoEntry{File: part.name(), Data: raw, History: history})
+	}
+
+	if err := utils.WriteJSON(filepath.Join(dir, logName), undo); err != nil {
+		removeHistoryBackups(dir, undo)
+		return fmt.Errorf("error writing transaction log: %w", err)
+	}
+
+	for _, part := range dirty {
+		if err := part.write(); err != nil {
+			return abortJSONTransaction(dir, logName, err)
+		}
+	}
+
+	// Borrar el log es lo que confirma la transacción.
+	if err := os.Remove(filepath.Join(dir, logName+".json")); err != nil {
+		return abortJSONTransaction(dir, logName, fmt.Errorf("error removing transaction log: %w", err))
+	}
+
+	if err := utils.SyncDir(dir); err != nil {
+		return abortJSONTransaction(dir, logName, err)
+	}
+
+	// Las copias ya no hacen falta; si alguna queda, la borra la recuperación.
+	removeHistoryBackups(dir, undo)
+
+	return nil
+}
+
+// saveHistoryState anota el tamaño del historial de part y, si la escritura
+// va a purgar versiones, lo guarda con un hard link.
+func saveHistoryState(dir string, logName string, part jsonPart) (*historyUndo, error) {
+	path := filepath.Join(dir, part.name()+".history.jsonl")
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &historyUndo{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading history %s: %w", path, err)
+	}
+
+	history := &historyUndo{Size: info.Size()}
+
+	if part.prunes() {
+		history.Backup = logName + "." + part.name() + historyBackupSuffix
+
+		backup := filepath.Join(dir, history.Backup)
+		os.Remove(backup)
+
+		if err := os.Link(path, backup); err != nil {
+			return nil, fmt.Errorf("error saving history %s: %w", path, err)
+		}
+	}
+
+	return history, nil
+}
+
+func removeHistoryBackups(dir string, undo []undoEntry) {
+	for _, entry := range undo {
+		if entry.History != nil && entry.History.Backup != "" {
+			os.Remove(filepath.Join(dir, entry.History.Backup))
+		}
+	}
+}
+
+// restoreHistory vuelve el historial de entry a como estaba antes de la
+// transacción: la copia, si la hay, y si no lo corta en el tamaño anterior.
+func restoreHistory(dir string, entry undoEntry) error {
+	if entry.History == nil {
+		return nil
+	}
+
+	path := filepath.Join(dir, entry.File+".history.jsonl")
+
+	// Lo que se lea después rearma el índice (ver historyFile.index).
+	defer historyFor(path).invalidate()
+
+	if entry.History.Backup != "" {
+		err := os.Rename(filepath.Join(dir, entry.History.Backup), path)
+		if err == nil {
+			return nil
+		}
+
+		// Sin la copia ya se restauró antes: queda cortarlo.
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error restoring history %s: %w", path, err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("error restoring history %s: %w", path, err)
+	}
+
+	if info.Size() <= entry.History.Size {
+		return nil
+	}
+
+	if err := os.Truncate(path, entry.History.Size); err != nil {
+		return fmt.Errorf("error restoring history %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// abortJSONTransaction deshace una confirmación que falló a mitad de camino.
+// Si tampoco se puede deshacer, el log queda y se deshace más adelante.
+func abortJSONTransaction(dir string, logName string, cause error) error {
+	if err := rollbackUndoLog(dir, logName); err != nil {
+		return fmt.Errorf("%w (rollback pending: %v)", cause, err)
+	}
+
+	return cause
+}
+
+// undoLogs devuelve los nombres (sin .json) de los logs de deshacer de dir.
+func undoLogs(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, undoLogName+"*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+
+	return names, nil
+}
+
+// pendingJSONTransaction indica si algún log de deshacer de dir nombra un
+// archivo que scope toma. Se llama con esos locks tomados: una transacción
+// en curso no puede tener uno de esos archivos, así que el log es de una que
+// no terminó.
+func pendingJSONTransaction(dir string, scope txScope) (bool, error) {
+	logs, err := undoLogs(dir)
+	if err != nil || len(logs) == 0 {
+		return false, err
+	}
+
+	taken := map[string]bool{}
+	for i, access := range scope.inLockOrder() {
+		taken[jsonCollections[i]] = access != noAccess
+	}
+
+	for _, name := range logs {
+		var undo []undoEntry
+
+		if err := utils.ReadJSON(filepath.Join(dir, name), &undo); err != nil {
+			return false, fmt.Errorf("error reading transaction log: %w", err)
+		}
+
+		for _, entry := range undo {
+			if taken[entry.File] {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// rollbackJSONTransaction deshace todas las transacciones que dejaron su log
+// en dir y borra las copias de historiales que quedaron sin log (de una
+// transacción que terminó antes de borrarlas). Sin logs no hace nada. Se
+// llama con los locks de todos los archivos tomados.
+func rollbackJSONTransaction(dir string) error {
+	logs, err := undoLogs(dir)
+	if err != nil {
+		return fmt.Errorf("error reading transaction log: %w", err)
+	}
+
+	for _, name := range logs {
+		if err := rollbackUndoLog(dir, name); err != nil {
+			return err
+		}
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, undoLogName+"*"+historyBackupSuffix))
+	if err != nil {
+		return fmt.Errorf("error reading transaction log: %w", err)
+	}
+
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
+
+	return nil
+}
+
+// rollbackUndoLog vuelve los archivos al contenido guardado en el log name y
+// lo borra. Se llama con los locks de esos archivos tomados.
+func rollbackUndoLog(dir string, name string) error {
+	var undo []undoEntry
+
+	if err := utils.ReadJSON(filepath.Join(dir, name), &undo); err != nil {
+		return fmt.Errorf("error reading transaction log: %w", err)
+	}
+
+	for _, entry := range undo {
+		if err := utils.WriteJSON(filepath.Join(dir, entry.File), entry.Data); err != nil {
+			return err
+		}
+
+		if err := restoreHistory(dir, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dir, name+".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing transaction log: %w", err)
+	}
+
+	return utils.SyncDir(dir)
+}
+
+// recoverJSONTransaction deshace, si quedó alguna, las transacciones
+// interrumpidas en dir. Toma los locks de todos los archivos.
+func recoverJSONTransaction(dir string) error {
+	release, err := lockJSONCollections(dir, allCollections)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return rollbackJSONTransaction(dir)
+}
+
+// settle deshace una transacción interrumpida antes de escribir. Sólo se
+// llama sin locks tomados: recoverJSONTransaction los necesita todos.
+func (s *jsonStore[T]) settle() error {
+	dir := filepath.Dir(s.filename)
+
+	if logs, err := undoLogs(dir); err == nil && len(logs) == 0 {
+		return nil
+	}
+
+	if err := recoverJSONTransaction(dir); err != nil {
+		return fmt.Errorf("error recovering JSON transaction: %w", err)
+	}
+
+	return nil
+}
+
+// lockJSONCollections toma, en orden, el lock en memoria y el lock entre
+// procesos de cada archivo de dir que scope usa: exclusivos para escribir y
+// compartidos para leer. release los suelta en orden inverso.
+func lockJSONCollections(dir string, scope txScope) (release func(), err error) {
+	var unlocks []func()
+
+	release = func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	for i, access := range scope.inLockOrder() {
+		if access == noAccess {
+			continue
+		}
+
+		filename := filepath.Join(dir, jsonCollections[i])
+		state := stateFor(filename)
+		exclusive := access == writeAccess
+
+		lock, unlockState := state.mu.RLock, state.mu.RUnlock
+		if exclusive {
+			lock, unlockState = state.mu.Lock, state.mu.Unlock
+		}
+
+		lock()
+
+		unlock, err := utils.LockFile(filename, exclusive)
+		if err != nil {
+			unlockState()
+			release()
+			return nil, err
+		}
+
+		unlocks = append(unlocks, func() {
+			unlock()
+			unlockState()
+		})
+	}
+
+	return release, nil
+}
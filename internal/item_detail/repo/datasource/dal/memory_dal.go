@@ -0,0 +1,381 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryDAL es una implementación CRUD en memoria, segura para usar desde
+// varias goroutines. Pensada para tests y entornos efímeros: los datos se
+// pierden al terminar el proceso.
+type MemoryDAL[T any] struct {
+	*collection[T]
+	store *memoryStore[T]
+}
+
+// NewMemoryDAL crea una colección vacía.
+func NewMemoryDAL[T any]() *MemoryDAL[T] {
+	store := &memoryStore[T]{refs: &lazyIndex{}}
+
+	return &MemoryDAL[T]{collection: &collection[T]{store: store}, store: store}
+}
+
+// memoryRecord guarda la entidad serializada, igual que en el archivo JSON:
+// así cada lectura devuelve una copia independiente y los campos que no se
+// persisten (json:"-") se comportan igual que en CrudDAL.
+type memoryRecord struct {
+	id  string
+	raw []byte
+}
+
+type memoryStore[T any] struct {
+	mu        sync.RWMutex
+	records   []memoryRecord
+	refs      *lazyIndex          // índices secundarios de records
+	revisions map[string][][]byte // versiones archivadas, por ID
+	writes    uint64
+	changedAt time.Time
+}
+
+func (s *memoryStore[T]) view(fn func(tx recordTx[T]) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(&memoryTx[T]{records: s.records, refs: s.refs})
+}
+
+func (s *memoryStore[T]) update(fn func(tx recordTx[T]) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Se trabaja sobre una copia: si fn falla, la colección queda intacta.
+	tx := &memoryTx[T]{records: append([]memoryRecord(nil), s.records...), indexes: indexUpdate[T]{base: s.refs}}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	// Si fn no escribió nada (un Modify que no cambia nada, un Purge sin
+	// vencidas) no hay cambio: el token de LastChange, y con él los ETags,
+	// siguen valiendo.
+	if tx.dirty {
+		s.commit(tx)
+	}
+
+	return nil
+}
+
+// commit publica lo que escribió tx. Se llama con s.mu tomado.
+func (s *memoryStore[T]) commit(tx *memoryTx[T]) {
+	s.records = tx.records
+	s.refs = tx.indexes.publish()
+	s.writes++
+	s.changedAt = time.Now().UTC()
+
+	if s.revisions == nil {
+		s.revisions = map[string][][]byte{}
+	}
+
+	for _, id := range tx.forgotten {
+		delete(s.revisions, id)
+	}
+
+	for _, rec := range tx.archived {
+		s.revisions[rec.id] = append(s.revisions[rec.id], rec.raw)
+	}
+}
+
+// begin abre la colección para una transacción con el acceso pedido: toma
+// el lock de escritura o el de lectura, que el coordinador suelta con end.
+// Sin acceso no toma nada y devuelve nil.
+func (s *memoryStore[T]) begin(access txAccess) *memoryTx[T] {
+	switch access {
+	case writeAccess:
+		s.mu.Lock()
+		return &memoryTx[T]{records: append([]memoryRecord(nil), s.records...), indexes: indexUpdate[T]{base: s.refs}}
+	case readAccess:
+		s.mu.RLock()
+		return &memoryTx[T]{records: s.records, refs: s.refs}
+	}
+
+	return nil
+}
+
+// end suelta el lock que tomó begin.
+func (s *memoryStore[T]) end(access txAccess) {
+	switch access {
+	case writeAccess:
+		s.mu.Unlock()
+	case readAccess:
+		s.mu.RUnlock()
+	}
+}
+
+// apply publica lo que escribió la transacción tx. Se llama con el lock de
+// begin tomado.
+func (s *memoryStore[T]) apply(tx *memoryTx[T]) {
+	if tx != nil && tx.dirty {
+		s.commit(tx)
+	}
+}
+
+// txStore devuelve la colección vista desde la transacción tx.
+func (s *memoryStore[T]) txStore(tx *memoryTx[T]) *txStore[T] {
+	return &txStore[T]{
+		tx: tx,
+		change: func() (dao.Change, error) {
+			return dao.Change{Token: strconv.FormatUint(s.writes, 10), At: s.changedAt}, nil
+		},
+		archived: func(id string) ([][]byte, error) {
+			return pendingHistory(append([][]byte(nil), s.revisions[id]...), tx.archived, id), nil
+		},
+	}
+}
+
+func (s *memoryStore[T]) history(id string) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([][]byte(nil), s.revisions[id]...), nil
+}
+
+func (s *memoryStore[T]) lastChange() (dao.Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return dao.Change{Token: strconv.FormatUint(s.writes, 10), At: s.changedAt}, nil
+}
+
+type memoryTx[T any] struct {
+	historyBuffer[T]
+	records []memoryRecord
+	dirty   bool       // si tx escribió algo
+	refs    *lazyIndex // índices de records; nil si tx los puede modificar
+	indexes indexUpdate[T]
+}
+
+func (tx *memoryTx[T]) lookup(field string, value string) ([]ranked[T], bool, error) {
+	if tx.refs == nil {
+		return nil, false, nil
+	}
+
+	index, err := tx.refs.get(func() (secondaryIndex, error) {
+		return buildIndex(len(tx.records), func(i int) (*T, error) {
+			return decodeRecord[T](tx.records[i].raw)
+		})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	positions, ok := index.positions(field, value)
+	if !ok {
+		return nil, false, nil
+	}
+
+	items := make([]ranked[T], 0, len(positions))
+
+	for _, i := range positions {
+		item, err := decodeRecord[T](tx.records[i].raw)
+		if err != nil {
+			return nil, false, err
+		}
+
+		items = append(items, ranked[T]{item: item, seq: int64(i)})
+	}
+
+	return items, true, nil
+}
+
+func (tx *memoryTx[T]) savepoint() (recordTx[T], func(keep bool) error, error) {
+	child := &memoryTx[T]{records: append([]memoryRecord(nil), tx.records...), indexes: tx.indexes.fork()}
+
+	return child, func(keep bool) error {
+		if keep {
+			tx.records = child.records
+			tx.keep(&child.historyBuffer)
+			tx.indexes.adopt(child.indexes)
+			tx.dirty = tx.dirty || child.dirty
+		}
+
+		return nil
+	}, nil
+}
+
+func (tx *memoryTx[T]) index(id string) int {
+	for i := range tx.records {
+		if tx.records[i].id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (tx *memoryTx[T]) get(id string) (*T, error) {
+	i := tx.index(id)
+	if i < 0 {
+		return nil, nil
+	}
+
+	return decodeRecord[T](tx.records[i].raw)
+}
+
+func (tx *memoryTx[T]) scan(fn func(item *T) bool) error {
+	for _, rec := range tx.records {
+		item, err := decodeRecord[T](rec.raw)
+		if err != nil {
+			return err
+		}
+
+		if !fn(item) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (tx *memoryTx[T]) insert(item *T) error {
+	rec, err := encodeRecord(item)
+	if err != nil {
+		return err
+	}
+
+	tx.indexes.insert(len(tx.records), item)
+	tx.records = append(tx.records, rec)
+	tx.dirty = true
+
+	return nil
+}
+
+func (tx *memoryTx[T]) replace(id string, item *T) error {
+	i := tx.index(id)
+	if i < 0 {
+		return dao.Errorf(dao.ErrNotFound, "Can't find entity with ID %v", id)
+	}
+
+	rec, err := encodeRecord(item)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(tx.records[i].raw, rec.raw) {
+		return nil
+	}
+
+	if err := tx.reindex(i, item); err != nil {
+		return err
+	}
+
+	tx.records[i] = rec
+	tx.dirty = true
+
+	return nil
+}
+
+// reindex actualiza los índices antes de reemplazar (item) o sacar (nil) el
+// record de la posición i.
+func (tx *memoryTx[T]) reindex(i int, item *T) error {
+	// La versión anterior sólo hace falta si hay un índice armado.
+	var old *T
+
+	if tx.indexes.tracking() {
+		var err error
+		if old, err = decodeRecord[T](tx.records[i].raw); err != nil {
+			return err
+		}
+	}
+
+	if item == nil {
+		tx.indexes.remove(i, old)
+	} else {
+		tx.indexes.replace(i, old, item)
+	}
+
+	return nil
+}
+
+func (tx *memoryTx[T]) remove(id string) (bool, error) {
+	i := tx.index(id)
+	if i < 0 {
+		return false, nil
+	}
+
+	if err := tx.reindex(i, nil); err != nil {
+		return false, err
+	}
+
+	kept := make([]memoryRecord, 0, len(tx.records))
+
+	for _, rec := range tx.records {
+		if rec.id != id {
+			kept = append(kept, rec)
+		}
+	}
+
+	found := len(kept) != len(tx.records)
+	tx.records = kept
+	tx.dirty = tx.dirty || found
+
+	return found, nil
+}
+
+func encodeRecord[T any](item *T) (memoryRecord, error) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return memoryRecord{}, fmt.Errorf("error encoding entity: %w", err)
+	}
+
+	return memoryRecord{id: entityID(item), raw: raw}, nil
+}
+
+func decodeRecord[T any](raw []byte) (*T, error) {
+	var item T
+
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, fmt.Errorf("error decoding entity: %w", err)
+	}
+
+	return &item, nil
+}
+
+// memoryTransaction implementa Storage.TransactIn para el driver memory. La
+// transacción trabaja sobre copias y confirmar no puede fallar: se publican
+// las colecciones que escribió antes de soltar cualquier lock.
+func memoryTransaction(
+	products *memoryStore[models.Product],
+	sellers *memoryStore[models.Seller],
+	categories *memoryStore[models.Category],
+	images *memoryStore[models.Image],
+) transactFunc {
+	return func(scope txScope, fn func(uow dao.UnitOfWork) error) error {
+		ptx, stx, ctx, itx := products.begin(scope.products), sellers.begin(scope.sellers), categories.begin(scope.categories), images.begin(scope.images)
+
+		defer func() {
+			images.end(scope.images)
+			categories.end(scope.categories)
+			sellers.end(scope.sellers)
+			products.end(scope.products)
+		}()
+
+		uow := newUnitOfWork(scope, products.txStore(ptx), sellers.txStore(stx), categories.txStore(ctx), images.txStore(itx))
+
+		if err := fn(uow); err != nil {
+			return err
+		}
+
+		products.apply(ptx)
+		sellers.apply(stx)
+		categories.apply(ctx)
+		images.apply(itx)
+
+		return nil
+	}
+}
@@ -0,0 +1,227 @@
+package dal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"project/internal/item_detail/repo/datasource/dao"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Paginación por cursor: un cursor apunta a la entidad del borde de una
+// página (la última para "next", la primera para "prev") y la página se arma
+// a partir de ella, así altas y bajas en el medio no corren los resultados.
+// Si esa entidad ya no está en el listado, el cursor trae sus valores de
+// orden (los campos de Sort y su posición en el orden de alta) para ubicar
+// dónde estaba.
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// pageCursor es el contenido de un cursor; viaja como JSON en base64url.
+type pageCursor struct {
+	Dir  string            `json:"d"`
+	ID   string            `json:"id"`
+	Keys []json.RawMessage `json:"k,omitempty"`
+	Seq  int64             `json:"n"` // posición del borde en el orden de alta
+	Sort string            `json:"s,omitempty"`
+
+	keys []any // Keys ya interpretadas según los campos de Sort
+}
+
+// ranked es una entidad del listado con su posición en el orden de alta del
+// store (el índice en el archivo o el seq de SQLite), que desempata el orden.
+type ranked[T any] struct {
+	item *T
+	seq  int64
+}
+
+// sortSignature resume Sort para rechazar un cursor emitido con otro orden.
+func sortSignature(keys []dao.SortKey) string {
+	parts := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		if key.Desc {
+			parts = append(parts, "-"+key.Field)
+		} else {
+			parts = append(parts, key.Field)
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// decodeCursor interpreta el cursor de query contra el orden ya compilado.
+func decodeCursor(raw string, query dao.ListQuery, sort []sortField) (*pageCursor, error) {
+	invalid := dao.Errorf(dao.ErrInvalidQuery, "invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" ||
+		(cursor.Dir != cursorNext && cursor.Dir != cursorPrev) {
+		return nil, invalid
+	}
+
+	if cursor.Sort != sortSignature(query.Sort) || len(cursor.Keys) != len(sort) {
+		return nil, dao.Errorf(dao.ErrInvalidQuery, "cursor doesn't match sort %q", sortSignature(query.Sort))
+	}
+
+	for i, key := range sort {
+		if string(cursor.Keys[i]) == "null" {
+			cursor.keys = append(cursor.keys, nil)
+			continue
+		}
+
+		var raw any
+		if err := json.Unmarshal(cursor.Keys[i], &raw); err != nil {
+			return nil, invalid
+		}
+
+		value, ok := cursorValue(key.field.kind, raw)
+		if !ok {
+			return nil, invalid
+		}
+
+		cursor.keys = append(cursor.keys, value)
+	}
+
+	return &cursor, nil
+}
+
+// cursorValue pasa un valor decodificado del JSON al tipo normalizado del campo.
+func cursorValue(kind fieldKind, raw any) (any, bool) {
+	switch kind {
+	case kindTime:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, false
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		return t, err == nil
+	case kindNumber:
+		n, ok := raw.(float64)
+		return n, ok
+	case kindBool:
+		b, ok := raw.(bool)
+		return b, ok
+	}
+
+	s, ok := raw.(string)
+	return s, ok
+}
+
+// encodeCursor arma el cursor dir con borde en edge.
+func (l listFilter[T]) encodeCursor(dir string, edge ranked[T]) string {
+	cursor := pageCursor{Dir: dir, ID: entityID(edge.item), Seq: edge.seq, Sort: l.signature}
+
+	value := reflect.ValueOf(edge.item).Elem()
+
+	for _, key := range l.sort {
+		v, ok := key.field.value(value)
+		if !ok {
+			v = nil
+		}
+
+		raw, _ := json.Marshal(v)
+		cursor.Keys = append(cursor.Keys, raw)
+	}
+
+	data, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// compareToCursor compara r con el borde del cursor, que ya no está en el
+// listado, en el orden del listado. Entre empates decide el orden de alta:
+// las que estaban después del borde tienen una posición mayor o, si el borde
+// se eliminó y las siguientes se corrieron, la suya.
+func (l listFilter[T]) compareToCursor(r ranked[T]) int {
+	value := reflect.ValueOf(r.item).Elem()
+
+	for i, key := range l.sort {
+		x, okX := key.field.value(value)
+		y := l.cursor.keys[i]
+
+		if c := key.compare(x, okX, y, y != nil); c != 0 {
+			return c
+		}
+	}
+
+	if r.seq >= l.cursor.Seq {
+		return 1
+	}
+
+	return -1
+}
+
+// locate devuelve dónde empieza (next) o termina (prev) la página del cursor
+// dentro de items, que ya están filtrados y ordenados.
+func (l listFilter[T]) locate(items []ranked[T]) int {
+	for i, r := range items {
+		if entityID(r.item) == l.cursor.ID {
+			if l.cursor.Dir == cursorNext {
+				return i + 1
+			}
+			return i
+		}
+	}
+
+	// El borde ya no está: la página sigue desde la primera entidad que
+	// ordena después de él.
+	for i, r := range items {
+		if l.compareToCursor(r) > 0 {
+			return i
+		}
+	}
+
+	return len(items)
+}
+
+// page recorta items (ya filtrados y ordenados) según el cursor o
+// limit/offset de query, que ya vienen normalizados.
+func (l listFilter[T]) page(items []ranked[T], query dao.ListQuery) dao.Page[T] {
+	start := min(query.Offset, len(items))
+	end := min(start+query.Limit, len(items))
+
+	if l.cursor != nil {
+		if l.cursor.Dir == cursorNext {
+			start = l.locate(items)
+			end = min(start+query.Limit, len(items))
+		} else {
+			end = l.locate(items)
+			start = max(end-query.Limit, 0)
+		}
+	}
+
+	return l.pageOf(items[start:end], start > 0, end < len(items), len(items))
+}
+
+// pageOf arma la página con los cursores que correspondan.
+func (l listFilter[T]) pageOf(items []ranked[T], hasPrev bool, hasNext bool, total int) dao.Page[T] {
+	page := dao.Page[T]{Items: make([]*T, len(items)), Total: total}
+
+	for i, r := range items {
+		page.Items[i] = r.item
+	}
+
+	if len(items) == 0 {
+		return page
+	}
+
+	if hasNext {
+		page.NextCursor = l.encodeCursor(cursorNext, items[len(items)-1])
+	}
+
+	if hasPrev {
+		page.PrevCursor = l.encodeCursor(cursorPrev, items[0])
+	}
+
+	return page
+}
@@ -1,16 +1,39 @@
 package dal
 
 import (
+	"database/sql"
+	"path/filepath"
 	"project/internal/item_detail/repo/datasource/dao"
 	models "project/pkg"
 )
 
 type productDAL struct {
-	*CrudDAL[models.Product]
+	dao.CrudDAO[models.Product]
 }
 
+// NewProductDAL usa Product.json en el directorio de trabajo.
 func NewProductDAL() dao.ProductDAO {
+	return NewJSONProductDAL(".")
+}
+
+// NewJSONProductDAL usa Product.json dentro de dir.
+func NewJSONProductDAL(dir string) dao.ProductDAO {
 	return &productDAL{
-		CrudDAL: &CrudDAL[models.Product]{Filename: "Product"},
+		CrudDAO: &CrudDAL[models.Product]{Filename: filepath.Join(dir, "Product")},
+	}
+}
+
+// NewMemoryProductDAL guarda los productos en memoria.
+func NewMemoryProductDAL() dao.ProductDAO {
+	return &productDAL{CrudDAO: NewMemoryDAL[models.Product]()}
+}
+
+// NewSQLiteProductDAL usa la tabla products de db.
+func NewSQLiteProductDAL(db *sql.DB) (dao.ProductDAO, error) {
+	crud, err := NewSQLiteDAL[models.Product](db, "products")
+	if err != nil {
+		return nil, err
 	}
+
+	return &productDAL{CrudDAO: crud}, nil
 }
@@ -0,0 +1,93 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"project/pkg/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTrashRetention es cuánto queda una entidad en la papelera antes
+	// de que el purge la elimine.
+	DefaultTrashRetention = 30 * 24 * time.Hour
+	// DefaultPurgeInterval es cada cuánto corre el purge.
+	DefaultPurgeInterval = time.Hour
+)
+
+// Purge elimina definitivamente, de las cuatro colecciones, las entidades
+// borradas antes de before. Devuelve cuántas eliminó en total.
+func (s *Storage) Purge(before time.Time) (int, error) {
+	purges := []struct {
+		name  string
+		purge func(time.Time) (int, error)
+	}{
+		{"products", s.Products.Purge},
+		{"sellers", s.Sellers.Purge},
+		{"categories", s.Categories.Purge},
+		{"images", s.Images.Purge},
+	}
+
+	total := 0
+	var errs []error
+
+	for _, p := range purges {
+		n, err := p.purge(before)
+		total += n
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("purging %s: %w", p.name, err))
+		}
+	}
+
+	return total, errors.Join(errs...)
+}
+
+// startPurgeJob purga lo que lleva más de retention en la papelera al
+// arrancar y después cada interval. La función que devuelve frena el job y
+// espera a que termine la pasada en curso.
+func startPurgeJob(st *Storage, retention time.Duration, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purged, err := st.Purge(time.Now().Add(-retention))
+
+			if logger.Log != nil {
+				if err != nil {
+					logger.Log.Error("trash purge failed", zap.Int("purged", purged), zap.Error(err))
+				} else if purged > 0 {
+					logger.Log.Info("trash purged", zap.Int("purged", purged))
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+}
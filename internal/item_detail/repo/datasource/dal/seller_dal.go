@@ -1,16 +1,39 @@
 package dal
 
 import (
+	"database/sql"
+	"path/filepath"
 	"project/internal/item_detail/repo/datasource/dao"
 	models "project/pkg"
 )
 
 type sellerDAL struct {
-	*CrudDAL[models.Seller]
+	dao.CrudDAO[models.Seller]
 }
 
+// NewSellerDAL usa Seller.json en el directorio de trabajo.
 func NewSellerDAL() dao.SellerDAO {
+	return NewJSONSellerDAL(".")
+}
+
+// NewJSONSellerDAL usa Seller.json dentro de dir.
+func NewJSONSellerDAL(dir string) dao.SellerDAO {
 	return &sellerDAL{
-		CrudDAL: &CrudDAL[models.Seller]{Filename: "Seller"},
+		CrudDAO: &CrudDAL[models.Seller]{Filename: filepath.Join(dir, "Seller")},
+	}
+}
+
+// NewMemorySellerDAL guarda los vendedores en memoria.
+func NewMemorySellerDAL() dao.SellerDAO {
+	return &sellerDAL{CrudDAO: NewMemoryDAL[models.Seller]()}
+}
+
+// NewSQLiteSellerDAL usa la tabla sellers de db.
+func NewSQLiteSellerDAL(db *sql.DB) (dao.SellerDAO, error) {
+	crud, err := NewSQLiteDAL[models.Seller](db, "sellers")
+	if err != nil {
+		return nil, err
 	}
+
+	return &sellerDAL{CrudDAO: crud}, nil
 }
@@ -0,0 +1,693 @@
+package dal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite" // driver SQLite en Go puro (sin cgo)
+)
+
+// OpenSQLite abre (o crea) la base SQLite en path, con WAL y busy timeout
+// para que lecturas y escrituras concurrentes no fallen con SQLITE_BUSY.
+func OpenSQLite(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf(
+		"file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(FULL)&_txlock=immediate",
+		path,
+	)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening SQLite database %s: %w", path, err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening SQLite database %s: %w", path, err)
+	}
+
+	return db, nil
+}
+
+// SQLiteDAL es una implementación CRUD sobre una tabla SQLite.
+// Cada fila guarda la entidad serializada en JSON, junto con su ID, su
+// nombre en minúsculas y su UpdatedAt en columnas indexadas para búsquedas y
+// filtros. El nombre además va a una tabla FTS5 con tokenizer trigram, que
+// resuelve la búsqueda por substring de ?q= con un índice.
+type SQLiteDAL[T any] struct {
+	*collection[T]
+}
+
+// NewSQLiteDAL crea (si no existen) la tabla y sus índices.
+func NewSQLiteDAL[T any](db *sql.DB, table string) (*SQLiteDAL[T], error) {
+	schema := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+			id          TEXT NOT NULL,
+			name_search TEXT,
+			data        TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS %[1]s_id_idx ON %[1]s (id);
+		CREATE INDEX IF NOT EXISTS %[1]s_name_idx ON %[1]s (name_search);
+		CREATE TABLE IF NOT EXISTS %[1]s_history (
+			seq  INTEGER PRIMARY KEY AUTOINCREMENT,
+			id   TEXT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS %[1]s_history_id_idx ON %[1]s_history (id);
+		CREATE TABLE IF NOT EXISTS collection_changes (
+			name       TEXT PRIMARY KEY,
+			writes     INTEGER NOT NULL,
+			changed_at TEXT NOT NULL
+		);
+	`, table)
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("error creating table %s: %w", table, err)
+	}
+
+	if err := migrateUpdatedAt[T](db, table); err != nil {
+		return nil, fmt.Errorf("error migrating table %s: %w", table, err)
+	}
+
+	if err := migrateNameSearch(db, table); err != nil {
+		return nil, fmt.Errorf("error creating the name index of %s: %w", table, err)
+	}
+
+	// Los campos con tag index se indexan sobre el JSON (ver search).
+	for _, field := range indexedFields[T]() {
+		index := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_%[2]s_idx ON %[1]s (%[3]s)", table, field.name, jsonPath(field.name))
+
+		if _, err := db.Exec(index); err != nil {
+			return nil, fmt.Errorf("error creating index on %s.%s: %w", table, field.name, err)
+		}
+	}
+
+	store := &sqliteStore[T]{db: db, table: table}
+
+	return &SQLiteDAL[T]{collection: &collection[T]{store: store}}, nil
+}
+
+// queryer es lo que comparten *sql.DB y *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type sqliteStore[T any] struct {
+	db    *sql.DB
+	table string
+}
+
+// view corre fn en una transacción de sólo lectura: todas sus consultas (por
+// ejemplo, la página y su count(*)) ven la misma versión de la base aunque
+// haya escrituras en el medio. En WAL no bloquea a los que escriben.
+func (s *sqliteStore[T]) view(fn func(tx recordTx[T]) error) error {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("error starting SQLite read transaction: %w", err)
+	}
+	defer tx.Rollback() // sólo lectura: no hay nada que confirmar
+
+	return fn(&sqliteTx[T]{q: tx, table: s.table})
+}
+
+func (s *sqliteStore[T]) update(fn func(tx recordTx[T]) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting SQLite transaction: %w", err)
+	}
+
+	stx := &sqliteTx[T]{q: tx, table: s.table}
+
+	if err := fn(stx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// El contador de escrituras viaja en la misma transacción que los datos.
+	if stx.dirty {
+		if err := recordChange(tx, s.table); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing SQLite transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sqliteStore[T]) lastChange() (dao.Change, error) {
+	var (
+		writes    int64
+		changedAt string
+	)
+
+	err := s.db.QueryRow(
+		"SELECT writes, changed_at FROM collection_changes WHERE name = ?", s.table,
+	).Scan(&writes, &changedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return dao.Change{Token: "0"}, nil
+	}
+
+	if err != nil {
+		return dao.Change{}, err
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, changedAt)
+	if err != nil {
+		return dao.Change{}, fmt.Errorf("error decoding change time: %w", err)
+	}
+
+	return dao.Change{Token: strconv.FormatInt(writes, 10), At: at}, nil
+}
+
+// history lee las versiones archivadas de id en <tabla>_history.
+func (s *sqliteStore[T]) history(id string) ([][]byte, error) {
+	return readHistoryRows(s.db, s.table, id)
+}
+
+// txStore devuelve la colección vista desde la transacción tx.
+func (s *sqliteStore[T]) txStore(tx *sql.Tx) *txStore[T] {
+	return &txStore[T]{
+		tx:     &sqliteTx[T]{q: tx, table: s.table},
+		change: s.lastChange,
+		archived: func(id string) ([][]byte, error) {
+			return readHistoryRows(tx, s.table, id)
+		},
+	}
+}
+
+// recordChange suma una escritura a la colección table en collection_changes.
+func recordChange(q queryer, table string) error {
+	_, err := q.Exec(
+		`INSERT INTO collection_changes (name, writes, changed_at) VALUES (?, 1, ?)
+		 ON CONFLICT (name) DO UPDATE SET writes = writes + 1, changed_at = excluded.changed_at`,
+		table, time.Now().UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return fmt.Errorf("error recording SQLite change: %w", err)
+	}
+
+	return nil
+}
+
+func readHistoryRows(q queryer, table string, id string) ([][]byte, error) {
+	rows, err := q.Query(fmt.Sprintf("SELECT data FROM %s_history WHERE id = ? ORDER BY seq", table), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions [][]byte
+
+	for rows.Next() {
+		var raw string
+
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, []byte(raw))
+	}
+
+	return versions, rows.Err()
+}
+
+type sqliteTx[T any] struct {
+	q     queryer
+	table string
+	dirty bool
+}
+
+// savepoint usa un SAVEPOINT de SQLite. Sólo se llama dentro de una
+// transacción (q es un *sql.Tx).
+func (tx *sqliteTx[T]) savepoint() (recordTx[T], func(keep bool) error, error) {
+	if _, err := tx.q.Exec("SAVEPOINT collection_op"); err != nil {
+		return nil, nil, fmt.Errorf("error opening SQLite savepoint: %w", err)
+	}
+
+	child := &sqliteTx[T]{q: tx.q, table: tx.table}
+
+	return child, func(keep bool) error {
+		if !keep {
+			if _, err := tx.q.Exec("ROLLBACK TO collection_op"); err != nil {
+				return fmt.Errorf("error rolling back SQLite savepoint: %w", err)
+			}
+		} else if child.dirty {
+			if err := recordChange(tx.q, tx.table); err != nil {
+				return err
+			}
+
+			tx.dirty = true
+		}
+
+		if _, err := tx.q.Exec("RELEASE collection_op"); err != nil {
+			return fmt.Errorf("error releasing SQLite savepoint: %w", err)
+		}
+
+		return nil
+	}, nil
+}
+
+func (tx *sqliteTx[T]) get(id string) (*T, error) {
+	var raw string
+
+	err := tx.q.QueryRow(
+		fmt.Sprintf("SELECT data FROM %s WHERE id = ? ORDER BY seq LIMIT 1", tx.table),
+		id,
+	).Scan(&raw)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeRecord[T]([]byte(raw))
+}
+
+func (tx *sqliteTx[T]) scan(fn func(item *T) bool) error {
+	rows, err := tx.q.Query(fmt.Sprintf("SELECT data FROM %s ORDER BY seq", tx.table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanRow[T](rows)
+		if err != nil {
+			return err
+		}
+
+		if !fn(item) {
+			break
+		}
+	}
+
+	return rows.Err()
+}
+
+// jsonPath es la expresión que lee el campo name del JSON de la fila.
+func jsonPath(name string) string {
+	return fmt.Sprintf("json_extract(data, '$.%s')", name)
+}
+
+// search resuelve los filtros y la paginación en SQL, usando el índice
+// trigram del nombre, la columna updated_at (y el JSON para saber si la
+// entidad está borrada).
+func (tx *sqliteTx[T]) search(query dao.ListQuery, filter listFilter[T]) (dao.Page[T], error) {
+	conditions := []string{}
+	args := []any{}
+
+	if query.Q != "" {
+		condition, arg := nameCondition(tx.table, query.Q)
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if !query.UpdatedSince.IsZero() {
+		conditions = append(conditions, "updated_at >= ?")
+		args = append(args, query.UpdatedSince.UnixNano())
+	}
+
+	// El path va literal, no como parámetro: así SQLite usa el índice del
+	// campo. Sólo se aceptan nombres de campos del modelo.
+	fields := stringFields[T]()
+
+	for _, name := range slices.Sorted(maps.Keys(query.Equals)) {
+		if _, ok := fields[name]; !ok {
+			return dao.Page[T]{Items: []*T{}}, nil
+		}
+
+		conditions = append(conditions, jsonPath(name)+" = ?")
+		args = append(args, query.Equals[name])
+	}
+
+	switch query.Deleted {
+	case dao.ExcludeDeleted:
+		conditions = append(conditions, "json_extract(data, '$.deleted_at') IS NULL")
+	case dao.OnlyDeleted:
+		conditions = append(conditions, "json_extract(data, '$.deleted_at') IS NOT NULL")
+	}
+
+	// Si los filtros, el orden o el cursor no se pueden expresar en SQL, se
+	// traen las filas que cumplen el resto y se filtra, ordena y pagina en Go.
+	pushdown := filter.pushdown()
+	order := []string{"seq"}
+
+	if pushdown {
+		where, whereArgs, err := filter.where()
+		if err != nil {
+			return dao.Page[T]{}, err
+		}
+
+		conditions = append(conditions, where...)
+		args = append(args, whereArgs...)
+		order = append(filter.orderBy(), order...)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	sqlQuery := fmt.Sprintf("SELECT seq, data FROM %s%s ORDER BY %s", tx.table, where, strings.Join(order, ", "))
+	countArgs := args
+
+	if pushdown {
+		sqlQuery += " LIMIT ? OFFSET ?"
+		args = append(slices.Clip(args), query.Limit, query.Offset)
+	}
+
+	rows, err := tx.q.Query(sqlQuery, args...)
+	if err != nil {
+		return dao.Page[T]{}, err
+	}
+	defer rows.Close()
+
+	items := []ranked[T]{}
+
+	for rows.Next() {
+		var (
+			seq int64
+			raw string
+		)
+
+		if err := rows.Scan(&seq, &raw); err != nil {
+			return dao.Page[T]{}, err
+		}
+
+		item, err := decodeRecord[T]([]byte(raw))
+		if err != nil {
+			return dao.Page[T]{}, err
+		}
+
+		if pushdown || filter.match(item) {
+			items = append(items, ranked[T]{item: item, seq: seq})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return dao.Page[T]{}, err
+	}
+
+	if !pushdown {
+		filter.apply(items)
+		return filter.page(items, query), nil
+	}
+
+	var total int
+
+	err = tx.q.QueryRow(fmt.Sprintf("SELECT count(*) FROM %s%s", tx.table, where), countArgs...).Scan(&total)
+	if err != nil {
+		return dao.Page[T]{}, err
+	}
+
+	return filter.pageOf(items, query.Offset > 0, query.Offset+len(items) < total, total), nil
+}
+
+func (tx *sqliteTx[T]) insert(item *T) error {
+	raw, nameSearch, err := encodeRow(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.q.Exec(
+		fmt.Sprintf("INSERT INTO %s (id, name_search, updated_at, data) VALUES (?, ?, ?, ?)", tx.table),
+		entityID(item), nameSearch, updatedAtColumn(item), raw,
+	)
+	tx.dirty = tx.dirty || err == nil
+
+	return err
+}
+
+func (tx *sqliteTx[T]) replace(id string, item *T) error {
+	raw, nameSearch, err := encodeRow(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.q.Exec(
+		fmt.Sprintf(
+			"UPDATE %[1]s SET name_search = ?, updated_at = ?, data = ? WHERE seq = (SELECT seq FROM %[1]s WHERE id = ? ORDER BY seq LIMIT 1)",
+			tx.table,
+		),
+		nameSearch, updatedAtColumn(item), raw, id,
+	)
+	tx.dirty = tx.dirty || err == nil
+
+	return err
+}
+
+func (tx *sqliteTx[T]) remove(id string) (bool, error) {
+	res, err := tx.q.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", tx.table), id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	tx.dirty = tx.dirty || n > 0
+
+	return n > 0, err
+}
+
+// archive guarda la versión en <tabla>_history dentro de la misma transacción.
+func (tx *sqliteTx[T]) archive(item *T) error {
+	raw, _, err := encodeRow(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.q.Exec(fmt.Sprintf("INSERT INTO %s_history (id, data) VALUES (?, ?)", tx.table), entityID(item), raw)
+
+	return err
+}
+
+// forget borra de <tabla>_history las versiones de id, en la misma transacción.
+func (tx *sqliteTx[T]) forget(id string) error {
+	_, err := tx.q.Exec(fmt.Sprintf("DELETE FROM %s_history WHERE id = ?", tx.table), id)
+
+	return err
+}
+
+func encodeRow[T any](item *T) (string, sql.NullString, error) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return "", sql.NullString{}, fmt.Errorf("error encoding entity: %w", err)
+	}
+
+	var nameSearch sql.NullString
+
+	if name, ok := entityName(item); ok {
+		nameSearch = sql.NullString{String: strings.ToLower(name), Valid: true}
+	}
+
+	return string(raw), nameSearch, nil
+}
+
+// nameCondition es la condición de ?q= (el nombre contiene q, sin distinguir
+// mayúsculas). Con tres letras o más se resuelve con el índice trigram: la
+// frase entre comillas son los trigramas de q, seguidos. Con menos no hay
+// trigramas que buscar y se recorre la columna.
+func nameCondition(table string, q string) (string, any) {
+	q = strings.ToLower(q)
+
+	if utf8.RuneCountInString(q) < 3 {
+		return "instr(name_search, ?) > 0", q
+	}
+
+	phrase := `"` + strings.ReplaceAll(q, `"`, `""`) + `"`
+
+	return fmt.Sprintf("seq IN (SELECT rowid FROM %[1]s_name_fts WHERE %[1]s_name_fts MATCH ?)", table), phrase
+}
+
+// migrateNameSearch crea la tabla FTS5 del nombre y los triggers que la
+// mantienen al día con cada INSERT, UPDATE y DELETE de la tabla. Si la tabla
+// FTS no existía (una base creada antes), la arma con las filas que ya hay.
+func migrateNameSearch(db *sql.DB, table string) error {
+	var exists int
+
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table+"_name_fts",
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	schema := fmt.Sprintf(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS %[1]s_name_fts USING fts5(
+			name_search, content = '%[1]s', content_rowid = 'seq', tokenize = 'trigram'
+		);
+		CREATE TRIGGER IF NOT EXISTS %[1]s_name_fts_insert AFTER INSERT ON %[1]s BEGIN
+			INSERT INTO %[1]s_name_fts (rowid, name_search) VALUES (new.seq, new.name_search);
+		END;
+		CREATE TRIGGER IF NOT EXISTS %[1]s_name_fts_delete AFTER DELETE ON %[1]s BEGIN
+			INSERT INTO %[1]s_name_fts (%[1]s_name_fts, rowid, name_search) VALUES ('delete', old.seq, old.name_search);
+		END;
+		CREATE TRIGGER IF NOT EXISTS %[1]s_name_fts_update AFTER UPDATE OF name_search ON %[1]s BEGIN
+			INSERT INTO %[1]s_name_fts (%[1]s_name_fts, rowid, name_search) VALUES ('delete', old.seq, old.name_search);
+			INSERT INTO %[1]s_name_fts (rowid, name_search) VALUES (new.seq, new.name_search);
+		END;
+	`, table)
+
+	if _, err := tx.Exec(schema); err != nil {
+		return err
+	}
+
+	if exists == 0 {
+		if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %[1]s_name_fts (%[1]s_name_fts) VALUES ('rebuild')", table)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// updatedAtColumn es el valor de la columna updated_at: UpdatedAt en
+// nanosegundos, o 0 si la entidad no lleva datos de control.
+func updatedAtColumn[T any](item *T) int64 {
+	if m := meta(item); m != nil && !m.UpdatedAt.IsZero() {
+		return m.UpdatedAt.UnixNano()
+	}
+
+	return 0
+}
+
+// migrateUpdatedAt agrega la columna updated_at a las tablas creadas antes de
+// que existiera y la completa a partir del JSON de cada fila.
+func migrateUpdatedAt[T any](db *sql.DB, table string) error {
+	var exists int
+
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'updated_at'", table,
+	).Scan(&exists)
+	if err != nil || exists > 0 {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0", table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_updated_idx ON %[1]s (updated_at)", table),
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("SELECT seq, data FROM %s", table))
+	if err != nil {
+		return err
+	}
+
+	updates := map[int64]int64{}
+
+	for rows.Next() {
+		var (
+			seq int64
+			raw string
+		)
+
+		if err := rows.Scan(&seq, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+
+		item, err := decodeRecord[T]([]byte(raw))
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		if at := updatedAtColumn(item); at != 0 {
+			updates[seq] = at
+		}
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for seq, at := range updates {
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET updated_at = ? WHERE seq = ?", table), at, seq); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func scanRow[T any](rows *sql.Rows) (*T, error) {
+	var raw string
+
+	if err := rows.Scan(&raw); err != nil {
+		return nil, err
+	}
+
+	return decodeRecord[T]([]byte(raw))
+}
+
+// sqliteTransaction implementa Storage.TransactIn para el driver sqlite: las
+// cuatro tablas viven en la misma base, así que alcanza con una transacción.
+// Los locks los maneja SQLite: el alcance sólo decide qué colecciones se
+// pueden usar. Las tablas son las de New<Entidad>SQLiteDAL.
+func sqliteTransaction(db *sql.DB) transactFunc {
+	return func(scope txScope, fn func(uow dao.UnitOfWork) error) error {
+		tx, err := db.Begin()
+		if err != nil {
+			return dao.Errorf(dao.ErrStorageUnavailable, "error starting SQLite transaction: %w", err)
+		}
+		defer tx.Rollback() // no hace nada después del Commit
+
+		uow := newUnitOfWork(
+			scope,
+			(&sqliteStore[models.Product]{db: db, table: "products"}).txStore(tx),
+			(&sqliteStore[models.Seller]{db: db, table: "sellers"}).txStore(tx),
+			(&sqliteStore[models.Category]{db: db, table: "categories"}).txStore(tx),
+			(&sqliteStore[models.Image]{db: db, table: "images"}).txStore(tx),
+		)
+
+		if err := fn(uow); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return dao.Errorf(dao.ErrStorageUnavailable, "error committing SQLite transaction: %w", err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,325 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage agrupa los DAL de las cuatro colecciones de un mismo backend.
+type Storage struct {
+	Products   dao.ProductDAO
+	Sellers    dao.SellerDAO
+	Categories dao.CategoryDAO
+	Images     dao.ImageDAO
+
+	close    func() error
+	transact transactFunc
+}
+
+// Close libera los recursos del backend (conexiones, archivos abiertos).
+func (s *Storage) Close() error {
+	if s.close == nil {
+		return nil
+	}
+
+	return s.close()
+}
+
+// StorageConfig elige y configura el backend.
+type StorageConfig struct {
+	Driver  string // Nombre con el que se registró el driver (json, sqlite, ...)
+	DataDir string // Directorio donde el driver guarda sus archivos
+
+	// JournalCompactEvery es el umbral de compactación del driver journal
+	// (<= 0 usa DefaultJournalCompactEvery).
+	JournalCompactEvery int
+
+	// TrashRetention es cuánto quedan las entidades borradas en la papelera
+	// antes de eliminarlas definitivamente. <= 0 desactiva el purge.
+	TrashRetention time.Duration
+	// PurgeInterval es cada cuánto corre el purge (<= 0 usa DefaultPurgeInterval).
+	PurgeInterval time.Duration
+}
+
+// StorageDriver construye un Storage a partir de la configuración.
+type StorageDriver func(cfg StorageConfig) (*Storage, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]StorageDriver{}
+)
+
+// RegisterStorage registra un driver bajo un nombre. Registrar dos veces el
+// mismo nombre es un error de programación y hace panic.
+func RegisterStorage(name string, driver StorageDriver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if _, exists := drivers[name]; exists {
+		panic(fmt.Sprintf("storage driver %q already registered", name))
+	}
+
+	drivers[name] = driver
+}
+
+// StorageDrivers devuelve los nombres de los drivers registrados, ordenados.
+func StorageDrivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// StorageConfigFromEnv lee STORAGE_DRIVER (default "json"), DATA_DIR
+// (default "."), JOURNAL_COMPACT_EVERY, TRASH_RETENTION (default 720h; "0"
+// desactiva el purge) y PURGE_INTERVAL (default 1h).
+func StorageConfigFromEnv() StorageConfig {
+	cfg := StorageConfig{
+		Driver:         os.Getenv("STORAGE_DRIVER"),
+		DataDir:        os.Getenv("DATA_DIR"),
+		TrashRetention: DefaultTrashRetention,
+		PurgeInterval:  DefaultPurgeInterval,
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("JOURNAL_COMPACT_EVERY")); err == nil {
+		cfg.JournalCompactEvery = n
+	}
+
+	if d, err := time.ParseDuration(os.Getenv("TRASH_RETENTION")); err == nil {
+		cfg.TrashRetention = d
+	}
+
+	if d, err := time.ParseDuration(os.Getenv("PURGE_INTERVAL")); err == nil {
+		cfg.PurgeInterval = d
+	}
+
+	if cfg.Driver == "" {
+		cfg.Driver = "json"
+	}
+
+	if cfg.DataDir == "" {
+		cfg.DataDir = "."
+	}
+
+	if dir, err := filepath.Abs(cfg.DataDir); err == nil {
+		cfg.DataDir = dir
+	}
+
+	return cfg
+}
+
+// OpenStorage abre el backend elegido. DataDir se resuelve a una ruta absoluta
+// antes de pasarla al driver, así no depende del directorio de arranque.
+func OpenStorage(cfg StorageConfig) (*Storage, error) {
+	driversMu.RLock()
+	driver, ok := drivers[cfg.Driver]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf(
+			"unknown storage driver %q (available: %s)",
+			cfg.Driver, strings.Join(StorageDrivers(), ", "),
+		)
+	}
+
+	if cfg.DataDir != "" {
+		dir, err := filepath.Abs(cfg.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data directory %q: %w", cfg.DataDir, err)
+		}
+
+		cfg.DataDir = dir
+	}
+
+	st, err := driver(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("storage driver %q: %w", cfg.Driver, err)
+	}
+
+	if cfg.TrashRetention > 0 {
+		stop := startPurgeJob(st, cfg.TrashRetention, cfg.PurgeInterval)
+		closeDriver := st.close
+
+		// El job se frena antes de cerrar el backend que usa.
+		st.close = func() error {
+			stop()
+
+			if closeDriver == nil {
+				return nil
+			}
+
+			return closeDriver()
+		}
+	}
+
+	return st, nil
+}
+
+// ensureWritableDir crea el directorio si no existe y verifica que se pueda
+// escribir en él, para fallar al arrancar y no en el primer POST.
+func ensureWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("data directory %s can't be created: %w", dir, err)
+	}
+
+	probe, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("data directory %s is not writable: %w", dir, err)
+	}
+
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return nil
+}
+
+func init() {
+	RegisterStorage("json", func(cfg StorageConfig) (*Storage, error) {
+		if err := ensureWritableDir(cfg.DataDir); err != nil {
+			return nil, err
+		}
+
+		// Una transacción que quedó a medias (el proceso murió confirmándola)
+		// se deshace antes de servir nada.
+		if err := recoverJSONTransaction(cfg.DataDir); err != nil {
+			return nil, fmt.Errorf("error recovering JSON transaction: %w", err)
+		}
+
+		return &Storage{
+			Products:   NewJSONProductDAL(cfg.DataDir),
+			Sellers:    NewJSONSellerDAL(cfg.DataDir),
+			Categories: NewJSONCategoryDAL(cfg.DataDir),
+			Images:     NewJSONImageDAL(cfg.DataDir),
+			transact:   jsonTransaction(cfg.DataDir),
+		}, nil
+	})
+
+	RegisterStorage("memory", func(cfg StorageConfig) (*Storage, error) {
+		products := NewMemoryDAL[models.Product]()
+		sellers := NewMemoryDAL[models.Seller]()
+		categories := NewMemoryDAL[models.Category]()
+		images := NewMemoryDAL[models.Image]()
+
+		return &Storage{
+			Products:   &productDAL{CrudDAO: products},
+			Sellers:    &sellerDAL{CrudDAO: sellers},
+			Categories: &categoryDAL{CrudDAO: categories},
+			Images:     &imageDAL{CrudDAO: images},
+			transact:   memoryTransaction(products.store, sellers.store, categories.store, images.store),
+		}, nil
+	})
+
+	RegisterStorage("sqlite", func(cfg StorageConfig) (*Storage, error) {
+		if err := ensureWritableDir(cfg.DataDir); err != nil {
+			return nil, err
+		}
+
+		db, err := OpenSQLite(filepath.Join(cfg.DataDir, "catalog.db"))
+		if err != nil {
+			return nil, err
+		}
+
+		st := &Storage{close: db.Close, transact: sqliteTransaction(db)}
+
+		if st.Products, err = NewSQLiteProductDAL(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+		if st.Sellers, err = NewSQLiteSellerDAL(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+		if st.Categories, err = NewSQLiteCategoryDAL(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+		if st.Images, err = NewSQLiteImageDAL(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		return st, nil
+	})
+	RegisterStorage("journal", func(cfg StorageConfig) (*Storage, error) {
+		if err := ensureWritableDir(cfg.DataDir); err != nil {
+			return nil, err
+		}
+
+		opts := JournalOptions{CompactEvery: cfg.JournalCompactEvery}
+
+		var opened []func() error
+
+		closeAll := func() error {
+			var errs []error
+			for _, close := range opened {
+				errs = append(errs, close())
+			}
+			return errors.Join(errs...)
+		}
+
+		products, err := openJournal[models.Product](cfg.DataDir, "Product", opts, &opened)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sellers, err := openJournal[models.Seller](cfg.DataDir, "Seller", opts, &opened)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		categories, err := openJournal[models.Category](cfg.DataDir, "Category", opts, &opened)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		images, err := openJournal[models.Image](cfg.DataDir, "Image", opts, &opened)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		// Una transacción que alcanzó a escribirse en algún journal se
+		// completa en los demás.
+		if err := recoverJournalTransaction(products.store, sellers.store, categories.store, images.store); err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		return &Storage{
+			Products:   &productDAL{CrudDAO: products},
+			Sellers:    &sellerDAL{CrudDAO: sellers},
+			Categories: &categoryDAL{CrudDAO: categories},
+			Images:     &imageDAL{CrudDAO: images},
+			close:      closeAll,
+			transact:   journalTransaction(products.store, sellers.store, categories.store, images.store),
+		}, nil
+	})
+}
+
+// openJournal abre la colección name dentro de dir y anota su Close en opened.
+func openJournal[T any](dir string, name string, opts JournalOptions, opened *[]func() error) (*JournalDAL[T], error) {
+	journal, err := OpenJournalDAL[T](filepath.Join(dir, name), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	*opened = append(*opened, journal.Close)
+
+	return journal, nil
+}
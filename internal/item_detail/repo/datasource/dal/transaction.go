@@ -0,0 +1,215 @@
+package dal
+
+import (
+	"errors"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+)
+
+// Orden de los locks: una transacción toma las colecciones de su alcance al
+// empezar, siempre en el orden products, sellers, categories, images (las de
+// escritura con el lock de escritura, las de lectura con el de lectura). Las
+// escrituras sueltas sólo anidan lecturas de otras colecciones dentro de una
+// escritura de productos (service.Integrity sin transactor), así que con
+// productos primero no hay ciclos.
+
+// txAccess es lo que una transacción hace con una colección.
+type txAccess int
+
+const (
+	noAccess txAccess = iota
+	readAccess
+	writeAccess
+)
+
+// txScope es un dao.Scope ya resuelto: el acceso a cada colección.
+type txScope struct {
+	products, sellers, categories, images txAccess
+}
+
+// inLockOrder devuelve los accesos en el orden de los locks.
+func (s txScope) inLockOrder() [4]txAccess {
+	return [4]txAccess{s.products, s.sellers, s.categories, s.images}
+}
+
+// resolveScope valida scope. Una colección en Writes y en Reads se escribe.
+func resolveScope(scope dao.Scope) (txScope, error) {
+	var resolved txScope
+
+	for _, group := range []struct {
+		collections []dao.Collection
+		access      txAccess
+	}{{scope.Reads, readAccess}, {scope.Writes, writeAccess}} {
+		for _, collection := range group.collections {
+			var target *txAccess
+
+			switch collection {
+			case dao.ProductsCollection:
+				target = &resolved.products
+			case dao.SellersCollection:
+				target = &resolved.sellers
+			case dao.CategoriesCollection:
+				target = &resolved.categories
+			case dao.ImagesCollection:
+				target = &resolved.images
+			default:
+				return resolved, dao.Errorf(dao.ErrStorageUnavailable, "unknown collection %q", collection)
+			}
+
+			*target = max(*target, group.access)
+		}
+	}
+
+	return resolved, nil
+}
+
+// transactFunc es la implementación de Storage.TransactIn de cada driver.
+type transactFunc func(scope txScope, fn func(uow dao.UnitOfWork) error) error
+
+// Transact ejecuta fn como una unidad de trabajo sobre las cuatro colecciones:
+// sus escrituras se confirman todas juntas o ninguna (ver dao.Transactor).
+// Mientras fn corre, las demás escrituras esperan.
+func (s *Storage) Transact(fn func(uow dao.UnitOfWork) error) error {
+	return s.TransactIn(dao.Scope{Writes: []dao.Collection{
+		dao.ProductsCollection, dao.SellersCollection, dao.CategoriesCollection, dao.ImagesCollection,
+	}}, fn)
+}
+
+// TransactIn es Transact sobre las colecciones de scope: esperan sólo las
+// escrituras de las colecciones que lee y todo lo que use las que escribe.
+func (s *Storage) TransactIn(scope dao.Scope, fn func(uow dao.UnitOfWork) error) error {
+	if s.transact == nil {
+		return dao.Errorf(dao.ErrStorageUnavailable, "storage driver doesn't support transactions")
+	}
+
+	resolved, err := resolveScope(scope)
+	if err != nil {
+		return err
+	}
+
+	return s.transact(resolved, fn)
+}
+
+// unitOfWork son las colecciones de una transacción.
+type unitOfWork struct {
+	products   dao.ProductDAO
+	sellers    dao.SellerDAO
+	categories dao.CategoryDAO
+	images     dao.ImageDAO
+}
+
+// newUnitOfWork arma las colecciones de una transacción con alcance scope.
+// Las que scope no incluye pueden venir en nil.
+func newUnitOfWork(
+	scope txScope,
+	products recordStore[models.Product],
+	sellers recordStore[models.Seller],
+	categories recordStore[models.Category],
+	images recordStore[models.Image],
+) *unitOfWork {
+	return &unitOfWork{
+		products:   &productDAL{CrudDAO: &collection[models.Product]{store: scoped(scope.products, dao.ProductsCollection, products)}},
+		sellers:    &sellerDAL{CrudDAO: &collection[models.Seller]{store: scoped(scope.sellers, dao.SellersCollection, sellers)}},
+		categories: &categoryDAL{CrudDAO: &collection[models.Category]{store: scoped(scope.categories, dao.CategoriesCollection, categories)}},
+		images:     &imageDAL{CrudDAO: &collection[models.Image]{store: scoped(scope.images, dao.ImagesCollection, images)}},
+	}
+}
+
+// scoped limita store a lo que la transacción tomó de la colección name.
+func scoped[T any](access txAccess, name dao.Collection, store recordStore[T]) recordStore[T] {
+	switch access {
+	case writeAccess:
+		return store
+	case readAccess:
+		return &readOnlyStore[T]{recordStore: store, name: name}
+	}
+
+	return &outOfScopeStore[T]{name: name}
+}
+
+// readOnlyStore es una colección que la transacción tomó sólo para leer.
+type readOnlyStore[T any] struct {
+	recordStore[T]
+	name dao.Collection
+}
+
+func (s *readOnlyStore[T]) update(fn func(tx recordTx[T]) error) error {
+	return dao.Errorf(dao.ErrStorageUnavailable, "collection %s is read-only in this transaction", s.name)
+}
+
+// outOfScopeStore es una colección que la transacción no tomó.
+type outOfScopeStore[T any] struct {
+	name dao.Collection
+}
+
+func (s *outOfScopeStore[T]) err() error {
+	return dao.Errorf(dao.ErrStorageUnavailable, "collection %s is not part of this transaction", s.name)
+}
+
+func (s *outOfScopeStore[T]) view(fn func(tx recordTx[T]) error) error   { return s.err() }
+func (s *outOfScopeStore[T]) update(fn func(tx recordTx[T]) error) error { return s.err() }
+func (s *outOfScopeStore[T]) lastChange() (dao.Change, error)            { return dao.Change{}, s.err() }
+func (s *outOfScopeStore[T]) history(id string) ([][]byte, error)        { return nil, s.err() }
+
+func (u *unitOfWork) Products() dao.ProductDAO    { return u.products }
+func (u *unitOfWork) Sellers() dao.SellerDAO      { return u.sellers }
+func (u *unitOfWork) Categories() dao.CategoryDAO { return u.categories }
+func (u *unitOfWork) Images() dao.ImageDAO        { return u.images }
+
+// nestedTx es un recordTx que admite escrituras anidadas. Dentro de una
+// transacción cada operación de collection corre en su propio savepoint: una
+// que falla (por ejemplo, un Modify rechazado) se descarta sin arrastrar a las
+// anteriores, y fn decide si sigue o aborta.
+type nestedTx[T any] interface {
+	recordTx[T]
+	// savepoint abre una escritura anidada. release(true) la incorpora a la
+	// transacción y release(false) la descarta.
+	savepoint() (child recordTx[T], release func(keep bool) error, err error)
+}
+
+// txStore es el recordStore de una colección dentro de una transacción. No
+// toma locks: la transacción ya los tiene.
+type txStore[T any] struct {
+	tx nestedTx[T]
+	// change y archived responden lastChange e history con los datos que
+	// había al empezar la transacción.
+	change   func() (dao.Change, error)
+	archived func(id string) ([][]byte, error)
+}
+
+func (s *txStore[T]) view(fn func(tx recordTx[T]) error) error {
+	return fn(s.tx)
+}
+
+func (s *txStore[T]) update(fn func(tx recordTx[T]) error) error {
+	child, release, err := s.tx.savepoint()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(child); err != nil {
+		return errors.Join(err, release(false))
+	}
+
+	return release(true)
+}
+
+func (s *txStore[T]) lastChange() (dao.Change, error) {
+	return s.change()
+}
+
+func (s *txStore[T]) history(id string) ([][]byte, error) {
+	return s.archived(id)
+}
+
+// pendingHistory agrega a versions las versiones de id que archivó la
+// transacción y todavía no se guardaron.
+func pendingHistory(versions [][]byte, archived []memoryRecord, id string) [][]byte {
+	for _, rec := range archived {
+		if rec.id == id {
+			versions = append(versions, rec.raw)
+		}
+	}
+
+	return versions
+}
@@ -0,0 +1,162 @@
+package dal
+
+import (
+	"fmt"
+	"project/internal/item_detail/repo/datasource/dao"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Restricciones unique: un campo string del modelo con el tag
+//
+//	unique:"true"    no se repite entre las entidades activas
+//	unique:"nocase"  ídem, sin distinguir mayúsculas de minúsculas
+//
+// Create, Update, Modify, Restore y Revert que repetirían el valor de otra
+// entidad fallan con *dao.UniqueViolation (ErrConflict). Los valores vacíos y
+// las entidades en la papelera no cuentan, y sólo se verifican los campos que
+// la escritura cambia: datos viejos que ya estaban repetidos se pueden seguir
+// editando.
+
+type uniqueField struct {
+	index  int
+	name   string // nombre JSON, para el error
+	nocase bool
+}
+
+// key normaliza el valor según la restricción.
+func (f uniqueField) key(value string) string {
+	if f.nocase {
+		return strings.ToLower(value)
+	}
+
+	return value
+}
+
+var uniqueFieldsCache sync.Map // reflect.Type -> []uniqueField
+
+// uniqueFields devuelve los campos de T con tag unique.
+func uniqueFields[T any]() []uniqueField {
+	t := reflect.TypeFor[T]()
+
+	if cached, ok := uniqueFieldsCache.Load(t); ok {
+		return cached.([]uniqueField)
+	}
+
+	var fields []uniqueField
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		option, ok := field.Tag.Lookup("unique")
+		if !ok {
+			continue
+		}
+
+		if field.Type.Kind() != reflect.String {
+			panic(fmt.Sprintf("dal: unique tag on non-string field %s.%s", t.Name(), field.Name))
+		}
+
+		unique := uniqueField{index: i, name: jsonName(field)}
+
+		switch option {
+		case "", "true":
+		case "nocase":
+			unique.nocase = true
+		default:
+			panic(fmt.Sprintf("dal: unknown unique option %q on %s.%s", option, t.Name(), field.Name))
+		}
+
+		fields = append(fields, unique)
+	}
+
+	uniqueFieldsCache.Store(t, fields)
+
+	return fields
+}
+
+// jsonName devuelve el nombre con el que field se serializa.
+func jsonName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+
+	return field.Name
+}
+
+// checkUnique verifica que entity no repita, en los campos unique que cambian
+// respecto de before (nil: todos), el valor de otra entidad activa.
+func checkUnique[T any](tx recordTx[T], entity *T, before *T) error {
+	fields := uniqueFields[T]()
+	if len(fields) == 0 || isDeleted(entity) {
+		return nil
+	}
+
+	value := reflect.ValueOf(entity).Elem()
+
+	var previous reflect.Value
+	if before != nil {
+		previous = reflect.ValueOf(before).Elem()
+	}
+
+	// Campos a verificar, con el valor ya normalizado.
+	wanted := map[int]string{}
+
+	for _, field := range fields {
+		current := value.Field(field.index).String()
+		if current == "" {
+			continue
+		}
+
+		if previous.IsValid() && field.key(previous.Field(field.index).String()) == field.key(current) {
+			continue
+		}
+
+		wanted[field.index] = field.key(current)
+	}
+
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	id := entityID(entity)
+
+	var violation *dao.UniqueViolation
+
+	err := tx.scan(func(item *T) bool {
+		if isDeleted(item) || entityID(item) == id {
+			return true
+		}
+
+		other := reflect.ValueOf(item).Elem()
+
+		for _, field := range fields {
+			key, ok := wanted[field.index]
+			if !ok || field.key(other.Field(field.index).String()) != key {
+				continue
+			}
+
+			violation = &dao.UniqueViolation{
+				Entity: strings.ToLower(reflect.TypeFor[T]().Name()),
+				Field:  field.name,
+				Value:  value.Field(field.index).String(),
+				ID:     entityID(item),
+			}
+
+			return false
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return fmt.Errorf("error reading entities: %w", err)
+	}
+
+	if violation != nil {
+		return violation
+	}
+
+	return nil
+}
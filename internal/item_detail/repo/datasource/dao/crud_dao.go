@@ -1,9 +1,161 @@
 package dao
 
+import (
+	models "project/pkg"
+	"time"
+)
+
 type CrudDAO[T any] interface {
 	Create(entity *T) (*T, error)
+	// GetByID no devuelve entidades borradas (ErrNotFound).
 	GetByID(id string) (*T, error)
+	// Find es GetByID eligiendo qué hacer con las entidades borradas.
+	Find(id string, deleted DeletedFilter) (*T, error)
 	GetAll(q string, limit int, offset int) ([]*T, error)
+	// List es GetAll con todos los filtros de ListQuery.
+	List(query ListQuery) ([]*T, error)
+	// Page es List más el total de resultados y los cursores de las páginas
+	// vecinas.
+	Page(query ListQuery) (Page[T], error)
+	// Update mezcla los campos no vacíos de entity en la entidad guardada. Si
+	// entity es Tracked y trae una versión distinta de cero, sólo se aplica
+	// cuando coincide con la guardada (si no, ErrVersionMismatch).
 	Update(entity *T, id string) (*T, error)
+	// Modify aplica mutate sobre la entidad guardada y la persiste en una sola
+	// operación atómica (lectura + escritura bajo el mismo lock).
+	Modify(id string, mutate func(entity *T) error) (*T, error)
+	// Delete manda la entidad a la papelera: deja de verse pero se puede
+	// recuperar con Restore hasta que Purge la elimine. Las entidades que no
+	// son Tracked se eliminan directamente.
 	Delete(id string) (bool, error)
+	// Restore saca la entidad de la papelera. Si no está borrada es ErrConflict.
+	Restore(id string) (*T, error)
+	// Purge elimina definitivamente las entidades borradas antes de before y
+	// devuelve cuántas eran.
+	Purge(before time.Time) (int, error)
+	// Revisions devuelve las versiones conocidas de la entidad (las anteriores
+	// guardadas en el historial más la actual), de la más vieja a la más nueva.
+	// Incluye las entidades borradas.
+	Revisions(id string) ([]Revision[T], error)
+	// Revision devuelve la versión rev de la entidad (ErrNotFound si no existe).
+	Revision(id string, rev int64) (*Revision[T], error)
+	// Revert vuelve la entidad al contenido de la versión rev, guardándolo como
+	// una versión nueva. Con expected distinto de cero se aplica sólo si la
+	// versión actual coincide (si no, ErrVersionMismatch).
+	Revert(id string, rev int64, expected int64) (*T, error)
+	// LastChange identifica la última escritura sobre la colección, para
+	// validar cachés de listados sin volver a leerlos.
+	LastChange() (Change, error)
+	// As devuelve el mismo DAO pero registrando actor como autor de las
+	// escrituras (CreatedBy/UpdatedBy).
+	As(actor string) CrudDAO[T]
+}
+
+// ListQuery son los filtros y la paginación de un listado. Los valores cero
+// no filtran; Limit <= 0 usa el default (10).
+type ListQuery struct {
+	// Q filtra por substring sobre `Name`, sin distinguir mayúsculas.
+	Q      string
+	Limit  int
+	Offset int
+	// UpdatedSince deja sólo las entidades Tracked con UpdatedAt >= UpdatedSince.
+	UpdatedSince time.Time
+	// Deleted decide si entran las entidades borradas (por defecto no).
+	Deleted DeletedFilter
+	// Equals deja sólo las entidades cuyo campo string (por nombre JSON) vale
+	// exactamente lo indicado. Los campos con tag index:"true" se resuelven
+	// con un índice, sin recorrer la colección.
+	Equals map[string]string
+	// Filters son comparaciones sobre campos del modelo (por nombre JSON);
+	// tienen que cumplirse todas. Un campo u operador que no existe, o un
+	// valor que no es del tipo del campo, es ErrInvalidQuery.
+	Filters []Filter
+	// Sort ordena por esos campos, en orden de prioridad. Sin Sort (o entre
+	// empates) se mantiene el orden de alta.
+	Sort []SortKey
+	// Cursor es un Page.NextCursor o Page.PrevCursor de una página anterior;
+	// reemplaza a Offset. Sigue siendo válido aunque se agreguen o borren
+	// entidades, pero sólo con el mismo Sort con el que se emitió.
+	Cursor string
+}
+
+// Page es una página de un listado.
+type Page[T any] struct {
+	Items []*T `json:"items"`
+	// Total es la cantidad de entidades que cumplen los filtros, en todas las páginas.
+	Total int `json:"total"`
+	// NextCursor y PrevCursor son opacos; vacíos si no hay página siguiente
+	// (o anterior).
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// FilterOp es el operador de un Filter.
+type FilterOp string
+
+const (
+	OpEq  FilterOp = "eq"
+	OpNe  FilterOp = "ne"
+	OpGt  FilterOp = "gt"
+	OpGte FilterOp = "gte"
+	OpLt  FilterOp = "lt"
+	OpLte FilterOp = "lte"
+	// OpIn recibe los valores en Values o, si es nil, separados por comas
+	// en Value.
+	OpIn FilterOp = "in"
+	// OpContains es un substring sin distinguir mayúsculas (sólo en strings).
+	OpContains FilterOp = "contains"
+)
+
+// Filter compara el campo Field con Value. Value va como texto y se
+// interpreta según el tipo del campo: número, bool, fecha RFC 3339 o string.
+// Values es la lista de OpIn armada en código, que no se separa por comas
+// (un ID puede tenerlas); vacía no coincide con nada.
+type Filter struct {
+	Field  string
+	Op     FilterOp
+	Value  string
+	Values []string
+}
+
+// SortKey es un criterio de orden: el campo (nombre JSON) y la dirección.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// DeletedFilter indica qué hacer con las entidades borradas en una lectura.
+type DeletedFilter int
+
+const (
+	// ExcludeDeleted las oculta (default).
+	ExcludeDeleted DeletedFilter = iota
+	// IncludeDeleted devuelve activas y borradas.
+	IncludeDeleted
+	// OnlyDeleted devuelve sólo las borradas (la papelera).
+	OnlyDeleted
+)
+
+// Tracked lo implementan las entidades que embeben models.Metadata. El
+// storage la completa al crear (Version 1) y la actualiza en cada
+// Update/Modify; lo que mande el cliente en esos campos se ignora.
+type Tracked interface {
+	Meta() *models.Metadata
+}
+
+// Revision es una versión de una entidad: Rev es su Version y Entity el
+// contenido que tenía en ese momento.
+type Revision[T any] struct {
+	Rev       int64     `json:"rev"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	Deleted   bool      `json:"deleted"`
+	Entity    *T        `json:"entity,omitempty"`
+}
+
+// Change es el estado de una colección: Token cambia con cada escritura y
+// At es el momento de la última (cero si la colección nunca se escribió).
+type Change struct {
+	Token string
+	At    time.Time
 }
@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Categorías de error que cualquier implementación de CrudDAO devuelve, para
+// que los callers decidan con errors.Is y no leyendo el mensaje.
+var (
+	// ErrNotFound: no existe una entidad con el ID pedido.
+	ErrNotFound = errors.New("entity not found")
+	// ErrConflict: la escritura choca con una entidad existente (por ejemplo, un ID repetido).
+	ErrConflict = errors.New("entity conflict")
+	// ErrInvalidUpdate: el cambio pedido no es aplicable (vacío o rechazado).
+	ErrInvalidUpdate = errors.New("invalid update")
+	// ErrInvalidReference: la entidad apunta a otra que no existe (por ejemplo, un
+	// producto con una categoría borrada).
+	ErrInvalidReference = errors.New("invalid reference")
+	// ErrVersionMismatch: la versión esperada no es la guardada (alguien la modificó antes).
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrInvalidQuery: los filtros u orden del listado no aplican al modelo
+	// (campo u operador desconocido, valor de otro tipo).
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrStorageUnavailable: el backend no se pudo leer o escribir.
+	ErrStorageUnavailable = errors.New("storage unavailable")
+)
+
+// storageError conserva el mensaje original y agrega la categoría, así
+// errors.Is funciona con la categoría y con los errores envueltos en el mensaje.
+type storageError struct {
+	kind error
+	err  error
+}
+
+func (e *storageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *storageError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Errorf arma un error de la categoría kind con el mensaje formateado
+// (admite %w igual que fmt.Errorf).
+func Errorf(kind error, format string, args ...any) error {
+	return &storageError{kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+// UniqueViolation es el ErrConflict de una restricción unique: la entidad ID
+// ya usa Value en el campo Field.
+type UniqueViolation struct {
+	Entity string // tipo de la entidad (category, image, ...)
+	Field  string // nombre JSON del campo
+	Value  string
+	ID     string // la entidad que ya lo usa
+}
+
+func (e *UniqueViolation) Error() string {
+	return fmt.Sprintf("%s %q is already used by %s %q", e.Field, e.Value, e.Entity, e.ID)
+}
+
+func (e *UniqueViolation) Unwrap() error {
+	return ErrConflict
+}
+
+// Classified indica si err ya pertenece a alguna de las categorías.
+func Classified(err error) bool {
+	return errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrConflict) ||
+		errors.Is(err, ErrInvalidUpdate) ||
+		errors.Is(err, ErrInvalidReference) ||
+		errors.Is(err, ErrVersionMismatch) ||
+		errors.Is(err, ErrInvalidQuery) ||
+		errors.Is(err, ErrStorageUnavailable)
+}
@@ -0,0 +1,45 @@
+package dao
+
+// UnitOfWork da las colecciones de una transacción en curso: lo que se lea
+// por ellas ve las escrituras anteriores de la misma transacción, y lo que se
+// escriba se confirma junto al terminar o no se confirma.
+type UnitOfWork interface {
+	Products() ProductDAO
+	Sellers() SellerDAO
+	Categories() CategoryDAO
+	Images() ImageDAO
+}
+
+// Collection nombra una de las colecciones de un UnitOfWork.
+type Collection string
+
+const (
+	ProductsCollection   Collection = "products"
+	SellersCollection    Collection = "sellers"
+	CategoriesCollection Collection = "categories"
+	ImagesCollection     Collection = "images"
+)
+
+// Scope son las colecciones que usa una transacción: las de Writes se toman
+// para escribir y las de Reads sólo para leer. Las demás no se bloquean, y
+// usarlas desde el UnitOfWork falla con ErrStorageUnavailable, igual que
+// escribir en una de Reads.
+type Scope struct {
+	Writes []Collection
+	Reads  []Collection
+}
+
+// Transactor ejecuta escrituras sobre varias colecciones como una sola
+// operación. Si fn devuelve error (o la confirmación falla) no queda aplicada
+// ninguna de sus escrituras.
+//
+// Los DAO de uow sólo sirven dentro de fn: usarlos después es un error de
+// programación.
+type Transactor interface {
+	// Transact toma las cuatro colecciones para escribir.
+	Transact(fn func(uow UnitOfWork) error) error
+	// TransactIn toma sólo las colecciones de scope: mientras fn corre, las
+	// lecturas de las de Reads y todo lo que use otras colecciones sigue sin
+	// esperar.
+	TransactIn(scope Scope, fn func(uow UnitOfWork) error) error
+}
@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"net/url"
+	"project/internal/item_detail/repo/datasource/dao"
 	"project/internal/item_detail/service"
 	"project/internal/item_detail/utils"
+	"slices"
 	"strconv"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -16,17 +21,54 @@ import (
 
 var (
 	validate = validator.New()
-	lock     = sync.Mutex{}
 )
 
 type CrudHandler[T any] struct {
-	service *service.CrudService[T]
+	service  *service.CrudService[T]
+	facets   *service.ProductFacets
+	expander service.Expander[T]
 }
 
 func NewCrudHandler[T any](s *service.CrudService[T]) *CrudHandler[T] {
 	return &CrudHandler[T]{service: s}
 }
 
+// WithFacets hace que los listados respondan facets cuando se piden con
+// ?facets= (sólo tiene sentido en los listados de productos).
+func (h *CrudHandler[T]) WithFacets(facets *service.ProductFacets) *CrudHandler[T] {
+	h.facets = facets
+	return h
+}
+
+// WithExpander hace que el GET por ID y los listados agreguen las entidades
+// relacionadas que se piden con ?expand=.
+func (h *CrudHandler[T]) WithExpander(expander service.Expander[T]) *CrudHandler[T] {
+	h.expander = expander
+	return h
+}
+
+// listPage es una página de un listado con las entidades expandidas o con
+// facets.
+type listPage struct {
+	Items      any            `json:"items"`
+	Total      int            `json:"total"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+	Facets     service.Facets `json:"facets,omitempty"`
+}
+
+// expandFields interpreta ?expand=; nil si no se pidió o si el handler no
+// expande.
+func (h *CrudHandler[T]) expandFields(c echo.Context) ([]string, error) {
+	raw := c.QueryParam("expand")
+
+	if raw == "" || h.expander == nil {
+		return nil, nil
+	}
+
+	return h.expander.ParseExpand(raw)
+}
+
 func BindJSON(c echo.Context, entity interface{}) error {
 	err := c.Bind(entity)
 	if err == nil {
@@ -46,10 +88,6 @@ func BindJSON(c echo.Context, entity interface{}) error {
 }
 
 func (h *CrudHandler[T]) CreateEntity(c echo.Context) error {
-	// Evito problemas de concurrencia
-	lock.Lock()
-	defer lock.Unlock()
-
 	var entity T
 
 	if err := BindJSON(c, &entity); err != nil {
@@ -62,58 +100,356 @@ func (h *CrudHandler[T]) CreateEntity(c echo.Context) error {
 		return utils.ValidateBody(c, err)
 	}
 
-	createdEntity, err := h.service.RegisterEntity(&entity)
+	createdEntity, err := h.service.As(utils.Actor(c)).RegisterEntity(&entity)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return utils.StorageError(c, err)
 	}
 
+	utils.SetETag(c, createdEntity)
+
 	return c.JSON(http.StatusCreated, createdEntity)
 }
 
 func (h *CrudHandler[T]) GetAllEntities(c echo.Context) error {
-	lock.Lock()
-	defer lock.Unlock()
+	query, err := listQuery(c)
 
-	q := c.QueryParam("q")
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
 
-	entities, err := h.service.FetchEntities(q, limit, offset)
+	return h.list(c, query)
+}
+
+// GetTrash lista la papelera: las entidades borradas que todavía no se purgaron.
+func (h *CrudHandler[T]) GetTrash(c echo.Context) error {
+	query, err := listQuery(c)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, entities)
+	query.Deleted = dao.OnlyDeleted
+
+	return h.list(c, query)
 }
 
-func (h *CrudHandler[T]) GetEntityByID(c echo.Context) error {
-	lock.Lock()
-	defer lock.Unlock()
+func (h *CrudHandler[T]) list(c echo.Context, query dao.ListQuery) error {
+	// El ETag del listado sale del estado de la colección (más la query), así
+	// un 304 no necesita leer las entidades.
+	change, err := h.service.LastChange()
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	token, modified := change.Token, change.At
+
+	expand, err := h.expandFields(c)
+
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if expand != nil {
+		// Las entidades expandidas también cambian la respuesta.
+		related, err := h.expander.LastChange()
+
+		if err != nil {
+			return utils.StorageError(c, err)
+		}
+
+		token = token + "," + related.Token
+
+		if related.At.After(modified) {
+			modified = related.At
+		}
+	}
+
+	var facets *service.FacetRequest
+
+	if names := c.QueryParam("facets"); names != "" && h.facets != nil {
+		request, err := service.ParseFacetRequest(names, c.QueryParam("price_buckets"))
 
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		// Los facets traen nombres de categorías y vendedores: si cambian,
+		// cambia el listado.
+		names, err := h.facets.Token()
+
+		if err != nil {
+			return utils.StorageError(c, err)
+		}
+
+		facets, token = &request, token+","+names
+	}
+
+	etag := utils.CompositeETag(true, token, c.Request().URL.Path, c.QueryString())
+
+	if utils.NotModified(c, etag, modified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	page, err := h.service.PageEntities(query)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	if links := pageLinks(c, page.NextCursor, page.PrevCursor); links != "" {
+		c.Response().Header().Set("Link", links)
+	}
+
+	if facets == nil && expand == nil {
+		return c.JSON(http.StatusOK, page)
+	}
+
+	body := listPage{Items: page.Items, Total: page.Total, NextCursor: page.NextCursor, PrevCursor: page.PrevCursor}
+
+	if expand != nil {
+		if body.Items, err = h.expander.Expand(page.Items, expand); err != nil {
+			return utils.StorageError(c, err)
+		}
+	}
+
+	if facets != nil {
+		if body.Facets, err = h.facets.Facets(query, *facets); err != nil {
+			return utils.StorageError(c, err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, body)
+}
+
+// pageLinks arma el header Link (RFC 8288) del listado: first, y next/prev
+// con los cursores de la página. Conserva el resto de los query params.
+func pageLinks(c echo.Context, next string, prev string) string {
+	params := c.QueryParams()
+	params.Del("offset")
+	params.Del("cursor")
+
+	link := func(rel string, cursor string) string {
+		values := maps.Clone(params)
+		if cursor != "" {
+			values.Set("cursor", cursor)
+		}
+
+		target := url.URL{Path: c.Request().URL.Path, RawQuery: values.Encode()}
+
+		return fmt.Sprintf("<%s>; rel=%q", target.String(), rel)
+	}
+
+	links := []string{link("first", "")}
+
+	if prev != "" {
+		links = append(links, link("prev", prev))
+	}
+
+	if next != "" {
+		links = append(links, link("next", next))
+	}
+
+	return strings.Join(links, ", ")
+}
+
+// ListBy lista las entidades cuyo field vale el :id de la ruta (por ejemplo,
+// los productos de una categoría). parent responde si ese :id existe.
+func (h *CrudHandler[T]) ListBy(field string, parent func(id string) error) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		query, err := listQuery(c)
+
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		id := c.Param("id")
+
+		if err := parent(id); err != nil {
+			return utils.StorageError(c, err)
+		}
+
+		query.Equals = map[string]string{field: id}
+
+		return h.list(c, query)
+	}
+}
+
+// listQuery arma el ListQuery a partir de los query params del listado.
+func listQuery(c echo.Context) (dao.ListQuery, error) {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	query := dao.ListQuery{Q: c.QueryParam("q"), Limit: limit, Offset: offset, Cursor: c.QueryParam("cursor")}
+
+	if query.Cursor != "" && c.QueryParam("offset") != "" {
+		return query, fmt.Errorf("invalid offset: can't be combined with cursor")
+	}
+
+	if since := c.QueryParam("updated_since"); since != "" {
+		updatedSince, err := time.Parse(time.RFC3339Nano, since)
+
+		if err != nil {
+			return query, fmt.Errorf("invalid updated_since: must be an RFC 3339 date")
+		}
+
+		query.UpdatedSince = updatedSince
+	}
+
+	deleted, err := deletedFilter(c)
+	query.Deleted = deleted
+
+	if err != nil {
+		return query, err
+	}
+
+	if query.Filters, err = fieldFilters(c); err != nil {
+		return query, err
+	}
+
+	query.Sort, err = sortKeys(c.QueryParam("sort"))
+
+	return query, err
+}
+
+// fieldFilters interpreta los parámetros campo[operador]=valor, por ejemplo
+// ?price[lte]=50&stock[gt]=0. El campo y el operador los valida el storage.
+func fieldFilters(c echo.Context) ([]dao.Filter, error) {
+	params := c.QueryParams()
+	filters := []dao.Filter{}
+
+	// Orden fijo, para que el mismo query string arme siempre la misma query.
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		field, op, ok := strings.Cut(key, "[")
+		if !ok {
+			continue
+		}
+
+		op, closed := strings.CutSuffix(op, "]")
+		if !closed || field == "" || op == "" || strings.ContainsAny(op, "[]") {
+			return nil, fmt.Errorf("invalid filter %q: must be field[operator]=value", key)
+		}
+
+		for _, value := range params[key] {
+			filters = append(filters, dao.Filter{Field: field, Op: dao.FilterOp(op), Value: value})
+		}
+	}
+
+	return filters, nil
+}
+
+// sortKeys interpreta ?sort=-sales_number,price: campos separados por coma,
+// con "-" adelante para orden descendente.
+func sortKeys(raw string) ([]dao.SortKey, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var keys []dao.SortKey
+
+	for _, field := range strings.Split(raw, ",") {
+		field, desc := strings.CutPrefix(strings.TrimSpace(field), "-")
+
+		if field == "" {
+			return nil, fmt.Errorf("invalid sort %q: must be a comma separated list of fields", raw)
+		}
+
+		keys = append(keys, dao.SortKey{Field: field, Desc: desc})
+	}
+
+	return keys, nil
+}
+
+// deletedFilter interpreta ?include_deleted=true.
+func deletedFilter(c echo.Context) (dao.DeletedFilter, error) {
+	raw := c.QueryParam("include_deleted")
+
+	if raw == "" {
+		return dao.ExcludeDeleted, nil
+	}
+
+	include, err := strconv.ParseBool(raw)
+
+	if err != nil {
+		return dao.ExcludeDeleted, fmt.Errorf("invalid include_deleted: must be true or false")
+	}
+
+	if include {
+		return dao.IncludeDeleted, nil
+	}
+
+	return dao.ExcludeDeleted, nil
+}
+
+func (h *CrudHandler[T]) GetEntityByID(c echo.Context) error {
 	id := c.Param("id")
 
-	entity, err := h.service.FetchEntity(id)
+	deleted, err := deletedFilter(c)
+
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	expand, err := h.expandFields(c)
 
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, entity)
+	entity, err := h.service.FindEntity(id, deleted)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	if expand == nil {
+		return utils.CachedJSON(c, http.StatusOK, entity, utils.EntityETag(entity), utils.EntityModified(entity))
+	}
+
+	// La respuesta expandida cambia si cambia la entidad o alguna de las
+	// colecciones relacionadas.
+	related, err := h.expander.LastChange()
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	etag := utils.CompositeETag(true, utils.EntityETag(entity), related.Token, strings.Join(expand, ","))
+	modified := utils.EntityModified(entity)
+
+	if related.At.After(modified) {
+		modified = related.At
+	}
+
+	if utils.NotModified(c, etag, modified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	expanded, err := h.expander.Expand([]*T{entity}, expand)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, expanded[0])
 }
 
 func (h *CrudHandler[T]) UpdateEntity(c echo.Context) error {
-	// Evito problemas de concurrencia
-	lock.Lock()
-	defer lock.Unlock()
-
 	var entity T
 
 	if err := BindJSON(c, &entity); err != nil {
@@ -122,32 +458,48 @@ func (h *CrudHandler[T]) UpdateEntity(c echo.Context) error {
 		})
 	}
 
+	// If-Match: el dao rechaza el update si la entidad cambió desde esa
+	// versión. La versión del body no cuenta.
+	if err := utils.ExpectVersion(c, &entity); err != nil {
+		return utils.StorageError(c, err)
+	}
+
 	id := c.Param("id")
 
-	updatedEntity, err := h.service.PatchEntity(&entity, id)
+	updatedEntity, err := h.service.As(utils.Actor(c)).PatchEntity(&entity, id)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return utils.StorageError(c, err)
 	}
 
+	utils.SetETag(c, updatedEntity)
+
 	return c.JSON(http.StatusAccepted, updatedEntity)
 }
 
 func (h *CrudHandler[T]) DeleteEntity(c echo.Context) error {
-	lock.Lock()
-	defer lock.Unlock()
-
 	id := c.Param("id")
 
-	deleted, err := h.service.DeleteEntity(id)
+	deleted, err := h.service.As(utils.Actor(c)).DeleteEntity(id)
 
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": err.Error(),
-		})
+		return utils.StorageError(c, err)
 	}
 
 	return c.JSON(http.StatusNoContent, deleted)
 }
+
+// RestoreEntity saca una entidad de la papelera.
+func (h *CrudHandler[T]) RestoreEntity(c echo.Context) error {
+	id := c.Param("id")
+
+	restored, err := h.service.As(utils.Actor(c)).RestoreEntity(id)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	utils.SetETag(c, restored)
+
+	return c.JSON(http.StatusOK, restored)
+}
@@ -1,10 +1,14 @@
 package rest
 
 import (
+	"errors"
 	"net/http"
+	"project/internal/item_detail/repo/datasource/dao"
 	"project/internal/item_detail/service"
 	"project/internal/item_detail/utils"
 	models "project/pkg"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -20,10 +24,6 @@ func NewProductHandler(s *service.ProductService) *ProductHandler {
 }
 
 func (h *ProductHandler) GetProduct(c echo.Context) (*models.Product, error) {
-	// Evito problemas de concurrencia
-	lock.Lock()
-	defer lock.Unlock()
-
 	id := c.Param("id")
 
 	product, err := h.service.GetProduct(id)
@@ -35,106 +35,149 @@ func (h *ProductHandler) GetProduct(c echo.Context) (*models.Product, error) {
 	return product, nil
 }
 
+// ChangeCategories cambia la categoría del producto. La verificación de la
+// categoría y la escritura van en una transacción.
 func (h *ProductHandler) ChangeCategories(c echo.Context) error {
-	// Evito problemas de concurrencia
-	lock.Lock()
-	defer lock.Unlock()
-
 	var entity utils.ChangeAttributePayload
 
-	categoryService := h.service.GetCategoryService()
-
-	return utils.ChangeAttribute(
-		c,
-		entity,
-		h.service,
-		categoryService,
-		func(p *models.Product, ID string) {
-			p.CategoryId = ID
-		},
-	)
+	if err := c.Bind(&entity); err != nil {
+		return utils.ValidateBody(c, err)
+	}
+
+	expected, err := utils.IfMatchVersion(c)
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	updated, err := h.service.As(utils.Actor(c)).ChangeCategory(c.Param("id"), entity.ID, expected)
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	utils.SetETag(c, updated)
+
+	return c.JSON(http.StatusCreated, updated)
 }
 
+// AddImages agrega una imagen al producto. Con {"id"} usa una existente; con
+// name y url la crea en la misma operación, así que no quedan imágenes
+// huérfanas si el producto no se puede modificar.
 func (h *ProductHandler) AddImages(c echo.Context) error {
-	// Evito problemas de concurrencia
-	lock.Lock()
-	defer lock.Unlock()
+	var entity utils.AddImagePayload
 
-	var entity utils.ChangeAttributePayload
+	if err := BindJSON(c, &entity); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	expected, err := utils.IfMatchVersion(c)
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
 
-	ImageService := h.service.GetImageService()
+	var updated *models.Product
 
-	return utils.ChangeAttribute(
-		c,
-		entity,
-		h.service,
-		ImageService,
-		func(p *models.Product, ID string) {
-			images := append(p.Images, ID)
+	if entity.NewImage() {
+		image := &models.Image{ID: entity.ID, Name: entity.Name, URL: entity.URL}
 
-			p.Images = images
-		},
-	)
+		if err := validate.Struct(image); err != nil {
+			return utils.ValidateBody(c, err)
+		}
+
+		updated, err = h.service.As(utils.Actor(c)).AddNewImage(c.Param("id"), image, expected)
+	} else {
+		updated, err = h.service.As(utils.Actor(c)).AddImage(c.Param("id"), entity.ID, expected)
+	}
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	utils.SetETag(c, updated)
+
+	return c.JSON(http.StatusCreated, updated)
 }
 
+// ChangeSellers cambia el vendedor del producto. La verificación del
+// vendedor y la escritura van en una transacción.
 func (h *ProductHandler) ChangeSellers(c echo.Context) error {
-	// Evito problemas de concurrencia
-	lock.Lock()
-	defer lock.Unlock()
-
 	var entity utils.ChangeAttributePayload
 
-	sellerService := h.service.GetSellerService()
-
-	return utils.ChangeAttribute(
-		c,
-		entity,
-		h.service,
-		sellerService,
-		func(p *models.Product, ID string) {
-			p.SellerId = ID
-		},
-	)
+	if err := c.Bind(&entity); err != nil {
+		return utils.ValidateBody(c, err)
+	}
+
+	expected, err := utils.IfMatchVersion(c)
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	updated, err := h.service.As(utils.Actor(c)).ChangeSeller(c.Param("id"), entity.ID, expected)
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	utils.SetETag(c, updated)
+
+	return c.JSON(http.StatusCreated, updated)
+}
+
+// CreateWithImages crea un producto junto con sus imágenes nuevas: se guardan
+// todas o ninguna.
+func (h *ProductHandler) CreateWithImages(c echo.Context) error {
+	var payload utils.CreateWithImagesPayload
+
+	if err := BindJSON(c, &payload); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return utils.ValidateBody(c, err)
+	}
+
+	created, err := h.service.As(utils.Actor(c)).CreateWithImages(&payload.Product, payload.Images)
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	utils.SetETag(c, created)
+
+	return c.JSON(http.StatusCreated, created)
 }
 
 func (h *ProductHandler) GetCategories(c echo.Context) error {
 	product, err := h.GetProduct(c)
 
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": err.Error(),
-		})
+		return utils.StorageError(c, err)
 	}
 
 	category, categoryErr := h.service.GetCategoryService().GetByID(product.CategoryId)
 
 	if categoryErr != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": categoryErr.Error(),
-		})
+		return utils.StorageError(c, categoryErr)
 	}
 
-	return c.JSON(http.StatusOK, category)
+	return utils.CachedJSON(c, http.StatusOK, category, relatedETag(category.ID, category.Metadata), latest(product.UpdatedAt, category.UpdatedAt))
 }
 
 func (h *ProductHandler) GetSellers(c echo.Context) error {
 	product, err := h.GetProduct(c)
 
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": err.Error(),
-		})
+		return utils.StorageError(c, err)
 	}
 
 	seller, sellerErr := h.service.GetSellerService().GetByID(product.SellerId)
 
 	if sellerErr != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": sellerErr.Error(),
-		})
+		return utils.StorageError(c, sellerErr)
 	}
 
-	return c.JSON(http.StatusOK, seller)
+	return utils.CachedJSON(c, http.StatusOK, seller, relatedETag(seller.ID, seller.Metadata), latest(product.UpdatedAt, seller.UpdatedAt))
 }
 
 func (h *ProductHandler) GetImages(c echo.Context) error {
@@ -143,44 +186,66 @@ func (h *ProductHandler) GetImages(c echo.Context) error {
 	var response []*models.Image
 
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": err.Error(),
-		})
+		return utils.StorageError(c, err)
 	}
 
+	parts := []string{}
+	modified := product.UpdatedAt
+
 	for _, id := range product.Images {
 		image, imageErr := h.service.GetImageService().GetByID(id)
 
+		if errors.Is(imageErr, dao.ErrNotFound) {
+			continue // imagen borrada: no rompe el listado
+		}
+
 		if imageErr != nil {
-			continue
+			return utils.StorageError(c, imageErr)
 		}
 
 		response = append(response, image)
+		parts = append(parts, image.ID, strconv.FormatInt(image.Version, 10))
+		modified = latest(modified, image.UpdatedAt)
 	}
 
-	return c.JSON(http.StatusOK, response)
+	return utils.CachedJSON(c, http.StatusOK, response, utils.CompositeETag(false, parts...), modified)
 }
 
 func (h *ProductHandler) GetCharacteristic(c echo.Context) error {
 	product, err := h.GetProduct(c)
 
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": err.Error(),
-		})
+		return utils.StorageError(c, err)
 	}
 
-	return c.JSON(http.StatusCreated, product.Characteristics)
+	return utils.CachedJSON(c, http.StatusCreated, product.Characteristics, utils.EntityETag(product), product.UpdatedAt)
 }
 
 func (h *ProductHandler) GetDetails(c echo.Context) error {
 	product, err := h.GetProduct(c)
 
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": err.Error(),
-		})
+		return utils.StorageError(c, err)
+	}
+
+	return utils.CachedJSON(c, http.StatusCreated, product.Details, utils.EntityETag(product), product.UpdatedAt)
+}
+
+// relatedETag identifica la entidad relacionada que devuelve un sub-recurso:
+// cambia si el producto apunta a otra o si esa entidad se modifica.
+func relatedETag(id string, meta models.Metadata) string {
+	return utils.CompositeETag(false, id, strconv.FormatInt(meta.Version, 10))
+}
+
+// latest devuelve el más reciente de los momentos dados.
+func latest(times ...time.Time) time.Time {
+	var max time.Time
+
+	for _, t := range times {
+		if t.After(max) {
+			max = t
+		}
 	}
 
-	return c.JSON(http.StatusCreated, product.Details)
+	return max
 }
@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"net/http"
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/utils"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// revisionDiff es la respuesta de GET /:id/revisions/diff.
+type revisionDiff struct {
+	From    int64               `json:"from"`
+	To      int64               `json:"to"`
+	Changes []utils.FieldChange `json:"changes"`
+}
+
+// GetRevisions lista las versiones de la entidad (sin su contenido), de la
+// más vieja a la actual.
+func (h *CrudHandler[T]) GetRevisions(c echo.Context) error {
+	revisions, err := h.service.FetchRevisions(c.Param("id"))
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	summaries := make([]dao.Revision[T], len(revisions))
+
+	for i, rev := range revisions {
+		rev.Entity = nil
+		summaries[i] = rev
+	}
+
+	last := revisions[len(revisions)-1]
+	etag := utils.CompositeETag(true, c.Param("id"), strconv.FormatInt(last.Rev, 10))
+
+	return utils.CachedJSON(c, http.StatusOK, summaries, etag, last.UpdatedAt)
+}
+
+// GetRevision devuelve una versión completa. Las versiones no cambian, así
+// que su ETag tampoco.
+func (h *CrudHandler[T]) GetRevision(c echo.Context) error {
+	rev, err := strconv.ParseInt(c.Param("rev"), 10, 64)
+
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid revision: must be a number",
+		})
+	}
+
+	revision, err := h.service.FetchRevision(c.Param("id"), rev)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	etag := utils.CompositeETag(false, c.Param("id"), strconv.FormatInt(revision.Rev, 10))
+
+	return utils.CachedJSON(c, http.StatusOK, revision, etag, revision.UpdatedAt)
+}
+
+// GetRevisionDiff compara dos versiones campo a campo: ?from=<rev> (requerido)
+// y ?to=<rev> (por defecto, la actual).
+func (h *CrudHandler[T]) GetRevisionDiff(c echo.Context) error {
+	from, fromErr := strconv.ParseInt(c.QueryParam("from"), 10, 64)
+
+	var (
+		to    int64
+		toErr error
+	)
+
+	if raw := c.QueryParam("to"); raw != "" {
+		to, toErr = strconv.ParseInt(raw, 10, 64)
+	}
+
+	if fromErr != nil || toErr != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid revisions: from is required and both must be numbers",
+		})
+	}
+
+	revisions, err := h.service.FetchRevisions(c.Param("id"))
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	if to == 0 {
+		to = revisions[len(revisions)-1].Rev
+	}
+
+	var before, after *dao.Revision[T]
+
+	for i := range revisions {
+		switch revisions[i].Rev {
+		case from:
+			before = &revisions[i]
+		case to:
+			after = &revisions[i]
+		}
+	}
+
+	if from == to {
+		after = before
+	}
+
+	if before == nil || after == nil {
+		return utils.StorageError(c, dao.Errorf(dao.ErrNotFound, "Can't find revisions %d and %d of entity with ID %v", from, to, c.Param("id")))
+	}
+
+	changes, err := utils.DiffFields(before.Entity, after.Entity, utils.MetadataFields...)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	etag := utils.CompositeETag(false, c.Param("id"), strconv.FormatInt(from, 10), strconv.FormatInt(to, 10))
+
+	return utils.CachedJSON(c, http.StatusOK, revisionDiff{From: from, To: to, Changes: changes}, etag, after.UpdatedAt)
+}
+
+// RevertRevision guarda el contenido de una versión anterior como versión
+// nueva. Respeta If-Match igual que PATCH.
+func (h *CrudHandler[T]) RevertRevision(c echo.Context) error {
+	rev, err := strconv.ParseInt(c.Param("rev"), 10, 64)
+
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid revision: must be a number",
+		})
+	}
+
+	expected, err := utils.IfMatchVersion(c)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	reverted, err := h.service.As(utils.Actor(c)).RevertEntity(c.Param("id"), rev, expected)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	utils.SetETag(c, reverted)
+
+	return c.JSON(http.StatusOK, reverted)
+}
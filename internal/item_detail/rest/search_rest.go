@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"net/http"
+	"project/internal/item_detail/service"
+	"project/internal/item_detail/utils"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SearchHandler struct {
+	search *service.ProductSearch
+	facets *service.ProductFacets
+}
+
+func NewSearchHandler(s *service.ProductSearch, facets *service.ProductFacets) *SearchHandler {
+	return &SearchHandler{search: s, facets: facets}
+}
+
+// Search responde GET /products/search?q=: los productos más relevantes
+// para q, con los fragmentos donde aparece. Acepta los mismos filtros
+// campo[operador]=valor que el listado. Pagina con limit/offset; con
+// ?facets= agrega los conteos sobre todos los resultados.
+func (h *SearchHandler) Search(c echo.Context) error {
+	q := strings.TrimSpace(c.QueryParam("q"))
+
+	if q == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "q is required",
+		})
+	}
+
+	var facets *service.FacetRequest
+
+	if names := c.QueryParam("facets"); names != "" {
+		request, err := service.ParseFacetRequest(names, c.QueryParam("price_buckets"))
+
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		facets = &request
+	}
+
+	filters, err := fieldFilters(c)
+
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	page, scope, err := h.search.Search(q, filters, limit, offset)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	if facets != nil {
+		if page.Facets, err = h.facets.Facets(scope, *facets); err != nil {
+			return utils.StorageError(c, err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// Suggest responde GET /products/suggest?prefix=: hasta limit compleciones
+// (10 por omisión) entre los nombres de productos y de categorías.
+func (h *SearchHandler) Suggest(c echo.Context) error {
+	prefix := strings.TrimSpace(c.QueryParam("prefix"))
+
+	if prefix == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "prefix is required",
+		})
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	suggestions, err := h.search.Suggest(prefix, limit)
+
+	if err != nil {
+		return utils.StorageError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, suggestions)
+}
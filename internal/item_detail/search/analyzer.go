@@ -0,0 +1,177 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Análisis de texto: el mismo para indexar y para las consultas. El texto se
+// parte en palabras (letras y dígitos), se pasa a minúsculas, se le sacan los
+// acentos ("Canción" y "cancion" son la misma palabra), se descartan las
+// stopwords y se reduce cada palabra a su raíz con un stemmer liviano de
+// español ("auriculares" y "auricular" van al mismo término).
+
+// Token es una palabra del texto original con el término al que se reduce.
+type Token struct {
+	Term string
+	// Start y End son los bytes de la palabra en el texto original, para
+	// resaltarla en los snippets.
+	Start, End int
+}
+
+// Analyze devuelve los tokens de text, sin stopwords.
+func Analyze(text string) []Token {
+	var tokens []Token
+
+	for _, word := range words(text) {
+		folded := Fold(text[word.Start:word.End])
+
+		if stopwords[folded] {
+			continue
+		}
+
+		tokens = append(tokens, Token{Term: Stem(folded), Start: word.Start, End: word.End})
+	}
+
+	return tokens
+}
+
+// Terms devuelve los términos de text, sin repetir y en el orden en que aparecen.
+func Terms(text string) []string {
+	var terms []string
+	seen := map[string]bool{}
+
+	for _, token := range Analyze(text) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			terms = append(terms, token.Term)
+		}
+	}
+
+	return terms
+}
+
+// words parte text en palabras; Term queda vacío.
+func words(text string) []Token {
+	var out []Token
+
+	start := -1
+
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			out = append(out, Token{Start: start, End: i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		out = append(out, Token{Start: start, End: len(text)})
+	}
+
+	return out
+}
+
+// folding son las letras con diacríticos que aparecen en español (y algunas
+// de otros idiomas del catálogo), ya en minúscula.
+var folding = map[rune]string{
+	'á': "a", 'à': "a", 'ä': "a", 'â': "a", 'ã': "a",
+	'é': "e", 'è': "e", 'ë': "e", 'ê': "e",
+	'í': "i", 'ì': "i", 'ï': "i", 'î': "i",
+	'ó': "o", 'ò': "o", 'ö': "o", 'ô': "o", 'õ': "o",
+	'ú': "u", 'ù': "u", 'ü': "u", 'û': "u",
+	'ñ': "n", 'ç': "c",
+}
+
+// Fold pasa word a minúsculas y le saca los acentos.
+func Fold(word string) string {
+	var b strings.Builder
+	b.Grow(len(word))
+
+	for _, r := range strings.ToLower(word) {
+		if folded, ok := folding[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// stopwords son palabras demasiado comunes para indexarlas (ya sin acentos).
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		a al algo algun alguna algunas alguno algunos ante antes como con contra
+		cual cuando de del desde donde durante e el ella ellas ellos en entre era
+		es esa esas ese eso esos esta estas este esto estos fue ha hay la las le
+		les lo los mas me mi mis muy ni no nos o otra otro para pero poco por que
+		se sea ser si sin sobre su sus tambien tan te tiene todo tu tus u un una
+		uno unos unas y ya`) {
+		stopwords[word] = true
+	}
+}
+
+// Stem reduce una palabra ya plegada (Fold) a su raíz. Es un stemmer liviano:
+// saca sufijos derivativos comunes, el plural y la vocal final de género, lo
+// suficiente para que las formas de una misma palabra coincidan.
+func Stem(word string) string {
+	if utf8.RuneCountInString(word) <= 3 || !isAlpha(word) {
+		return word
+	}
+
+	for _, suffix := range derivational {
+		if stem, ok := strings.CutSuffix(word, suffix); ok && len(stem) >= 3 {
+			word = stem
+			break
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ces") && len(word) > 4 && isVowel(word[len(word)-4]):
+		// luces -> luz (pero dulces -> dulc, como dulce)
+		word = strings.TrimSuffix(word, "ces") + "z"
+	case strings.HasSuffix(word, "es") && len(word) > 4 && !isVowel(word[len(word)-3]):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && len(word) > 3:
+		word = strings.TrimSuffix(word, "s")
+	}
+
+	if n := len(word); n > 3 && (word[n-1] == 'a' || word[n-1] == 'o' || word[n-1] == 'e') {
+		word = word[:n-1]
+	}
+
+	return word
+}
+
+// derivational son sufijos que forman palabras derivadas, de más largo a más
+// corto (se saca sólo el primero que coincide).
+var derivational = []string{
+	"amientos", "imientos", "amiento", "imiento",
+	"aciones", "uciones", "mente", "acion", "ucion",
+	"idades", "idad", "ismos", "ismo", "istas", "ista",
+	"ables", "ibles", "able", "ible",
+}
+
+func isVowel(b byte) bool {
+	return strings.IndexByte("aeiou", b) >= 0
+}
+
+// isAlpha indica si word es sólo letras ASCII (los modelos y códigos no se
+// tocan).
+func isAlpha(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,116 @@
+package search
+
+import "unicode/utf8"
+
+// Tolerancia a errores de tipeo: un término de la búsqueda que no está en el
+// índice se cambia por los términos del índice a pocas ediciones (letras
+// agregadas, borradas o cambiadas) de distancia: "celuar" encuentra
+// "celular". Los términos que sí están no se expanden, para no mezclar
+// palabras parecidas pero distintas.
+
+// fuzzyPenalty multiplica el puntaje de una coincidencia aproximada por cada
+// edición de distancia: la palabra exacta siempre vale más.
+const fuzzyPenalty = 0.5
+
+// maxEdits es la distancia tolerada para un término: ninguna en los cortos
+// (casi cualquier palabra de tres letras está a una edición de otra), una
+// hasta siete letras y dos desde ahí.
+func maxEdits(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// variant es un término del índice que reemplaza a uno de la búsqueda, con el
+// factor de su puntaje.
+type variant struct {
+	term   string
+	weight float64
+}
+
+// variants devuelve los términos del índice que cuentan para term: él mismo
+// si está, o si no los que están a maxEdits o menos. Se llama con el lock
+// tomado.
+func (ix *Index) variants(term string) []variant {
+	if _, ok := ix.postings[term]; ok {
+		return []variant{{term: term, weight: 1}}
+	}
+
+	limit := maxEdits(term)
+	if limit == 0 {
+		return nil
+	}
+
+	var out []variant
+
+	for candidate := range ix.postings {
+		if d, ok := distance(term, candidate, limit); ok {
+			weight := 1.0
+			for range d {
+				weight *= fuzzyPenalty
+			}
+
+			out = append(out, variant{term: candidate, weight: weight})
+		}
+	}
+
+	return out
+}
+
+// distance es la distancia de Levenshtein entre a y b, si es como mucho
+// limit; ok es false si es mayor. Corta apenas una fila entera pasa el
+// límite, así que descartar una palabra lejana es barato.
+func distance(a string, b string, limit int) (int, bool) {
+	ra, rb := []rune(a), []rune(b)
+
+	if abs(len(ra)-len(rb)) > limit {
+		return 0, false
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		best := curr[0]
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			best = min(best, curr[j])
+		}
+
+		if best > limit {
+			return 0, false
+		}
+
+		prev, curr = curr, prev
+	}
+
+	if d := prev[len(rb)]; d <= limit {
+		return d, true
+	}
+
+	return 0, false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
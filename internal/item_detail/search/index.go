@@ -0,0 +1,254 @@
+package search
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+)
+
+// Parámetros de BM25F: k1 satura la frecuencia de un término y b pesa cuánto
+// se penaliza un campo más largo que el promedio.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Field es un campo de texto de los documentos y su peso en el puntaje (un
+// término en el nombre vale más que en la descripción).
+type Field struct {
+	Name   string
+	Weight float64
+}
+
+// Index es un índice invertido en memoria: por término, los documentos que lo
+// tienen y cuántas veces en cada campo. Se actualiza de a un documento con Put
+// y Remove, y es seguro para usar desde varias goroutines.
+type Index struct {
+	fields []Field
+
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string][]int // término -> documento -> frecuencia por campo
+	lengths  []int                       // tokens por campo, sumando todos los documentos
+}
+
+type document struct {
+	texts   []string // los textos indexados, para los snippets
+	lengths []int    // tokens por campo
+	terms   []string // términos distintos, para sacarlo de los postings
+}
+
+// Hit es un documento que coincide con una búsqueda.
+type Hit struct {
+	ID    string
+	Score float64
+	// Terms son los términos del documento que coincidieron con la búsqueda
+	// (para un término con errores, el término correcto).
+	Terms []string
+}
+
+// NewIndex arma un índice vacío con esos campos.
+func NewIndex(fields ...Field) *Index {
+	return &Index{
+		fields:   fields,
+		docs:     map[string]*document{},
+		postings: map[string]map[string][]int{},
+		lengths:  make([]int, len(fields)),
+	}
+}
+
+// Len devuelve la cantidad de documentos indexados.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.docs)
+}
+
+// Put indexa (o reindexa) el documento id. texts van en el orden de los
+// campos del índice.
+func (ix *Index) Put(id string, texts ...string) {
+	doc := &document{texts: make([]string, len(ix.fields)), lengths: make([]int, len(ix.fields))}
+	copy(doc.texts, texts)
+
+	freqs := map[string][]int{}
+
+	for i := range ix.fields {
+		for _, token := range Analyze(doc.texts[i]) {
+			f, ok := freqs[token.Term]
+			if !ok {
+				f = make([]int, len(ix.fields))
+				freqs[token.Term] = f
+				doc.terms = append(doc.terms, token.Term)
+			}
+
+			f[i]++
+			doc.lengths[i]++
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+
+	for term, f := range freqs {
+		byDoc, ok := ix.postings[term]
+		if !ok {
+			byDoc = map[string][]int{}
+			ix.postings[term] = byDoc
+		}
+
+		byDoc[id] = f
+	}
+
+	for i, n := range doc.lengths {
+		ix.lengths[i] += n
+	}
+
+	ix.docs[id] = doc
+}
+
+// Remove saca el documento id del índice (si estaba).
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id string) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.terms {
+		delete(ix.postings[term], id)
+
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+
+	for i, n := range doc.lengths {
+		ix.lengths[i] -= n
+	}
+
+	delete(ix.docs, id)
+}
+
+// Search devuelve los documentos con alguno de los términos de query, de
+// mayor a menor puntaje (BM25F): pesan más los términos raros, los que se
+// repiten y los que están en campos de más peso. Los términos que no están en
+// el índice se buscan con tolerancia a errores de tipeo (ver variants). A
+// igual puntaje, por ID.
+func (ix *Index) Search(query string) []Hit {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return []Hit{}
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	scores := map[string]float64{}
+	matched := map[string][]string{}
+
+	for _, term := range terms {
+		// Un término con errores puede coincidir con varias variantes en un
+		// mismo documento: cuenta sólo la mejor.
+		best := map[string]variant{}
+		bestScore := map[string]float64{}
+
+		for _, v := range ix.variants(term) {
+			byDoc := ix.postings[v.term]
+			idf := ix.idf(len(byDoc))
+
+			for id, freq := range byDoc {
+				tf := ix.weightedFrequency(ix.docs[id], freq)
+				score := v.weight * idf * tf * (bm25K1 + 1) / (tf + bm25K1)
+
+				if score > bestScore[id] {
+					best[id], bestScore[id] = v, score
+				}
+			}
+		}
+
+		for id, v := range best {
+			scores[id] += bestScore[id]
+			matched[id] = append(matched[id], v.term)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score, Terms: matched[id]})
+	}
+
+	slices.SortFunc(hits, func(a Hit, b Hit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return hits
+}
+
+// idf es la rareza de un término que está en n documentos.
+func (ix *Index) idf(n int) float64 {
+	total := float64(len(ix.docs))
+
+	return math.Log(1 + (total-float64(n)+0.5)/(float64(n)+0.5))
+}
+
+// weightedFrequency suma la frecuencia del término en cada campo, pesada por
+// el campo y normalizada por su largo respecto del promedio.
+func (ix *Index) weightedFrequency(doc *document, freq []int) float64 {
+	var tf float64
+
+	for i, field := range ix.fields {
+		if freq[i] == 0 {
+			continue
+		}
+
+		avg := float64(ix.lengths[i]) / float64(len(ix.docs))
+		norm := 1 - bm25B
+		if avg > 0 {
+			norm += bm25B * float64(doc.lengths[i]) / avg
+		}
+
+		tf += field.Weight * float64(freq[i]) / norm
+	}
+
+	return tf
+}
+
+// Highlights devuelve, por campo con coincidencias, un fragmento del texto
+// del documento con las palabras de hit resaltadas (ver Snippet). Se pide
+// sólo para los resultados que se muestran.
+func (ix *Index) Highlights(hit Hit) map[string]string {
+	highlights := map[string]string{}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	doc, ok := ix.docs[hit.ID]
+	if !ok {
+		return highlights
+	}
+
+	terms := hit.Terms
+
+	for i, field := range ix.fields {
+		if snippet, ok := Snippet(doc.texts[i], terms); ok {
+			highlights[field.Name] = snippet
+		}
+	}
+
+	return highlights
+}
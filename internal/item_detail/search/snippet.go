@@ -0,0 +1,72 @@
+package search
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// snippetWords es el largo máximo de un snippet, en palabras.
+const snippetWords = 16
+
+// Snippet devuelve el fragmento de text alrededor de la primera palabra que
+// coincide con terms, con las coincidencias entre <em> y </em>. El texto va
+// escapado como HTML; "…" marca que el fragmento está recortado. ok es false
+// si ninguna palabra coincide.
+func Snippet(text string, terms []string) (string, bool) {
+	tokens := words(text)
+
+	matches := make([]bool, len(tokens))
+	first := -1
+
+	for i, token := range tokens {
+		folded := Fold(text[token.Start:token.End])
+
+		if !stopwords[folded] && slices.Contains(terms, Stem(folded)) {
+			matches[i] = true
+
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+
+	if first < 0 {
+		return "", false
+	}
+
+	// Un poco de contexto antes de la primera coincidencia.
+	from := max(first-3, 0)
+	to := min(from+snippetWords, len(tokens))
+
+	var b strings.Builder
+
+	start := tokens[from].Start
+	if from > 0 {
+		b.WriteString("…")
+	} else {
+		start = 0
+	}
+
+	for i := from; i < to; i++ {
+		token := tokens[i]
+
+		b.WriteString(html.EscapeString(text[start:token.Start]))
+
+		if matches[i] {
+			b.WriteString("<em>" + html.EscapeString(text[token.Start:token.End]) + "</em>")
+		} else {
+			b.WriteString(html.EscapeString(text[token.Start:token.End]))
+		}
+
+		start = token.End
+	}
+
+	if to < len(tokens) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(html.EscapeString(text[start:]))
+	}
+
+	return b.String(), true
+}
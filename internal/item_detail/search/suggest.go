@@ -0,0 +1,153 @@
+package search
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Suggestion es una compleción para un prefijo.
+type Suggestion struct {
+	Key   string
+	Kind  string
+	Text  string
+	Score float64
+}
+
+// Suggester completa prefijos con textos cortos (nombres), de mayor a menor
+// puntaje. El prefijo puede empezar en cualquier palabra del texto y no
+// distingue mayúsculas ni acentos: "auric" y "sony aur" completan "Auriculares
+// Sony". Guarda las claves ordenadas, así que cada consulta es una búsqueda
+// binaria más el recorrido de las que empiezan con el prefijo; el orden se
+// rearma, una vez, en la primera consulta después de un cambio.
+type Suggester struct {
+	mu      sync.Mutex
+	entries map[string]*completion
+	keys    []suggestKey // nil si hay que rearmarlas
+}
+
+type completion struct {
+	kind  string
+	text  string
+	score float64
+}
+
+// suggestKey es el texto normalizado de una compleción desde una de sus
+// palabras.
+type suggestKey struct {
+	key   string
+	entry string
+}
+
+func NewSuggester() *Suggester {
+	return &Suggester{entries: map[string]*completion{}}
+}
+
+// Put agrega (o reemplaza) la compleción key: text, de tipo kind, con ese
+// puntaje.
+func (s *Suggester) Put(key string, kind string, text string, score float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = &completion{kind: kind, text: text, score: score}
+	s.keys = nil
+}
+
+// Remove saca la compleción key (si estaba).
+func (s *Suggester) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; ok {
+		delete(s.entries, key)
+		s.keys = nil
+	}
+}
+
+// Suggest devuelve hasta limit compleciones de prefix, de mayor a menor
+// puntaje (a igual puntaje, por texto). Un mismo texto aparece una sola vez
+// por tipo: el de mayor puntaje.
+func (s *Suggester) Suggest(prefix string, limit int) []Suggestion {
+	prefix = normalize(prefix)
+	if prefix == "" || limit <= 0 {
+		return []Suggestion{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys == nil {
+		s.rebuild()
+	}
+
+	best := map[string]Suggestion{}
+
+	from := sort.Search(len(s.keys), func(i int) bool { return s.keys[i].key >= prefix })
+
+	for _, k := range s.keys[from:] {
+		if !strings.HasPrefix(k.key, prefix) {
+			break
+		}
+
+		entry := s.entries[k.entry]
+		dedup := entry.kind + "\x00" + normalize(entry.text)
+
+		if current, ok := best[dedup]; !ok || entry.score > current.Score ||
+			entry.score == current.Score && k.entry < current.Key {
+			best[dedup] = Suggestion{Key: k.entry, Kind: entry.kind, Text: entry.text, Score: entry.score}
+		}
+	}
+
+	out := make([]Suggestion, 0, len(best))
+	for _, suggestion := range best {
+		out = append(out, suggestion)
+	}
+
+	slices.SortFunc(out, func(a Suggestion, b Suggestion) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+
+		if c := cmp.Compare(a.Text, b.Text); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	return out[:min(limit, len(out))]
+}
+
+// rebuild arma las claves ordenadas: una por palabra de cada texto.
+func (s *Suggester) rebuild() {
+	s.keys = make([]suggestKey, 0, len(s.entries))
+
+	for key, entry := range s.entries {
+		text := normalize(entry.text)
+
+		for i := 0; i < len(text); i++ {
+			if i == 0 || text[i-1] == ' ' {
+				s.keys = append(s.keys, suggestKey{key: text[i:], entry: key})
+			}
+		}
+	}
+
+	slices.SortFunc(s.keys, func(a suggestKey, b suggestKey) int {
+		return cmp.Compare(a.key, b.key)
+	})
+}
+
+// normalize pliega las palabras de text (ver Fold) y las separa con un solo
+// espacio. No saca stopwords ni reduce a la raíz: se compara contra lo que
+// se está tipeando.
+func normalize(text string) string {
+	var parts []string
+
+	for _, word := range words(text) {
+		parts = append(parts, Fold(text[word.Start:word.End]))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -18,10 +18,23 @@ func (s *CrudService[T]) FetchEntities(q string, limit int, offset int) ([]*T, e
 	return s.dao.GetAll(q, limit, offset)
 }
 
+func (s *CrudService[T]) ListEntities(query dao.ListQuery) ([]*T, error) {
+	return s.dao.List(query)
+}
+
+// PageEntities es ListEntities con el total y los cursores de las páginas vecinas.
+func (s *CrudService[T]) PageEntities(query dao.ListQuery) (dao.Page[T], error) {
+	return s.dao.Page(query)
+}
+
 func (s *CrudService[T]) FetchEntity(id string) (*T, error) {
 	return s.dao.GetByID(id)
 }
 
+func (s *CrudService[T]) FindEntity(id string, deleted dao.DeletedFilter) (*T, error) {
+	return s.dao.Find(id, deleted)
+}
+
 func (s *CrudService[T]) PatchEntity(entity *T, id string) (*T, error) {
 	return s.dao.Update(entity, id)
 }
@@ -29,3 +42,28 @@ func (s *CrudService[T]) PatchEntity(entity *T, id string) (*T, error) {
 func (s *CrudService[T]) DeleteEntity(id string) (bool, error) {
 	return s.dao.Delete(id)
 }
+
+func (s *CrudService[T]) RestoreEntity(id string) (*T, error) {
+	return s.dao.Restore(id)
+}
+
+func (s *CrudService[T]) LastChange() (dao.Change, error) {
+	return s.dao.LastChange()
+}
+
+// As devuelve el servicio sobre la vista del DAO que firma las escrituras con actor.
+func (s *CrudService[T]) As(actor string) *CrudService[T] {
+	return &CrudService[T]{dao: s.dao.As(actor)}
+}
+
+func (s *CrudService[T]) FetchRevisions(id string) ([]dao.Revision[T], error) {
+	return s.dao.Revisions(id)
+}
+
+func (s *CrudService[T]) FetchRevision(id string, rev int64) (*dao.Revision[T], error) {
+	return s.dao.Revision(id, rev)
+}
+
+func (s *CrudService[T]) RevertEntity(id string, rev int64, expected int64) (*T, error) {
+	return s.dao.Revert(id, rev, expected)
+}
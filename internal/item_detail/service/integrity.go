@@ -0,0 +1,495 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"slices"
+	"strings"
+)
+
+// DeletePolicy decide qué pasa con los productos que apuntan a una categoría,
+// un vendedor o una imagen cuando se borra.
+type DeletePolicy string
+
+const (
+	// RestrictDelete rechaza el borrado (409) mientras algún producto la use.
+	RestrictDelete DeletePolicy = "restrict"
+	// CascadeDelete manda a la papelera los productos que la usan.
+	CascadeDelete DeletePolicy = "cascade"
+	// NullifyDelete saca la referencia de los productos que la usan: quedan
+	// sin categoría o sin vendedor (hasta que se les asigne otro) o sin la
+	// imagen.
+	NullifyDelete DeletePolicy = "nullify"
+)
+
+// ParseDeletePolicy interpreta el nombre de una política ("" es restrict).
+func ParseDeletePolicy(raw string) (DeletePolicy, error) {
+	switch policy := DeletePolicy(strings.ToLower(strings.TrimSpace(raw))); policy {
+	case "":
+		return RestrictDelete, nil
+	case RestrictDelete, CascadeDelete, NullifyDelete:
+		return policy, nil
+	}
+
+	return RestrictDelete, fmt.Errorf("unknown delete policy %q (available: restrict, cascade, nullify)", raw)
+}
+
+// reference describe un campo de Product que apunta a otra colección.
+type reference struct {
+	field      string         // nombre JSON del campo en el producto
+	entity     string         // nombre de la entidad apuntada, para los mensajes
+	collection dao.Collection // colección apuntada, para el alcance de las transacciones
+	indexed    bool           // el campo tiene índice: se busca con ListQuery.Equals
+	uses       func(product *models.Product, id string) bool
+	detach     func(product *models.Product, id string)
+}
+
+var (
+	categoryRef = reference{
+		field:      "categoryId",
+		entity:     "category",
+		collection: dao.CategoriesCollection,
+		indexed:    true,
+		uses:       func(p *models.Product, id string) bool { return p.CategoryId == id },
+		detach:     func(p *models.Product, id string) { p.CategoryId = "" },
+	}
+	sellerRef = reference{
+		field:      "sellerId",
+		entity:     "seller",
+		collection: dao.SellersCollection,
+		indexed:    true,
+		uses:       func(p *models.Product, id string) bool { return p.SellerId == id },
+		detach:     func(p *models.Product, id string) { p.SellerId = "" },
+	}
+	imageRef = reference{
+		field:      "images",
+		entity:     "image",
+		collection: dao.ImagesCollection,
+		uses:       func(p *models.Product, id string) bool { return slices.Contains(p.Images, id) },
+		detach: func(p *models.Product, id string) {
+			p.Images = slices.DeleteFunc(slices.Clone(p.Images), func(image string) bool { return image == id })
+		},
+	}
+)
+
+// Integrity cuida las referencias de los productos a categorías, vendedores e
+// imágenes. Entrega vistas de los DAO que verifican que las referencias
+// existan al escribir un producto y que aplican la DeletePolicy al borrar una
+// entidad referenciada. Los DAO originales quedan sin controles (seeds,
+// migraciones).
+//
+// Con un transactor (ver WithTransactor) cada verificación corre en la misma
+// transacción que la escritura: nadie puede borrar la categoría entre que se
+// verifica y se guarda el producto, ni crear un producto que la use entre que
+// se buscan sus usuarios y se borra. La transacción toma sólo lo que usa: una
+// escritura de productos que no cambia referencias (un PATCH del precio) no
+// abre ninguna, y una que las cambia lee sólo las colecciones apuntadas.
+type Integrity struct {
+	products   dao.ProductDAO
+	sellers    dao.SellerDAO
+	categories dao.CategoryDAO
+	images     dao.ImageDAO
+	policy     DeletePolicy
+	transactor dao.Transactor
+}
+
+func NewIntegrity(
+	products dao.ProductDAO,
+	sellers dao.SellerDAO,
+	categories dao.CategoryDAO,
+	images dao.ImageDAO,
+	policy DeletePolicy,
+) *Integrity {
+	return &Integrity{
+		products:   products,
+		sellers:    sellers,
+		categories: categories,
+		images:     images,
+		policy:     policy,
+	}
+}
+
+// WithTransactor hace que las verificaciones y las escrituras de los DAO de
+// Integrity corran juntas en una transacción de transactor.
+func (i *Integrity) WithTransactor(transactor dao.Transactor) *Integrity {
+	i.transactor = transactor
+	return i
+}
+
+// Products devuelve el DAO de productos que rechaza referencias rotas.
+func (i *Integrity) Products() dao.ProductDAO {
+	return &checkedProducts{ProductDAO: i.products, integrity: i}
+}
+
+// Categories devuelve el DAO de categorías que aplica la política al borrar.
+func (i *Integrity) Categories() dao.CategoryDAO {
+	return &referencedDAO[models.Category]{CrudDAO: i.categories, integrity: i, ref: categoryRef,
+		collection: func(tx *Integrity) dao.CrudDAO[models.Category] { return tx.categories }}
+}
+
+// Sellers devuelve el DAO de vendedores que aplica la política al borrar.
+func (i *Integrity) Sellers() dao.SellerDAO {
+	return &referencedDAO[models.Seller]{CrudDAO: i.sellers, integrity: i, ref: sellerRef,
+		collection: func(tx *Integrity) dao.CrudDAO[models.Seller] { return tx.sellers }}
+}
+
+// Images devuelve el DAO de imágenes que aplica la política al borrar.
+func (i *Integrity) Images() dao.ImageDAO {
+	return &referencedDAO[models.Image]{CrudDAO: i.images, integrity: i, ref: imageRef,
+		collection: func(tx *Integrity) dao.CrudDAO[models.Image] { return tx.images }}
+}
+
+// atomically ejecuta fn con una Integrity cuyos DAO leen y escriben dentro de
+// una sola transacción con alcance scope. Sin transactor fn recibe i: cada
+// operación es atómica por separado.
+func (i *Integrity) atomically(scope dao.Scope, fn func(tx *Integrity) error) error {
+	if i.transactor == nil {
+		return fn(i)
+	}
+
+	return i.transactor.TransactIn(scope, func(uow dao.UnitOfWork) error {
+		return fn(NewIntegrity(uow.Products(), uow.Sellers(), uow.Categories(), uow.Images(), i.policy))
+	})
+}
+
+// check verifica que existan las referencias de product que no estaban en
+// before (nil: todas). Los IDs vacíos no se verifican: si son obligatorios lo
+// decide el validador.
+func (i *Integrity) check(product *models.Product, before *models.Product) error {
+	if product.CategoryId != "" && (before == nil || before.CategoryId != product.CategoryId) {
+		if err := exists(i.categories, categoryRef, product.CategoryId); err != nil {
+			return err
+		}
+	}
+
+	if product.SellerId != "" && (before == nil || before.SellerId != product.SellerId) {
+		if err := exists(i.sellers, sellerRef, product.SellerId); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range product.Images {
+		if before != nil && slices.Contains(before.Images, id) {
+			continue
+		}
+
+		if err := exists(i.images, imageRef, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changedRefs devuelve las colecciones de las referencias que check
+// verificaría: las de product que no estaban en before (nil: todas).
+func changedRefs(product *models.Product, before *models.Product) []dao.Collection {
+	var collections []dao.Collection
+
+	if product.CategoryId != "" && (before == nil || before.CategoryId != product.CategoryId) {
+		collections = append(collections, categoryRef.collection)
+	}
+
+	if product.SellerId != "" && (before == nil || before.SellerId != product.SellerId) {
+		collections = append(collections, sellerRef.collection)
+	}
+
+	if slices.ContainsFunc(product.Images, func(id string) bool { return before == nil || !slices.Contains(before.Images, id) }) {
+		collections = append(collections, imageRef.collection)
+	}
+
+	return collections
+}
+
+// errReferencesChanged descarta un Modify cuyo mutate cambió referencias que
+// la escritura en curso no puede verificar (ver checkedProducts.Modify).
+var errReferencesChanged = errors.New("product references changed")
+
+// exists devuelve ErrInvalidReference si id no está en d (o está en la papelera).
+func exists[T any](d dao.CrudDAO[T], ref reference, id string) error {
+	_, err := d.GetByID(id)
+
+	if errors.Is(err, dao.ErrNotFound) {
+		return dao.Errorf(dao.ErrInvalidReference, "%s: %s %q doesn't exist", ref.field, ref.entity, id)
+	}
+
+	return err
+}
+
+// checkedProducts verifica las referencias en cada escritura de productos.
+type checkedProducts struct {
+	dao.ProductDAO
+	integrity *Integrity
+	actor     string // quien firma las escrituras (ver As)
+}
+
+// write ejecuta fn con los productos firmados por p.actor. Si hay
+// referencias que verificar (reads son sus colecciones), fn corre en una
+// transacción que escribe productos y lee sólo esas colecciones; si no, la
+// escritura del producto alcanza y no se abre ninguna.
+func (p *checkedProducts) write(reads []dao.Collection, fn func(tx *Integrity, products dao.CrudDAO[models.Product]) error) error {
+	if len(reads) == 0 {
+		return fn(p.integrity, p.ProductDAO)
+	}
+
+	scope := dao.Scope{Writes: []dao.Collection{dao.ProductsCollection}, Reads: reads}
+
+	return p.integrity.atomically(scope, func(tx *Integrity) error {
+		products := dao.CrudDAO[models.Product](p.ProductDAO)
+		if tx != p.integrity {
+			products = tx.products.As(p.actor)
+		}
+
+		return fn(tx, products)
+	})
+}
+
+func (p *checkedProducts) Create(product *models.Product) (*models.Product, error) {
+	var created *models.Product
+
+	err := p.write(changedRefs(product, nil), func(tx *Integrity, products dao.CrudDAO[models.Product]) error {
+		if err := tx.check(product, nil); err != nil {
+			return err
+		}
+
+		var err error
+		created, err = products.Create(product)
+
+		return err
+	})
+
+	return created, err
+}
+
+// Update es parcial: sólo se verifican las referencias que trae el cambio.
+func (p *checkedProducts) Update(patch *models.Product, id string) (*models.Product, error) {
+	var updated *models.Product
+
+	err := p.write(changedRefs(patch, nil), func(tx *Integrity, products dao.CrudDAO[models.Product]) error {
+		if err := tx.check(patch, nil); err != nil {
+			return err
+		}
+
+		var err error
+		updated, err = products.Update(patch, id)
+
+		return err
+	})
+
+	return updated, err
+}
+
+// Modify verifica, dentro de la misma escritura, las referencias que mutate
+// cambió. Cuáles son se sabe recién al correr mutate: se prueba primero sin
+// transacción y, si cambia alguna, se descarta y se repite en una que lee
+// esas colecciones.
+func (p *checkedProducts) Modify(id string, mutate func(product *models.Product) error) (*models.Product, error) {
+	var (
+		modified *models.Product
+		reads    []dao.Collection
+	)
+
+	for {
+		var needed []dao.Collection
+
+		err := p.write(reads, func(tx *Integrity, products dao.CrudDAO[models.Product]) error {
+			var err error
+			modified, err = products.Modify(id, func(product *models.Product) error {
+				before := *product
+				before.Images = slices.Clone(product.Images)
+
+				if err := mutate(product); err != nil {
+					return err
+				}
+
+				needed = changedRefs(product, &before)
+				if slices.ContainsFunc(needed, func(c dao.Collection) bool { return !slices.Contains(reads, c) }) {
+					return errReferencesChanged
+				}
+
+				return tx.check(product, &before)
+			})
+
+			return err
+		})
+
+		if !errors.Is(err, errReferencesChanged) {
+			return modified, err
+		}
+
+		reads = needed
+	}
+}
+
+// Restore no saca de la papelera un producto cuyas referencias ya no existen.
+// Los productos en la papelera no cambian: sus referencias se pueden leer
+// antes de la transacción.
+func (p *checkedProducts) Restore(id string) (*models.Product, error) {
+	var (
+		restored *models.Product
+		reads    []dao.Collection
+	)
+
+	if product, err := p.ProductDAO.Find(id, dao.OnlyDeleted); err == nil {
+		reads = changedRefs(product, nil)
+	}
+
+	err := p.write(reads, func(tx *Integrity, products dao.CrudDAO[models.Product]) error {
+		if product, err := products.Find(id, dao.OnlyDeleted); err == nil {
+			if err := tx.check(product, nil); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		restored, err = products.Restore(id)
+
+		return err
+	})
+
+	return restored, err
+}
+
+// Revert verifica las referencias que la versión pedida vuelve a poner. Las
+// versiones archivadas no cambian: se pueden leer antes de la transacción.
+func (p *checkedProducts) Revert(id string, rev int64, expected int64) (*models.Product, error) {
+	var (
+		reverted *models.Product
+		reads    []dao.Collection
+	)
+
+	if target, err := p.ProductDAO.Revision(id, rev); err == nil {
+		reads = changedRefs(target.Entity, nil)
+	}
+
+	err := p.write(reads, func(tx *Integrity, products dao.CrudDAO[models.Product]) error {
+		target, targetErr := products.Revision(id, rev)
+		current, currentErr := products.GetByID(id)
+
+		if targetErr == nil && currentErr == nil {
+			if err := tx.check(target.Entity, current); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		reverted, err = products.Revert(id, rev, expected)
+
+		return err
+	})
+
+	return reverted, err
+}
+
+func (p *checkedProducts) As(actor string) dao.CrudDAO[models.Product] {
+	return &checkedProducts{ProductDAO: p.ProductDAO.As(actor), integrity: p.integrity, actor: actor}
+}
+
+// referencedDAO aplica la DeletePolicy al borrar una entidad que los
+// productos pueden referenciar.
+type referencedDAO[T any] struct {
+	dao.CrudDAO[T]
+	integrity *Integrity
+	ref       reference
+	actor     string // quien firma las escrituras (ver As)
+	// collection es la colección de T en una transacción de la integridad.
+	collection func(tx *Integrity) dao.CrudDAO[T]
+}
+
+// Delete resuelve primero los productos que usan la entidad y después la
+// borra, todo en la misma transacción: si algo falla no queda nada a medias.
+// Con restrict, un producto que la use hace fallar el borrado con
+// ErrConflict. La transacción escribe la
+// colección de la entidad, y los productos sólo si la política los cambia.
+func (r *referencedDAO[T]) Delete(id string) (bool, error) {
+	var deleted bool
+
+	scope := dao.Scope{Writes: []dao.Collection{r.ref.collection}, Reads: []dao.Collection{dao.ProductsCollection}}
+	if r.integrity.policy != RestrictDelete {
+		scope.Writes = append(scope.Writes, dao.ProductsCollection)
+	}
+
+	err := r.integrity.atomically(scope, func(tx *Integrity) error {
+		entities, products := r.CrudDAO, dao.CrudDAO[models.Product](tx.products.As(r.actor))
+		if tx != r.integrity {
+			entities = r.collection(tx).As(r.actor)
+		}
+
+		if _, err := entities.GetByID(id); err != nil {
+			return err
+		}
+
+		users, err := usedBy(products, r.ref, id)
+		if err != nil {
+			return err
+		}
+
+		switch tx.policy {
+		case CascadeDelete:
+			for _, product := range users {
+				if _, err := products.Delete(product.ID); err != nil {
+					return err
+				}
+			}
+		case NullifyDelete:
+			for _, product := range users {
+				_, err := products.Modify(product.ID, func(product *models.Product) error {
+					r.ref.detach(product, id)
+					return nil
+				})
+
+				if err != nil {
+					return err
+				}
+			}
+		default:
+			if len(users) > 0 {
+				ids := make([]string, len(users))
+				for i, product := range users {
+					ids[i] = product.ID
+				}
+
+				return dao.Errorf(dao.ErrConflict, "%s %q is used by %d product(s): %s", r.ref.entity, id, len(ids), strings.Join(ids, ", "))
+			}
+		}
+
+		deleted, err = entities.Delete(id)
+
+		return err
+	})
+
+	return deleted, err
+}
+
+func (r *referencedDAO[T]) As(actor string) dao.CrudDAO[T] {
+	return &referencedDAO[T]{CrudDAO: r.CrudDAO.As(actor), integrity: r.integrity, ref: r.ref, actor: actor, collection: r.collection}
+}
+
+// usedBy devuelve los productos activos que apuntan a id, en una sola
+// lectura (dentro de la transacción de Delete nadie los puede cambiar). Las
+// referencias indexadas se buscan por índice; las imágenes recorren los
+// productos.
+func usedBy(products dao.CrudDAO[models.Product], ref reference, id string) ([]*models.Product, error) {
+	query := dao.ListQuery{Limit: math.MaxInt32}
+	if ref.indexed {
+		query.Equals = map[string]string{ref.field: id}
+	}
+
+	all, err := products.List(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*models.Product
+
+	for _, product := range all {
+		if ref.uses(product, id) {
+			users = append(users, product)
+		}
+	}
+
+	return users, nil
+}
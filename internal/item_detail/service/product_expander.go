@@ -0,0 +1,211 @@
+package service
+
+import (
+	"fmt"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"slices"
+	"strings"
+)
+
+// Expander agrega a las entidades de un GET o de un listado las entidades
+// relacionadas que se piden con ?expand=.
+type Expander[T any] interface {
+	// ParseExpand valida la lista de relaciones pedidas.
+	ParseExpand(raw string) ([]string, error)
+	// LastChange es el último cambio de las colecciones relacionadas, para
+	// los validadores de caché de la respuesta expandida.
+	LastChange() (dao.Change, error)
+	// Expand devuelve las entidades, en el mismo orden, con las relaciones
+	// de fields adentro.
+	Expand(entities []*T, fields []string) ([]any, error)
+}
+
+// Relaciones que se pueden expandir en un producto.
+const (
+	ExpandCategory = "category"
+	ExpandSeller   = "seller"
+	ExpandImages   = "images"
+)
+
+// ExpandedLink es un link HATEOAS con la entidad a la que apunta, si se pidió
+// expandirla (y existe).
+type ExpandedLink[T any] struct {
+	Href   string `json:"href"`
+	Entity *T     `json:"entity,omitempty"`
+}
+
+// ExpandedProduct es un producto con sus links expandidos: reemplaza en el
+// JSON a category, seller e image_links del producto.
+type ExpandedProduct struct {
+	*models.Product
+	Category   ExpandedLink[models.Category] `json:"category"`
+	Seller     ExpandedLink[models.Seller]   `json:"seller"`
+	ImageLinks []ExpandedLink[models.Image]  `json:"image_links"`
+}
+
+// ProductExpander expande la categoría, el vendedor y las imágenes de los
+// productos. Lee cada colección una sola vez por llamada, con todos los IDs
+// que hacen falta: una página de 50 productos son como mucho tres lecturas.
+type ProductExpander struct {
+	categories dao.CrudDAO[models.Category]
+	sellers    dao.CrudDAO[models.Seller]
+	images     dao.CrudDAO[models.Image]
+}
+
+func NewProductExpander(
+	categories dao.CrudDAO[models.Category],
+	sellers dao.CrudDAO[models.Seller],
+	images dao.CrudDAO[models.Image],
+) *ProductExpander {
+	return &ProductExpander{categories: categories, sellers: sellers, images: images}
+}
+
+// ParseExpand interpreta la lista, separada por comas, de category, seller e
+// images.
+func (e *ProductExpander) ParseExpand(raw string) ([]string, error) {
+	var fields []string
+
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+
+		switch field {
+		case ExpandCategory, ExpandSeller, ExpandImages:
+		default:
+			return nil, fmt.Errorf("invalid expand %q: must be a comma separated list of category, seller and images", raw)
+		}
+
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, nil
+}
+
+// LastChange combina los últimos cambios de categorías, vendedores e imágenes.
+func (e *ProductExpander) LastChange() (dao.Change, error) {
+	var combined dao.Change
+	var tokens []string
+
+	for _, last := range []func() (dao.Change, error){e.categories.LastChange, e.sellers.LastChange, e.images.LastChange} {
+		change, err := last()
+		if err != nil {
+			return dao.Change{}, err
+		}
+
+		tokens = append(tokens, change.Token)
+
+		if change.At.After(combined.At) {
+			combined.At = change.At
+		}
+	}
+
+	combined.Token = strings.Join(tokens, ",")
+
+	return combined, nil
+}
+
+// Expand arma los ExpandedProduct de products. Una relación que no existe (o
+// está en la papelera) queda sólo con el link.
+func (e *ProductExpander) Expand(products []*models.Product, fields []string) ([]any, error) {
+	var categories map[string]*models.Category
+	var sellers map[string]*models.Seller
+	var images map[string]*models.Image
+
+	var err error
+
+	if slices.Contains(fields, ExpandCategory) {
+		categories, err = byIDs(e.categories, products, func(p *models.Product) []string { return []string{p.CategoryId} },
+			func(c *models.Category) string { return c.ID })
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(fields, ExpandSeller) {
+		sellers, err = byIDs(e.sellers, products, func(p *models.Product) []string { return []string{p.SellerId} },
+			func(s *models.Seller) string { return s.ID })
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(fields, ExpandImages) {
+		images, err = byIDs(e.images, products, func(p *models.Product) []string { return p.Images },
+			func(i *models.Image) string { return i.ID })
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	expanded := make([]any, len(products))
+
+	for i, product := range products {
+		out := &ExpandedProduct{
+			Product:    product,
+			Category:   ExpandedLink[models.Category]{Href: product.Category.Href, Entity: categories[product.CategoryId]},
+			Seller:     ExpandedLink[models.Seller]{Href: product.Seller.Href, Entity: sellers[product.SellerId]},
+			ImageLinks: make([]ExpandedLink[models.Image], 0, len(product.ImageLinks)),
+		}
+
+		for j, link := range product.ImageLinks {
+			var image *models.Image
+			if j < len(product.Images) {
+				image = images[product.Images[j]]
+			}
+
+			out.ImageLinks = append(out.ImageLinks, ExpandedLink[models.Image]{Href: link.Href, Entity: image})
+		}
+
+		expanded[i] = out
+	}
+
+	return expanded, nil
+}
+
+// byIDs lee las entidades de collection a las que apuntan los productos
+// (refs), por ID.
+func byIDs[T any](
+	collection dao.CrudDAO[T],
+	products []*models.Product,
+	refs func(*models.Product) []string,
+	id func(*T) string,
+) (map[string]*T, error) {
+	var ids []string
+	seen := map[string]bool{}
+
+	for _, product := range products {
+		for _, ref := range refs(product) {
+			if ref != "" && !seen[ref] {
+				seen[ref] = true
+				ids = append(ids, ref)
+			}
+		}
+	}
+
+	entities, err := listByIDs(collection, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]*T, len(entities))
+	for _, entity := range entities {
+		found[id(entity)] = entity
+	}
+
+	return found, nil
+}
+
+// listByIDs lee en una sola consulta las entidades activas con esos IDs (las
+// que no existen no vienen).
+func listByIDs[T any](collection dao.CrudDAO[T], ids []string) ([]*T, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	return collection.List(dao.ListQuery{
+		Filters: []dao.Filter{{Field: "id", Op: dao.OpIn, Values: ids}},
+		Limit:   len(ids),
+	})
+}
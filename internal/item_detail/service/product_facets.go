@@ -0,0 +1,358 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Facets que se pueden pedir, y el campo de Product que cuenta cada uno.
+const (
+	FacetCategory = "category"
+	FacetSeller   = "seller"
+	FacetPrice    = "price"
+	FacetStock    = "stock"
+	FacetRating   = "rating"
+)
+
+var facetFields = map[string]string{
+	FacetCategory: "categoryId",
+	FacetSeller:   "sellerId",
+	FacetPrice:    "price",
+	FacetStock:    "stock",
+	FacetRating:   "rate",
+}
+
+// DefaultPriceBuckets son los límites de los rangos de precio si no se piden
+// otros: menos de 100, de 100 a 500, de 500 a 1000, de 1000 a 5000 y 5000 o más.
+var DefaultPriceBuckets = []float64{100, 500, 1000, 5000}
+
+// FacetRequest son los facets a calcular y sus parámetros.
+type FacetRequest struct {
+	Names []string
+	// PriceBuckets son los límites, ascendentes, de los rangos de precio.
+	PriceBuckets []float64
+}
+
+// ParseFacetRequest interpreta la lista de facets (separados por coma) y los
+// límites de precio ("" usa DefaultPriceBuckets).
+func ParseFacetRequest(names string, priceBuckets string) (FacetRequest, error) {
+	request := FacetRequest{PriceBuckets: DefaultPriceBuckets}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+
+		if _, ok := facetFields[name]; !ok {
+			return request, fmt.Errorf("invalid facets %q: must be a comma separated list of category, seller, price, stock and rating", names)
+		}
+
+		if !slices.Contains(request.Names, name) {
+			request.Names = append(request.Names, name)
+		}
+	}
+
+	if priceBuckets == "" {
+		return request, nil
+	}
+
+	request.PriceBuckets = nil
+
+	for _, raw := range strings.Split(priceBuckets, ",") {
+		limit, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+
+		if err != nil || limit < 0 || math.IsInf(limit, 0) ||
+			len(request.PriceBuckets) > 0 && limit <= request.PriceBuckets[len(request.PriceBuckets)-1] {
+			return request, fmt.Errorf("invalid price_buckets %q: must be ascending non-negative numbers separated by commas", priceBuckets)
+		}
+
+		request.PriceBuckets = append(request.PriceBuckets, limit)
+	}
+
+	return request, nil
+}
+
+// Facets son los conteos pedidos, por nombre de facet.
+type Facets map[string][]FacetBucket
+
+// FacetBucket es un valor (o rango) de un facet y cuántos productos lo tienen.
+// Filter es el filtro de listado que se queda con esos productos.
+type FacetBucket struct {
+	Value  string   `json:"value"`
+	Label  string   `json:"label,omitempty"`
+	From   *float64 `json:"from,omitempty"`
+	To     *float64 `json:"to,omitempty"`
+	Count  int      `json:"count"`
+	Filter string   `json:"filter"`
+}
+
+// ProductFacets cuenta los productos de un listado por categoría, vendedor,
+// rango de precio, stock y calificación, para los filtros laterales.
+//
+// Cada facet respeta todos los filtros del listado salvo los de su propio
+// campo: con ?categoryId[eq]=cat-1 el facet de categorías sigue mostrando
+// cuántos productos hay en las otras (para cambiar de categoría), y los demás
+// cuentan sólo los de cat-1.
+type ProductFacets struct {
+	products   dao.CrudDAO[models.Product]
+	categories dao.CrudDAO[models.Category]
+	sellers    dao.CrudDAO[models.Seller]
+}
+
+func NewProductFacets(
+	products dao.CrudDAO[models.Product],
+	categories dao.CrudDAO[models.Category],
+	sellers dao.CrudDAO[models.Seller],
+) *ProductFacets {
+	return &ProductFacets{products: products, categories: categories, sellers: sellers}
+}
+
+// Token cambia cuando cambian los nombres de categorías o vendedores, que
+// también salen en los facets.
+func (f *ProductFacets) Token() (string, error) {
+	categories, err := f.categories.LastChange()
+	if err != nil {
+		return "", err
+	}
+
+	sellers, err := f.sellers.LastChange()
+	if err != nil {
+		return "", err
+	}
+
+	return categories.Token + "," + sellers.Token, nil
+}
+
+// Facets calcula los facets pedidos sobre los productos de query. Los
+// productos se leen una vez por combinación distinta de filtros (una sola si
+// ningún filtro toca un campo de los facets), y los nombres en una lectura
+// por colección.
+func (f *ProductFacets) Facets(query dao.ListQuery, request FacetRequest) (Facets, error) {
+	facets := Facets{}
+	reads := map[string][]*models.Product{}
+
+	for _, name := range request.Names {
+		scoped := withoutFilters(query, facetFields[name])
+
+		key := filterKey(scoped.Filters)
+		products, ok := reads[key]
+
+		if !ok {
+			var err error
+			if products, err = f.products.List(scoped); err != nil {
+				return nil, err
+			}
+
+			reads[key] = products
+		}
+
+		var err error
+
+		switch name {
+		case FacetCategory:
+			facets[name], err = countBy(products, "categoryId", func(p *models.Product) string { return p.CategoryId }, f.categoryNames)
+		case FacetSeller:
+			facets[name], err = countBy(products, "sellerId", func(p *models.Product) string { return p.SellerId }, f.sellerNames)
+		case FacetPrice:
+			facets[name] = priceBuckets(products, request.PriceBuckets)
+		case FacetStock:
+			facets[name] = stockBuckets(products)
+		case FacetRating:
+			facets[name] = ratingBuckets(products)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return facets, nil
+}
+
+// withoutFilters es query, sin paginado ni orden y sin los filtros sobre
+// field. Equals no se toca: acota el listado (los productos de una
+// categoría), no es un filtro elegido en la barra lateral.
+func withoutFilters(query dao.ListQuery, field string) dao.ListQuery {
+	query.Limit, query.Offset, query.Cursor, query.Sort = math.MaxInt32, 0, "", nil
+
+	query.Filters = slices.DeleteFunc(slices.Clone(query.Filters), func(filter dao.Filter) bool {
+		return filter.Field == field
+	})
+
+	return query
+}
+
+func filterKey(filters []dao.Filter) string {
+	var parts []string
+
+	for _, filter := range filters {
+		parts = append(parts, fmt.Sprintf("%s[%s]=%q%q", filter.Field, filter.Op, filter.Value, filter.Values))
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// countBy cuenta los productos por el valor de key (el campo field), de más
+// a menos productos, con los nombres que devuelve names.
+func countBy(
+	products []*models.Product,
+	field string,
+	key func(*models.Product) string,
+	names func(ids []string) (map[string]string, error),
+) ([]FacetBucket, error) {
+	counts := map[string]int{}
+
+	for _, product := range products {
+		counts[key(product)]++
+	}
+
+	ids := make([]string, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+
+	labels, err := names(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]FacetBucket, 0, len(ids))
+
+	for _, id := range ids {
+		buckets = append(buckets, FacetBucket{
+			Value:  id,
+			Label:  labels[id],
+			Count:  counts[id],
+			Filter: field + "[eq]=" + id,
+		})
+	}
+
+	slices.SortFunc(buckets, func(a FacetBucket, b FacetBucket) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Label+"\x00"+a.Value, b.Label+"\x00"+b.Value)
+	})
+
+	return buckets, nil
+}
+
+func (f *ProductFacets) categoryNames(ids []string) (map[string]string, error) {
+	return namesOf(f.categories, ids, func(c *models.Category) (string, string) { return c.ID, c.Name })
+}
+
+func (f *ProductFacets) sellerNames(ids []string) (map[string]string, error) {
+	return namesOf(f.sellers, ids, func(s *models.Seller) (string, string) { return s.ID, s.Name })
+}
+
+// namesOf lee en una sola consulta los nombres de las entidades ids.
+func namesOf[T any](collection dao.CrudDAO[T], ids []string, name func(*T) (string, string)) (map[string]string, error) {
+	entities, err := listByIDs(collection, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(entities))
+	for _, entity := range entities {
+		id, label := name(entity)
+		names[id] = label
+	}
+
+	return names, nil
+}
+
+// priceBuckets cuenta los productos en los rangos [límite, siguiente límite),
+// más uno por debajo del primero y otro desde el último. Están todos, aunque
+// cuenten 0.
+func priceBuckets(products []*models.Product, limits []float64) []FacetBucket {
+	buckets := make([]FacetBucket, len(limits)+1)
+
+	for i := range buckets {
+		bucket := &buckets[i]
+		var filters []string
+
+		if i > 0 {
+			bucket.From = &limits[i-1]
+			filters = append(filters, "price[gte]="+formatPrice(limits[i-1]))
+		}
+
+		if i < len(limits) {
+			bucket.To = &limits[i]
+			filters = append(filters, "price[lt]="+formatPrice(limits[i]))
+		}
+
+		from, to := "*", "*"
+		if bucket.From != nil {
+			from = formatPrice(*bucket.From)
+		}
+		if bucket.To != nil {
+			to = formatPrice(*bucket.To)
+		}
+
+		bucket.Value = from + "-" + to
+		bucket.Filter = strings.Join(filters, "&")
+	}
+
+	for _, product := range products {
+		i, found := slices.BinarySearch(limits, product.Price)
+		if found {
+			i++
+		}
+
+		buckets[i].Count++
+	}
+
+	return buckets
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// stockBuckets cuenta los productos con y sin stock. Los que no tienen el
+// campo no van a ningún bucket: tampoco cumplen ninguno de los dos filtros.
+func stockBuckets(products []*models.Product) []FacetBucket {
+	buckets := []FacetBucket{
+		{Value: "in_stock", Filter: "stock[gt]=0"},
+		{Value: "out_of_stock", Filter: "stock[lte]=0"},
+	}
+
+	for _, product := range products {
+		switch {
+		case product.Stock == nil:
+		case *product.Stock > 0:
+			buckets[0].Count++
+		default:
+			buckets[1].Count++
+		}
+	}
+
+	return buckets
+}
+
+// ratingBuckets cuenta los productos por calificación, de la más alta a la
+// más baja; sólo las que tiene algún producto.
+func ratingBuckets(products []*models.Product) []FacetBucket {
+	counts := map[int]int{}
+
+	for _, product := range products {
+		counts[product.Rate]++
+	}
+
+	buckets := make([]FacetBucket, 0, len(counts))
+
+	for _, rate := range slices.Sorted(maps.Keys(counts)) {
+		value := strconv.Itoa(rate)
+		buckets = append(buckets, FacetBucket{Value: value, Count: counts[rate], Filter: "rate[eq]=" + value})
+	}
+
+	slices.Reverse(buckets)
+
+	return buckets
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/search"
+	models "project/pkg"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// productFields son los campos de texto que indexa la búsqueda de productos,
+// con su peso: una coincidencia en el nombre vale más.
+var productFields = []search.Field{
+	{Name: "name", Weight: 3},
+	{Name: "description", Weight: 1},
+	{Name: "details", Weight: 1.5},
+}
+
+// ProductSearch es la búsqueda de texto libre sobre nombre, descripción y
+// detalles de los productos activos, y el autocompletado de nombres de
+// productos y categorías. Los índices viven en memoria y se ponen al día de
+// forma incremental antes de cada consulta: si una colección cambió desde la
+// última vez, se reindexan sólo las entidades con updated_at posterior (y se
+// sacan las que pasaron a la papelera). Así ven cualquier escritura, también
+// las hechas en una transacción o desde otro handler.
+type ProductSearch struct {
+	products   changeFeed[models.Product]
+	categories changeFeed[models.Category]
+	index      *search.Index
+	suggester  *search.Suggester
+
+	mu      sync.Mutex               // serializa Sync
+	ranked  map[string]rankedProduct // productos activos, por ID
+	names   map[string]string        // nombre de cada categoría activa
+	popular map[string]float64       // suma de rank de los productos de cada categoría
+}
+
+// rankedProduct es lo que hace falta recordar de un producto indexado para
+// actualizar el puntaje de su categoría.
+type rankedProduct struct {
+	category string
+	rank     float64
+}
+
+// SearchResult es un producto encontrado, con su puntaje y los fragmentos de
+// texto donde aparecen las palabras buscadas.
+type SearchResult struct {
+	Product    *models.Product   `json:"product"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchPage es una página de resultados, de mayor a menor relevancia.
+type SearchPage struct {
+	Items  []SearchResult `json:"items"`
+	Total  int            `json:"total"`
+	Facets Facets         `json:"facets,omitempty"`
+}
+
+// Tipos de compleción de Suggest.
+const (
+	SuggestProduct  = "product"
+	SuggestCategory = "category"
+)
+
+// Suggestion es una compleción del autocompletado: el nombre de un producto o
+// de una categoría, con su ID.
+type Suggestion struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func NewProductSearch(products dao.CrudDAO[models.Product], categories dao.CrudDAO[models.Category]) *ProductSearch {
+	return &ProductSearch{
+		products:   changeFeed[models.Product]{dao: products},
+		categories: changeFeed[models.Category]{dao: categories},
+		index:      search.NewIndex(productFields...),
+		suggester:  search.NewSuggester(),
+		ranked:     map[string]rankedProduct{},
+		names:      map[string]string{},
+		popular:    map[string]float64{},
+	}
+}
+
+// Sync pone los índices al día con las colecciones.
+func (s *ProductSearch) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	categories, err := s.categories.changes()
+	if err != nil {
+		return err
+	}
+
+	products, err := s.products.changes()
+	if err != nil {
+		return err
+	}
+
+	// Categorías cuyo nombre o puntaje cambió.
+	touched := map[string]bool{}
+
+	for _, category := range categories {
+		if category.Deleted() {
+			delete(s.names, category.ID)
+		} else {
+			s.names[category.ID] = category.Name
+		}
+
+		touched[category.ID] = true
+	}
+
+	for _, product := range products {
+		if old, ok := s.ranked[product.ID]; ok {
+			s.popular[old.category] -= old.rank
+			touched[old.category] = true
+		}
+
+		if product.Deleted() {
+			s.index.Remove(product.ID)
+			s.suggester.Remove(SuggestProduct + ":" + product.ID)
+			delete(s.ranked, product.ID)
+
+			continue
+		}
+
+		rank := productRank(product)
+
+		s.index.Put(product.ID, productTexts(product)...)
+		s.suggester.Put(SuggestProduct+":"+product.ID, SuggestProduct, product.Name, rank)
+		s.ranked[product.ID] = rankedProduct{category: product.CategoryId, rank: rank}
+
+		s.popular[product.CategoryId] += rank
+		touched[product.CategoryId] = true
+	}
+
+	for id := range touched {
+		if name, ok := s.names[id]; ok {
+			s.suggester.Put(SuggestCategory+":"+id, SuggestCategory, name, s.popular[id])
+		} else {
+			s.suggester.Remove(SuggestCategory + ":" + id)
+		}
+	}
+
+	s.products.commit()
+	s.categories.commit()
+
+	return nil
+}
+
+// Suggest devuelve hasta limit compleciones de prefix entre los nombres de
+// productos y de categorías, las más vendidas y mejor calificadas primero.
+func (s *ProductSearch) Suggest(prefix string, limit int) ([]Suggestion, error) {
+	if err := s.Sync(); err != nil {
+		return nil, fmt.Errorf("error updating the search index: %w", err)
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	suggestions := []Suggestion{}
+
+	for _, found := range s.suggester.Suggest(prefix, limit) {
+		suggestions = append(suggestions, Suggestion{
+			Text: found.Text,
+			Type: found.Kind,
+			ID:   strings.TrimPrefix(found.Key, found.Kind+":"),
+		})
+	}
+
+	return suggestions, nil
+}
+
+// Search busca q entre los productos que cumplen filters y devuelve la
+// página limit/offset de los resultados. scope es la consulta de todos los
+// productos que coinciden con q, con filters: la que usan los facets.
+func (s *ProductSearch) Search(q string, filters []dao.Filter, limit int, offset int) (page SearchPage, scope dao.ListQuery, err error) {
+	if err := s.Sync(); err != nil {
+		return SearchPage{}, scope, fmt.Errorf("error updating the search index: %w", err)
+	}
+
+	hits := s.index.Search(q)
+
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	scope.Filters = append(slices.Clone(filters), dao.Filter{Field: "id", Op: dao.OpIn, Values: ids})
+
+	if len(filters) == 0 {
+		page, err = s.page(hits, limit, offset, nil)
+		return page, scope, err
+	}
+
+	// Con filtros se leen todos los productos que coinciden, en una sola
+	// lectura: de ahí salen el total y la página, sin volver a leer.
+	products, err := s.products.dao.List(dao.ListQuery{Filters: scope.Filters, Limit: math.MaxInt32})
+	if err != nil {
+		return SearchPage{}, scope, err
+	}
+
+	byID := make(map[string]*models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	hits = slices.DeleteFunc(hits, func(hit search.Hit) bool { return byID[hit.ID] == nil })
+
+	page, err = s.page(hits, limit, offset, byID)
+
+	return page, scope, err
+}
+
+// page arma la página de hits. Los productos salen de byID o, si es nil, se
+// traen los de la página en una sola lectura.
+func (s *ProductSearch) page(hits []search.Hit, limit int, offset int, byID map[string]*models.Product) (SearchPage, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	page := SearchPage{Items: []SearchResult{}, Total: len(hits)}
+
+	offset = min(max(offset, 0), len(hits))
+	hits = hits[offset:min(offset+limit, len(hits))]
+
+	if len(hits) == 0 {
+		return page, nil
+	}
+
+	if byID == nil {
+		ids := make([]string, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.ID
+		}
+
+		products, err := s.products.dao.List(dao.ListQuery{
+			Filters: []dao.Filter{{Field: "id", Op: dao.OpIn, Values: ids}},
+			Limit:   len(ids),
+		})
+		if err != nil {
+			return SearchPage{}, err
+		}
+
+		byID = make(map[string]*models.Product, len(products))
+		for _, product := range products {
+			byID[product.ID] = product
+		}
+	}
+
+	for _, hit := range hits {
+		product, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+
+		page.Items = append(page.Items, SearchResult{
+			Product:    product,
+			Score:      hit.Score,
+			Highlights: s.index.Highlights(hit),
+		})
+	}
+
+	return page, nil
+}
+
+// productTexts arma los textos a indexar, en el orden de productFields. Los
+// detalles incluyen los de Characteristics.
+func productTexts(product *models.Product) []string {
+	var details []string
+
+	for _, detail := range product.Details {
+		details = append(details, detail.Name+": "+detail.Description)
+	}
+
+	for _, detail := range product.Characteristics.Details {
+		details = append(details, detail.Name+": "+detail.Description)
+	}
+
+	return []string{product.Name, product.Description, strings.Join(details, ". ")}
+}
+
+// productRank es el puntaje de un producto en el autocompletado: las ventas,
+// multiplicadas por la calificación (de 0 a 5, que hasta duplica el puntaje).
+// Un producto sin ventas cuenta como una, para que la calificación desempate.
+func productRank(product *models.Product) float64 {
+	return float64(product.SalesNumber+1) * (1 + float64(product.Rate)/5)
+}
+
+// changeFeed lee los cambios de una colección desde la última lectura
+// confirmada: las entidades (también las borradas) con updated_at posterior
+// al mayor ya visto, sólo si el token de LastChange se movió.
+type changeFeed[T any] struct {
+	dao dao.CrudDAO[T]
+
+	synced bool
+	token  string    // dao.Change.Token con el que se está al día
+	since  time.Time // mayor UpdatedAt visto
+
+	// Lo leído y todavía no confirmado con commit.
+	next      string
+	nextSince time.Time
+}
+
+// changes devuelve lo que cambió; nil si nada.
+func (f *changeFeed[T]) changes() ([]*T, error) {
+	// El token se lee antes de listar: una escritura entre las dos lecturas
+	// hace que la próxima lectura vuelva a mirar, nunca que se pierda.
+	change, err := f.dao.LastChange()
+	if err != nil {
+		return nil, err
+	}
+
+	f.next, f.nextSince = change.Token, f.since
+
+	if f.synced && change.Token == f.token {
+		return nil, nil
+	}
+
+	entities, err := f.dao.List(dao.ListQuery{
+		UpdatedSince: f.since,
+		Deleted:      dao.IncludeDeleted,
+		Limit:        math.MaxInt32,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range entities {
+		if tracked, ok := any(entity).(dao.Tracked); ok && tracked.Meta().UpdatedAt.After(f.nextSince) {
+			f.nextSince = tracked.Meta().UpdatedAt
+		}
+	}
+
+	return entities, nil
+}
+
+// commit confirma la última lectura de changes, una vez aplicada.
+func (f *changeFeed[T]) commit() {
+	f.synced, f.token, f.since = true, f.next, f.nextSince
+}
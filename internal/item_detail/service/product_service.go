@@ -1,8 +1,10 @@
 package service
 
 import (
+	"errors"
 	"project/internal/item_detail/repo/datasource/dao"
 	models "project/pkg"
+	"slices"
 )
 
 type ProductService struct {
@@ -10,6 +12,9 @@ type ProductService struct {
 	sellerDao   dao.SellerDAO
 	categoryDao dao.CategoryDAO
 	imageDao    dao.ImageDAO
+
+	transactor dao.Transactor
+	actor      string
 }
 
 func NewProductService(
@@ -26,10 +31,24 @@ func NewProductService(
 	}
 }
 
+// As devuelve el servicio con las escrituras de productos firmadas por actor.
+func (s *ProductService) As(actor string) *ProductService {
+	scoped := *s
+	scoped.dao = s.dao.As(actor)
+	scoped.actor = actor
+
+	return &scoped
+}
+
 func (s *ProductService) UpdateProduct(id string, entity *models.Product) (*models.Product, error) {
 	return s.dao.Update(entity, id)
 }
 
+// ModifyProduct aplica mutate sobre el producto guardado de forma atómica.
+func (s *ProductService) ModifyProduct(id string, mutate func(product *models.Product) error) (*models.Product, error) {
+	return s.dao.Modify(id, mutate)
+}
+
 func (s *ProductService) GetProduct(id string) (*models.Product, error) {
 	return s.dao.GetByID(id)
 }
@@ -53,3 +72,171 @@ func (s *ProductService) GetSellerService() dao.SellerDAO {
 func (s *ProductService) GetImageService() dao.ImageDAO {
 	return s.imageDao
 }
+
+// WithTransactor habilita las operaciones que escriben varias colecciones
+// juntas (Transact y las que lo usan).
+func (s *ProductService) WithTransactor(transactor dao.Transactor) *ProductService {
+	s.transactor = transactor
+	return s
+}
+
+// Transact ejecuta fn con un servicio cuyos DAO escriben dentro de una misma
+// transacción: si fn devuelve error no queda aplicada ninguna escritura. Los
+// productos se escriben con los controles de Integrity.
+func (s *ProductService) Transact(fn func(tx *ProductService) error) error {
+	if s.transactor == nil {
+		return dao.Errorf(dao.ErrStorageUnavailable, "product service has no transactor")
+	}
+
+	return s.transactor.Transact(func(uow dao.UnitOfWork) error {
+		integrity := NewIntegrity(uow.Products(), uow.Sellers(), uow.Categories(), uow.Images(), RestrictDelete)
+
+		tx := &ProductService{
+			dao:         integrity.Products().As(s.actor),
+			sellerDao:   uow.Sellers().As(s.actor),
+			categoryDao: uow.Categories().As(s.actor),
+			imageDao:    uow.Images().As(s.actor),
+			actor:       s.actor,
+		}
+
+		return fn(tx)
+	})
+}
+
+// CreateWithImages crea las imágenes y el producto que las usa en una sola
+// operación. Las imágenes se agregan a las que ya traiga el producto, en una
+// copia: product no cambia.
+func (s *ProductService) CreateWithImages(product *models.Product, images []*models.Image) (*models.Product, error) {
+	var created *models.Product
+
+	err := s.Transact(func(tx *ProductService) error {
+		draft := *product
+		draft.Images = slices.Clone(product.Images)
+
+		for _, image := range images {
+			saved, err := tx.imageDao.Create(image)
+			if err != nil {
+				return err
+			}
+
+			draft.Images = append(draft.Images, saved.ID)
+		}
+
+		var err error
+		created, err = tx.dao.Create(&draft)
+
+		return err
+	})
+
+	return created, err
+}
+
+// AddNewImage crea image y la agrega al producto id en una sola operación.
+// expected (0: sin control) es la versión del producto que espera el cliente.
+func (s *ProductService) AddNewImage(id string, image *models.Image, expected int64) (*models.Product, error) {
+	var updated *models.Product
+
+	err := s.Transact(func(tx *ProductService) error {
+		saved, err := tx.imageDao.Create(image)
+		if err != nil {
+			return err
+		}
+
+		updated, err = tx.dao.Modify(id, func(product *models.Product) error {
+			if err := expectVersion(product, expected); err != nil {
+				return err
+			}
+
+			product.Images = append(product.Images, saved.ID)
+			return nil
+		})
+
+		return err
+	})
+
+	return updated, err
+}
+
+// AddImage agrega al producto id la imagen existente imageID. La imagen no
+// puede desaparecer entre la verificación y la escritura. Si el producto ya
+// la tiene no cambia nada.
+func (s *ProductService) AddImage(id string, imageID string, expected int64) (*models.Product, error) {
+	return s.setReference(id, expected,
+		func(tx *ProductService) error { return referenced(tx.imageDao, "image", imageID) },
+		func(product *models.Product) {
+			if !slices.Contains(product.Images, imageID) {
+				product.Images = append(product.Images, imageID)
+			}
+		},
+	)
+}
+
+// ChangeCategory apunta el producto id a la categoría categoryID. La
+// categoría no puede desaparecer entre la verificación y la escritura.
+func (s *ProductService) ChangeCategory(id string, categoryID string, expected int64) (*models.Product, error) {
+	return s.setReference(id, expected,
+		func(tx *ProductService) error { return referenced(tx.categoryDao, "category", categoryID) },
+		func(product *models.Product) { product.CategoryId = categoryID },
+	)
+}
+
+// ChangeSeller apunta el producto id al vendedor sellerID. El vendedor no
+// puede desaparecer entre la verificación y la escritura.
+func (s *ProductService) ChangeSeller(id string, sellerID string, expected int64) (*models.Product, error) {
+	return s.setReference(id, expected,
+		func(tx *ProductService) error { return referenced(tx.sellerDao, "seller", sellerID) },
+		func(product *models.Product) { product.SellerId = sellerID },
+	)
+}
+
+// setReference verifica con exists la entidad a la que va a apuntar el
+// producto id y le aplica set, en una sola transacción.
+func (s *ProductService) setReference(
+	id string,
+	expected int64,
+	exists func(tx *ProductService) error,
+	set func(product *models.Product),
+) (*models.Product, error) {
+	var updated *models.Product
+
+	err := s.Transact(func(tx *ProductService) error {
+		if err := exists(tx); err != nil {
+			return err
+		}
+
+		var err error
+		updated, err = tx.dao.Modify(id, func(product *models.Product) error {
+			if err := expectVersion(product, expected); err != nil {
+				return err
+			}
+
+			set(product)
+			return nil
+		})
+
+		return err
+	})
+
+	return updated, err
+}
+
+// referenced devuelve ErrInvalidReference si id no está en d.
+func referenced[T any](d dao.CrudDAO[T], entity string, id string) error {
+	if _, err := d.GetByID(id); err != nil {
+		if errors.Is(err, dao.ErrNotFound) {
+			return dao.Errorf(dao.ErrInvalidReference, "%s %q doesn't exist", entity, id)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func expectVersion(product *models.Product, expected int64) error {
+	if expected != 0 && product.Version != expected {
+		return dao.Errorf(dao.ErrVersionMismatch, "Version mismatch: expected %d, current is %d", expected, product.Version)
+	}
+
+	return nil
+}
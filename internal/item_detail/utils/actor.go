@@ -0,0 +1,19 @@
+package utils
+
+import "github.com/labstack/echo/v4"
+
+// actorKey es la clave del contexto de echo donde el middleware de API key
+// deja la identidad de quien hace la request.
+const actorKey = "actor"
+
+// SetActor registra la identidad autenticada de la request.
+func SetActor(c echo.Context, actor string) {
+	c.Set(actorKey, actor)
+}
+
+// Actor devuelve la identidad autenticada de la request ("" si no hay).
+func Actor(c echo.Context) string {
+	actor, _ := c.Get(actorKey).(string)
+
+	return actor
+}
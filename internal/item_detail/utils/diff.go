@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MetadataFields son los campos JSON de models.Metadata: cambian en cada
+// escritura, así que no aportan a un diff de contenido.
+var MetadataFields = []string{
+	"version", "created_at", "created_by", "updated_at", "updated_by", "deleted_at", "deleted_by",
+}
+
+// FieldChange es un campo que cambió entre dos versiones de una entidad.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffFields compara dos entidades campo a campo a partir de su JSON. Los
+// objetos anidados se recorren y se nombran con puntos
+// ("characteristics.name"); los arrays se comparan enteros. Los campos de
+// primer nivel de skip se ignoran. El resultado está ordenado por campo.
+func DiffFields(from any, to any, skip ...string) ([]FieldChange, error) {
+	before, err := jsonObject(from)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := jsonObject(to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range skip {
+		delete(before, field)
+		delete(after, field)
+	}
+
+	changes := []FieldChange{}
+	diffObjects("", before, after, &changes)
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes, nil
+}
+
+func diffObjects(prefix string, before map[string]any, after map[string]any, changes *[]FieldChange) {
+	keys := map[string]bool{}
+
+	for k := range before {
+		keys[k] = true
+	}
+
+	for k := range after {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		oldValue, newValue := before[k], after[k]
+
+		oldObject, oldIsObject := oldValue.(map[string]any)
+		newObject, newIsObject := newValue.(map[string]any)
+
+		if oldIsObject && newIsObject {
+			diffObjects(prefix+k+".", oldObject, newObject, changes)
+			continue
+		}
+
+		if !reflect.DeepEqual(oldValue, newValue) {
+			*changes = append(*changes, FieldChange{Field: prefix + k, From: oldValue, To: newValue})
+		}
+	}
+}
+
+func jsonObject(value any) (map[string]any, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding entity: %w", err)
+	}
+
+	object := map[string]any{}
+
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("error decoding entity: %w", err)
+	}
+
+	return object, nil
+}
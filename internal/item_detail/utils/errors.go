@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"project/internal/item_detail/repo/datasource/dao"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -88,3 +89,44 @@ func ValidateBody(c echo.Context, err error) error {
 		"error": ErrInvalidBody.Error(),
 	})
 }
+
+// ---------------------
+// StorageError
+// ---------------------
+
+// StorageErrorStatus traduce las categorías de error de dao a códigos HTTP.
+func StorageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dao.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dao.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, dao.ErrInvalidUpdate), errors.Is(err, dao.ErrInvalidReference):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, dao.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, dao.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, dao.ErrStorageUnavailable):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// StorageError responde un error del storage con el status que le corresponde.
+// Un conflicto de unicidad agrega el campo y el ID de la entidad que ya usa el
+// valor.
+func StorageError(c echo.Context, err error) error {
+	body := map[string]string{
+		"error": err.Error(),
+	}
+
+	var violation *dao.UniqueViolation
+	if errors.As(err, &violation) {
+		body["field"] = violation.Field
+		body["conflicting_id"] = violation.ID
+	}
+
+	return c.JSON(StorageErrorStatus(err), body)
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EntityETag devuelve el ETag de una entidad versionada, o "" si no lleva versión.
+func EntityETag(entity any) string {
+	tracked, ok := entity.(dao.Tracked)
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf(`"%d"`, tracked.Meta().Version)
+}
+
+// EntityModified devuelve el momento de la última escritura de la entidad
+// (cero si no lo lleva).
+func EntityModified(entity any) time.Time {
+	tracked, ok := entity.(dao.Tracked)
+	if !ok {
+		return time.Time{}
+	}
+
+	return tracked.Meta().UpdatedAt
+}
+
+// CompositeETag arma un ETag opaco a partir de varias partes, para respuestas
+// que combinan más de una entidad o que representan una colección entera.
+func CompositeETag(weak bool, parts ...string) string {
+	h := fnv.New64a()
+
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+
+	etag := fmt.Sprintf(`"%x"`, h.Sum64())
+	if weak {
+		return "W/" + etag
+	}
+
+	return etag
+}
+
+// SetETag agrega el header ETag a la respuesta si la entidad lleva versión.
+func SetETag(c echo.Context, entity any) {
+	if etag := EntityETag(entity); etag != "" {
+		c.Response().Header().Set("ETag", etag)
+	}
+}
+
+// NotModified agrega ETag y Last-Modified a la respuesta e indica si el
+// cliente ya tiene esa representación (If-None-Match, o If-Modified-Since
+// cuando no vino If-None-Match). Un etag vacío o un modified cero no se envían.
+func NotModified(c echo.Context, etag string, modified time.Time) bool {
+	header := c.Response().Header()
+	req := c.Request()
+
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+
+	if !modified.IsZero() {
+		header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && etagListMatches(inm, etag)
+	}
+
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		since, err := http.ParseTime(ims)
+
+		// Las fechas HTTP tienen resolución de segundos.
+		return err == nil && !modified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// CachedJSON responde body como JSON con sus validadores, o 304 si el cliente
+// ya lo tiene.
+func CachedJSON(c echo.Context, code int, body any, etag string, modified time.Time) error {
+	if NotModified(c, etag, modified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSON(code, body)
+}
+
+// etagListMatches aplica la comparación débil de If-None-Match: se ignora el
+// prefijo W/ y alcanza con que coincida cualquiera de la lista (o "*").
+func etagListMatches(list string, etag string) bool {
+	opaque := strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IfMatchVersion interpreta el header If-Match como la versión que el cliente
+// espera modificar. Devuelve 0 si no hay header o si es "*". Un valor que no
+// es un ETag fuerte de este API nunca puede coincidir: es ErrVersionMismatch.
+// Se acepta un solo ETag.
+func IfMatchVersion(c echo.Context) (int64, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err == nil {
+		if version, err := strconv.ParseInt(unquoted, 10, 64); err == nil && version > 0 {
+			return version, nil
+		}
+	}
+
+	return 0, dao.Errorf(dao.ErrVersionMismatch, "If-Match %s doesn't match the current version", header)
+}
+
+// ExpectVersion copia la versión de If-Match en la entidad del update, para
+// que el dao la compare con la guardada. Los datos de control que traiga el
+// body se descartan: sin header no hay precondición, aunque venga "version".
+func ExpectVersion(c echo.Context, entity any) error {
+	version, err := IfMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	if tracked, ok := entity.(dao.Tracked); ok {
+		*tracked.Meta() = models.Metadata{Version: version}
+	}
+
+	return nil
+}
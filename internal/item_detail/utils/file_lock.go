@@ -0,0 +1,69 @@
+//go:build unix
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// LockFile toma un lock advisory del sistema operativo (flock) sobre
+// <filename>.json.lock, compartido o exclusivo. Sirve para coordinar procesos
+// distintos (otra instancia de la API, un job de importación, una CLI) que
+// operan sobre los mismos archivos. Se usa un archivo aparte porque
+// WriteJSON reemplaza el .json con un rename y el lock se perdería.
+//
+// Devuelve la función que libera el lock.
+func LockFile(filename string, exclusive bool) (func() error, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	return flockFile(filename, how, exclusive)
+}
+
+// TryLockFile toma el lock exclusivo sin esperar: si otro proceso lo tiene,
+// devuelve ErrFileLocked.
+func TryLockFile(filename string) (func() error, error) {
+	unlock, err := flockFile(filename, syscall.LOCK_EX|syscall.LOCK_NB, true)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return nil, fmt.Errorf("%s: %w", filename, ErrFileLocked)
+	}
+
+	return unlock, err
+}
+
+func flockFile(filename string, how int, exclusive bool) (func() error, error) {
+	path := filename + ".json.lock"
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil && !exclusive {
+		// Un lector en un directorio de solo lectura todavía puede tomar
+		// el lock compartido si el archivo ya existe.
+		file, err = os.Open(path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el lock %s: %w", path, err)
+	}
+
+	for {
+		err = syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error al tomar el lock %s: %w", path, err)
+	}
+
+	return func() error {
+		defer file.Close()
+		return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	}, nil
+}
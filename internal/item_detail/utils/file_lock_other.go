@@ -0,0 +1,15 @@
+//go:build !unix
+
+package utils
+
+// LockFile no tiene implementación fuera de sistemas unix: sólo quedan los
+// locks dentro del proceso, así que no es seguro compartir los archivos con
+// otros procesos.
+func LockFile(filename string, exclusive bool) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+// TryLockFile tampoco bloquea nada fuera de sistemas unix.
+func TryLockFile(filename string) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -2,46 +2,122 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// ErrCorruptJSON indica que el archivo existe pero su contenido no se puede decodificar.
+var ErrCorruptJSON = errors.New("corrupt JSON file")
+
+// ErrFileLocked indica que otro proceso tiene el lock exclusivo del archivo.
+var ErrFileLocked = errors.New("file is locked by another process")
+
 // ReadJSON lee un archivo JSON y lo deserializa en la variable destino (struct o map).
+// Un archivo inexistente o vacío se considera sin datos.
 func ReadJSON(filename string, dest interface{}) error {
+	_, err := ReadJSONStat(filename, dest)
+
+	return err
+}
+
+// ReadJSONStat hace lo mismo que ReadJSON y además devuelve la info del archivo
+// leído (tomada del mismo file descriptor), o nil si el archivo no existe.
+func ReadJSONStat(filename string, dest interface{}) (os.FileInfo, error) {
 	file, err := os.Open(filename + ".json")
 
 	if os.IsNotExist(err) {
-		return nil
+		return nil, nil
 	}
 
 	if err != nil {
-		return fmt.Errorf("error al abrir el archivo %s: %w", filename, err)
+		return nil, fmt.Errorf("error al abrir el archivo %s: %w", filename, err)
 	}
 	defer file.Close()
 
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el archivo %s: %w", filename, err)
+	}
+
+	if info.Size() == 0 {
+		return info, nil
+	}
+
 	if err := json.NewDecoder(file).Decode(dest); err != nil {
-		return fmt.Errorf("error al decodificar JSON %s: %w", filename, err)
+		return nil, fmt.Errorf("error al decodificar JSON %s: %w: %w", filename, ErrCorruptJSON, err)
 	}
 
-	return nil
+	return info, nil
 }
 
 // WriteJSON guarda una estructura o mapa en un archivo JSON, formateado bonito.
-func WriteJSON(filename string, data interface{}) error {
-	file, err := os.Create(filename + ".json")
+// La escritura es atómica: se codifica en un archivo temporal del mismo
+// directorio, se sincroniza a disco y recién entonces se renombra sobre el
+// destino, así un crash o un error a mitad de camino nunca deja el archivo truncado.
+func WriteJSON(filename string, data interface{}) (err error) {
+	path := filename + ".json"
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
 	if err != nil {
 		return fmt.Errorf("error al crear el archivo %s: %w", filename, err)
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	encoder := json.NewEncoder(tmp)
 	encoder.SetIndent("", "  ")
 
 	if err := encoder.Encode(data); err != nil {
 		return fmt.Errorf("error al codificar JSON: %w", err)
 	}
 
-	return nil
+	if err := tmp.Chmod(fileMode(path)); err != nil {
+		return fmt.Errorf("error al escribir el archivo %s: %w", filename, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("error al escribir el archivo %s: %w", filename, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error al escribir el archivo %s: %w", filename, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error al reemplazar el archivo %s: %w", filename, err)
+	}
+
+	committed = true
+
+	return SyncDir(dir)
+}
+
+// QuarantineJSON aparta un archivo JSON que no se puede decodificar,
+// renombrándolo con un sufijo `.corrupt-<timestamp>` para poder recuperarlo a mano.
+// Devuelve la ruta final del archivo en cuarentena.
+func QuarantineJSON(filename string) (string, error) {
+	path := filename + ".json"
+	target := fmt.Sprintf("%s.corrupt-%s", path, time.Now().UTC().Format("20060102T150405.000000000"))
+
+	if err := os.Rename(path, target); err != nil {
+		return "", fmt.Errorf("error al poner en cuarentena el archivo %s: %w", filename, err)
+	}
+
+	if err := SyncDir(filepath.Dir(path)); err != nil {
+		return "", err
+	}
+
+	return target, nil
 }
 
 // UpdateJSON actualiza un campo específico dentro de un JSON (sin perder el resto de los datos).
@@ -53,7 +129,9 @@ func UpdateJSON(filename string, key string, newValue interface{}) error {
 		if err := ReadJSON(filename, &data); err != nil {
 			return err
 		}
-	} else {
+	}
+
+	if data == nil {
 		data = make(map[string]interface{})
 	}
 
@@ -62,3 +140,28 @@ func UpdateJSON(filename string, key string, newValue interface{}) error {
 
 	return WriteJSON(filename, data)
 }
+
+// fileMode conserva los permisos del archivo existente (o usa 0644 si todavía no existe).
+func fileMode(path string) os.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+
+	return 0644
+}
+
+// SyncDir sincroniza el directorio para que un rename o un archivo nuevo
+// sobrevivan a un corte de luz.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("error al abrir el directorio %s: %w", dir, err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("error al sincronizar el directorio %s: %w", dir, err)
+	}
+
+	return nil
+}
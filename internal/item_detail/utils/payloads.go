@@ -22,3 +22,22 @@ type ProductPayload struct {
 type ChangeAttributePayload struct {
 	ID string `json:"id"`
 }
+
+// AddImagePayload es el body de POST /products/:id/images: el ID de una
+// imagen existente o los datos de una nueva, que se crea junto con el cambio.
+type AddImagePayload struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// NewImage indica si el body describe una imagen a crear.
+func (p AddImagePayload) NewImage() bool {
+	return p.Name != "" || p.URL != ""
+}
+
+// CreateWithImagesPayload es el body de POST /products/with-images.
+type CreateWithImagesPayload struct {
+	Product models.Product  `json:"product"`
+	Images  []*models.Image `json:"images" validate:"dive"`
+}
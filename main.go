@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"project/cmd/routes"
+	"project/internal/item_detail/repo/datasource/dal"
 	"project/pkg/logger"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 )
 
 func main() {
@@ -21,14 +29,64 @@ func main() {
 		log.Fatal("Error cargando el archivo .env")
 	}
 
+	cfg := dal.StorageConfigFromEnv()
+
+	storage, err := dal.OpenStorage(cfg)
+	if err != nil {
+		log.Fatalf("Error configurando el storage: %v", err)
+	}
+
+	logger.Log.Info("storage ready",
+		zap.String("driver", cfg.Driver),
+		zap.String("data_dir", cfg.DataDir),
+	)
+
 	router := echo.New()
 
-	r := routes.Routes(router)
+	r := routes.Routes(router, storage)
 
 	r.Use(logger.LoggerMiddleware)
 
 	PORT := os.Getenv("PORT")
 	port := fmt.Sprintf(":%s", PORT)
 
-	r.Logger.Fatal(r.Start(port))
+	// Al recibir SIGINT/SIGTERM se deja de aceptar conexiones, se esperan las
+	// requests en curso y recién después se cierra el storage (que vuelca los
+	// datos pendientes).
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	failed := false
+
+	go func() {
+		if err := r.Start(port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.Error("server stopped", zap.Error(err))
+			failed = true
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Log.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := r.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Error("error shutting down the server", zap.Error(err))
+		failed = true
+	}
+
+	if err := storage.Close(); err != nil {
+		logger.Log.Error("error closing the storage", zap.Error(err))
+		failed = true
+	}
+
+	if failed {
+		logger.Sync()
+		os.Exit(1)
+	}
 }
+
+// shutdownTimeout es lo que se espera a que terminen las requests en curso.
+const shutdownTimeout = 10 * time.Second
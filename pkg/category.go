@@ -4,7 +4,9 @@ import "github.com/google/uuid"
 
 type Category struct {
 	ID   string `json:"id"`
-	Name string `json:"name" validate:"required"`
+	Name string `json:"name" validate:"required" unique:"nocase"`
+
+	Metadata
 }
 
 func (p *Category) Init() {
@@ -5,7 +5,9 @@ import "github.com/google/uuid"
 type Image struct {
 	ID   string `json:"id"`
 	Name string `json:"name" validate:"required"`
-	URL  string `json:"url" validate:"required"`
+	URL  string `json:"url" validate:"required" unique:"true"`
+
+	Metadata
 }
 
 func (p *Image) Init() {
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Metadata son los datos de control que el storage mantiene en cada entidad.
+// Se embebe en los modelos; los clientes no la pueden modificar con un PATCH.
+type Metadata struct {
+	// Version arranca en 1 al crear la entidad y sube en cada modificación.
+	Version int64 `json:"version"`
+	// CreatedAt es el momento del alta (UTC).
+	CreatedAt time.Time `json:"created_at"`
+	// CreatedBy es la identidad de la API key que dio de alta la entidad.
+	CreatedBy string `json:"created_by,omitempty"`
+	// UpdatedAt es el momento de la última escritura (UTC).
+	UpdatedAt time.Time `json:"updated_at"`
+	// UpdatedBy es la identidad de la API key de la última escritura.
+	UpdatedBy string `json:"updated_by,omitempty"`
+	// DeletedAt marca la entidad como borrada (en la papelera) desde ese
+	// momento; nil si está activa.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// DeletedBy es la identidad de la API key que la borró.
+	DeletedBy string `json:"deleted_by,omitempty"`
+}
+
+// Deleted indica si la entidad está en la papelera.
+func (m *Metadata) Deleted() bool {
+	return m.DeletedAt != nil
+}
+
+// Meta da acceso a los datos de control; el storage la usa para mantenerlos.
+func (m *Metadata) Meta() *Metadata {
+	return m
+}
@@ -20,17 +20,19 @@ type Product struct {
 	SalesNumber  int             `json:"sales_number"`
 	Description  string          `json:"description"`
 
-	CategoryId string `json:"categoryId" validate:"required"`
-	SellerId   string `json:"sellerId" validate:"required"`
+	CategoryId string `json:"categoryId" validate:"required" index:"true"`
+	SellerId   string `json:"sellerId" validate:"required" index:"true"`
 
 	Characteristics  ProductCharacteristic `json:"characteristics"`
-	InstallmentPrice float64               `json:"installmentPrice"`
-	DiscountPrice    float64               `json:"discountPrice"`
+	InstallmentPrice float64               `json:"installmentPrice" filter:"-"`
+	DiscountPrice    float64               `json:"discountPrice" filter:"-"`
 
 	// HATEOAS
 	Category   HATEOASLink   `json:"category"`
 	Seller     HATEOASLink   `json:"seller"`
 	ImageLinks []HATEOASLink `json:"image_links"`
+
+	Metadata
 }
 
 type HATEOASLink struct {
@@ -45,6 +47,8 @@ func (p *Product) Init() {
 	// Construcción de HATEOAS
 	base := os.Getenv("BASE_URL")
 
+	// Sin categoría o vendedor (ver DELETE_POLICY=nullify) no queda link
+	p.Category, p.Seller = HATEOASLink{}, HATEOASLink{}
 	if p.CategoryId != "" {
 		p.Category = HATEOASLink{
 			Href: fmt.Sprintf("%s/categories/%s", base, p.CategoryId),
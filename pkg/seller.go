@@ -7,6 +7,8 @@ type Seller struct {
 	Name     string `json:"name" validate:"required"`
 	Address  string `json:"address" validate:"required"`
 	Verified bool   `json:"verified"`
+
+	Metadata
 }
 
 func (p *Seller) Init() {
@@ -0,0 +1,93 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditMetadata_StampsApiKeyIdentity(t *testing.T) {
+	t.Log("🔍 TEST: POST/PATCH record timestamps and the identity of the API key")
+
+	t.Setenv("API_KEYS", "backoffice:bo-key, sync:sync-key")
+	e, _ := newTestServer(t)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/categories", `{"name":"Mates","created_by":"forged"}`, "X-API-Key", "bo-key")
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var created models.Category
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, "backoffice", created.CreatedBy)
+	assert.Equal(t, "backoffice", created.UpdatedBy)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/"+created.ID, `{"name":"Termos"}`, "X-API-Key", "sync-key")
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var updated models.Category
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.Equal(t, "backoffice", updated.CreatedBy)
+	assert.Equal(t, "sync", updated.UpdatedBy)
+
+	// API_KEY sigue funcionando, con la identidad "default"
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/"+created.ID, `{"name":"Yerbas"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.Equal(t, "default", updated.UpdatedBy)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories", "", "X-API-Key", "otra")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	t.Log("✅ Every write is signed with who made it")
+}
+
+func TestAuditMetadata_ProductAttributeChangeStampsIdentity(t *testing.T) {
+	t.Log("🔍 TEST: PATCH /products/:id/category also records who made the change")
+
+	t.Setenv("API_KEYS", "backoffice:bo-key")
+	e, st := newTestServer(t)
+	seed(t, st.Products, createTestProduct())
+	seed(t, st.Categories, models.Category{ID: "cat-2", Name: "Termos"})
+
+	rec := doRequest(e, http.MethodPatch, "/api/v1/products/1/category", `{"id":"cat-2"}`, "X-API-Key", "bo-key")
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	product, err := st.Products.GetByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, "backoffice", product.UpdatedBy)
+
+	t.Log("✅ Attribute changes are signed too")
+}
+
+func TestAuditMetadata_UpdatedSinceFilter(t *testing.T) {
+	t.Log("🔍 TEST: GET ?updated_since= returns only what changed since that moment")
+
+	e, st := newTestServer(t)
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"}, models.Category{ID: "c2", Name: "Termos"})
+
+	since := time.Now().UTC()
+
+	rec := doRequest(e, http.MethodPatch, "/api/v1/categories/c2", `{"name":"Bombillas"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories?updated_since="+url.QueryEscape(since.Format(time.RFC3339Nano)), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var delta dao.Page[models.Category]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &delta))
+	require.Len(t, delta.Items, 1)
+	assert.Equal(t, "c2", delta.Items[0].ID)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories?updated_since=ayer", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	t.Log("✅ Nightly sync can pull only the delta")
+}
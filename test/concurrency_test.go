@@ -0,0 +1,141 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/rest"
+	"project/internal/item_detail/service"
+	models "project/pkg"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/* ===========================================================
+   Stress tests de concurrencia. Pensados para correr con el
+   race detector:  go test -race -run Concurrent project/test
+   =========================================================== */
+
+const stressWorkers = 40
+
+func TestConcurrent_JSONCreates_NoLostWrites(t *testing.T) {
+	t.Log("🔍 TEST: Concurrent Create() calls on the same JSON file keep every entity")
+
+	filename := filepath.Join(t.TempDir(), "Stress")
+
+	var wg sync.WaitGroup
+	for i := 0; i < stressWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Instancias distintas sobre el mismo archivo comparten el lock
+			repo := &dal.CrudDAL[models.Category]{Filename: filename}
+			_, err := repo.Create(&models.Category{ID: fmt.Sprintf("%d", i), Name: fmt.Sprintf("Cat %d", i)})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	repo := &dal.CrudDAL[models.Category]{Filename: filename}
+	all, err := repo.GetAll("", 1000, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, stressWorkers)
+
+	t.Log("✅ No creates were lost")
+}
+
+func TestConcurrent_ReadsAndWritesAcrossCollections(t *testing.T) {
+	t.Log("🔍 TEST: Reads and writes on different collections run concurrently without races")
+
+	st, err := dal.OpenStorage(dal.StorageConfig{Driver: "json", DataDir: t.TempDir()})
+	require.NoError(t, err)
+
+	seed(t, st.Products, models.Product{ID: "p1", Name: "Producto"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < stressWorkers; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			_, err := st.Sellers.Create(&models.Seller{ID: fmt.Sprintf("s%d", i), Name: "Seller"})
+			assert.NoError(t, err)
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			p, err := st.Products.GetByID("p1")
+			assert.NoError(t, err)
+			assert.Equal(t, "Producto", p.Name)
+		}()
+	}
+	wg.Wait()
+
+	sellers, err := st.Sellers.GetAll("", 1000, 0)
+	require.NoError(t, err)
+	assert.Len(t, sellers, stressWorkers)
+
+	t.Log("✅ Concurrent reads and writes completed consistently")
+}
+
+func TestConcurrent_AddImages_NoLostUpdates(t *testing.T) {
+	t.Log("🔍 TEST: Concurrent POST /products/:id/images keep every appended image")
+
+	for _, driver := range []string{"json", "memory", "sqlite", "journal"} {
+		t.Run(driver, func(t *testing.T) {
+			st, err := dal.OpenStorage(dal.StorageConfig{Driver: driver, DataDir: t.TempDir()})
+			require.NoError(t, err)
+			t.Cleanup(func() { st.Close() })
+
+			seed(t, st.Products, createProductForImages())
+
+			for i := 0; i < stressWorkers; i++ {
+				seed(t, st.Images, models.Image{ID: fmt.Sprintf("img-%d", i), Name: "Imagen"})
+			}
+
+			svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images).WithTransactor(st)
+			handler := rest.NewProductHandler(svc)
+			e := echo.New()
+
+			var wg sync.WaitGroup
+			for i := 0; i < stressWorkers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+
+					body := fmt.Sprintf(`{"id":"img-%d"}`, i)
+					req := httptest.NewRequest("POST", "/products/prod-1/images", strings.NewReader(body))
+					req.Header.Set("Content-Type", "application/json")
+					rec := httptest.NewRecorder()
+
+					c := e.NewContext(req, rec)
+					c.SetParamNames("id")
+					c.SetParamValues("prod-1")
+
+					assert.NoError(t, handler.AddImages(c))
+					assert.Equal(t, http.StatusCreated, rec.Code)
+				}(i)
+			}
+			wg.Wait()
+
+			product, err := st.Products.GetByID("prod-1")
+			require.NoError(t, err)
+			assert.Len(t, product.Images, stressWorkers+1)
+
+			for i := 0; i < stressWorkers; i++ {
+				assert.Contains(t, product.Images, fmt.Sprintf("img-%d", i))
+			}
+		})
+	}
+
+	t.Log("✅ Every concurrent image append was persisted")
+}
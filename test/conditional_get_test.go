@@ -0,0 +1,127 @@
+package main_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalGet_EntityIfNoneMatch(t *testing.T) {
+	t.Log("🔍 TEST: GET /:id answers 304 when If-None-Match has the current ETag")
+
+	e, st := newTestServer(t)
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"})
+
+	rec := doRequest(e, http.MethodGet, "/api/v1/categories/c1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+	assert.NotEmpty(t, rec.Header().Get("Last-Modified"))
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1", "", "If-None-Match", `"7", W/"1"`)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/c1", `{"name":"Termos"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	t.Log("✅ Unchanged entities are not sent again")
+}
+
+func TestConditionalGet_IfModifiedSince(t *testing.T) {
+	t.Log("🔍 TEST: If-Modified-Since is honoured when If-None-Match is absent")
+
+	e, st := newTestServer(t)
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"})
+
+	rec := doRequest(e, http.MethodGet, "/api/v1/categories/c1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	modified := rec.Header().Get("Last-Modified")
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1", "", "If-Modified-Since", modified)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1", "", "If-Modified-Since", past)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// If-None-Match manda sobre If-Modified-Since
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1", "", "If-None-Match", `"9"`, "If-Modified-Since", modified)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	t.Log("✅ Dates validate the response too")
+}
+
+func TestConditionalGet_ProductSubResources(t *testing.T) {
+	t.Log("🔍 TEST: product sub-resources expose validators and answer 304")
+
+	e, st := newTestServer(t)
+	product := createTestProduct()
+	seed(t, st.Products, product)
+	seed(t, st.Categories, models.Category{ID: "100", Name: "Mates"}, models.Category{ID: "101", Name: "Termos"})
+	seed(t, st.Images, models.Image{ID: product.Images[0], URL: "https://img/1.png"})
+
+	for _, path := range []string{"details", "characteristic", "category", "images"} {
+		rec := doRequest(e, http.MethodGet, "/api/v1/products/1/"+path, "")
+		require.Less(t, rec.Code, 300, path)
+		etag := rec.Header().Get("ETag")
+		require.NotEmpty(t, etag, path)
+		assert.NotEmpty(t, rec.Header().Get("Last-Modified"), path)
+
+		rec = doRequest(e, http.MethodGet, "/api/v1/products/1/"+path, "", "If-None-Match", etag)
+		assert.Equal(t, http.StatusNotModified, rec.Code, path)
+	}
+
+	rec := doRequest(e, http.MethodGet, "/api/v1/products/1/category", "")
+	categoryETag := rec.Header().Get("ETag")
+
+	// Cambiar la categoría del producto cambia la representación del sub-recurso
+	rec = doRequest(e, http.MethodPatch, "/api/v1/products/1/category", `{"id":"101"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/1/category", "", "If-None-Match", categoryETag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, categoryETag, rec.Header().Get("ETag"))
+
+	t.Log("✅ Sub-resources are cacheable and invalidated by changes")
+}
+
+func TestConditionalGet_ListingWeakETag(t *testing.T) {
+	t.Log("🔍 TEST: listings carry a weak ETag that changes with the collection")
+
+	e, st := newTestServer(t)
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"})
+
+	rec := doRequest(e, http.MethodGet, "/api/v1/categories", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.Regexp(t, `^W/".+"$`, etag)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	// Otra query es otra representación
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories?limit=1", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	seed(t, st.Categories, models.Category{ID: "c2", Name: "Termos"})
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	t.Log("✅ Listing ETag follows the last change of the collection")
+}
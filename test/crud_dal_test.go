@@ -253,3 +253,45 @@ func reflectUpdateData[T any](src *T, dst *T) (*T, bool) {
 
 	return dst, wasUpdated
 }
+
+func TestCRUD_DAL_Create_CorruptFileIsQuarantined(t *testing.T) {
+	t.Log("🔍 TEST: Ensures Create() refuses to overwrite an undecodable file and quarantines it")
+
+	dir := t.TempDir()
+	tmpFile := filepath.Join(dir, "corrupt")
+
+	truncated := `[{"ID":"1","Name":"Uno","Price":1},{"ID":"2","Na`
+	assert.NoError(t, os.WriteFile(tmpFile+".json", []byte(truncated), 0644))
+
+	repo := &dal.CrudDAL[MockEntity]{Filename: tmpFile}
+
+	_, err := repo.Create(&MockEntity{ID: "3", Name: "Tres"})
+	assert.ErrorIs(t, err, utils.ErrCorruptJSON)
+
+	matches, _ := filepath.Glob(tmpFile + ".json.corrupt-*")
+	assert.Len(t, matches, 1)
+
+	content, _ := os.ReadFile(matches[0])
+	assert.Equal(t, truncated, string(content))
+
+	t.Log("✅ Corrupt file quarantined and write refused")
+}
+
+func TestCRUD_DAL_Update_CorruptFileIsQuarantined(t *testing.T) {
+	t.Log("🔍 TEST: Ensures Update() also refuses to write over an undecodable file")
+
+	dir := t.TempDir()
+	tmpFile := filepath.Join(dir, "corrupt_update")
+
+	assert.NoError(t, os.WriteFile(tmpFile+".json", []byte("{oops"), 0644))
+
+	repo := &dal.CrudDAL[MockEntity]{Filename: tmpFile}
+
+	_, err := repo.Update(&MockEntity{Name: "Nuevo"}, "1")
+	assert.ErrorIs(t, err, utils.ErrCorruptJSON)
+
+	matches, _ := filepath.Glob(tmpFile + ".json.corrupt-*")
+	assert.Len(t, matches, 1)
+
+	t.Log("✅ Update refused and file quarantined")
+}
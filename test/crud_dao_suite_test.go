@@ -0,0 +1,1026 @@
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/* ===========================================================
+   Conformance suite: todo backend de dao.CrudDAO[T] tiene que
+   pasarla. Para sumar un backend nuevo alcanza con una línea:
+
+       RunCrudDAOSuite(t, func(t *testing.T) dao.CrudDAO[SuiteEntity] { ... })
+   =========================================================== */
+
+// SuiteEntity es la entidad que usa la suite. Computed no se persiste
+// (json:"-"), así se puede verificar que Init() corre también al leer.
+type SuiteEntity struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Price    float64  `json:"price"`
+	Tags     []string `json:"tags"`
+	Code     string   `json:"code,omitempty" unique:"nocase"`
+	Group    string   `json:"group,omitempty" index:"true"`
+	Computed string   `json:"-"`
+
+	models.Metadata
+}
+
+func (e *SuiteEntity) Init() {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+
+	e.Computed = fmt.Sprintf("%s:%.2f", e.Name, e.Price)
+}
+
+// CrudDAOFactory devuelve un backend vacío y aislado para cada subtest.
+type CrudDAOFactory func(t *testing.T) dao.CrudDAO[SuiteEntity]
+
+func RunCrudDAOSuite(t *testing.T, newDAO CrudDAOFactory) {
+	t.Run("Create_CallsInitAndPersists", func(t *testing.T) {
+		repo := newDAO(t)
+
+		created, err := repo.Create(&SuiteEntity{Name: "Mate", Price: 10})
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.ID, "Init() must generate the ID")
+		assert.Equal(t, "Mate:10.00", created.Computed)
+
+		found, err := repo.GetByID(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Mate", found.Name)
+	})
+
+	t.Run("Create_KeepsGivenID", func(t *testing.T) {
+		repo := newDAO(t)
+
+		created, err := repo.Create(&SuiteEntity{ID: "fixed", Name: "Termo"})
+		require.NoError(t, err)
+		assert.Equal(t, "fixed", created.ID)
+	})
+
+	t.Run("GetByID_CallsInit", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Yerba", Price: 2.5})
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Yerba:2.50", found.Computed)
+	})
+
+	t.Run("GetByID_NotFound", func(t *testing.T) {
+		repo := newDAO(t)
+
+		_, err := repo.GetByID("no-existe")
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+	})
+
+	t.Run("Create_DuplicateIDIsConflict", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Original"})
+
+		_, err := repo.Create(&SuiteEntity{ID: "1", Name: "Copia"})
+		assert.ErrorIs(t, err, dao.ErrConflict)
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Original", found.Name)
+	})
+
+	t.Run("GetAll_EmptyCollection", func(t *testing.T) {
+		repo := newDAO(t)
+
+		all, err := repo.GetAll("", 10, 0)
+		require.NoError(t, err)
+		assert.NotNil(t, all)
+		assert.Len(t, all, 0)
+	})
+
+	t.Run("GetAll_InsertionOrderAndInit", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 3)
+
+		all, err := repo.GetAll("", 10, 0)
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+
+		for i, item := range all {
+			assert.Equal(t, fmt.Sprintf("%d", i+1), item.ID)
+			assert.Equal(t, fmt.Sprintf("Item %d:%d.00", i+1, i+1), item.Computed)
+		}
+	})
+
+	t.Run("GetAll_QueryIsCaseInsensitiveOnName", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "ÁRBOL de Navidad"})
+		repo.Create(&SuiteEntity{ID: "2", Name: "Mesa"})
+		repo.Create(&SuiteEntity{ID: "3", Name: "Árbol frutal"})
+
+		all, err := repo.GetAll("árbol", 10, 0)
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, "1", all[0].ID)
+		assert.Equal(t, "3", all[1].ID)
+
+		none, err := repo.GetAll("silla", 10, 0)
+		require.NoError(t, err)
+		assert.Len(t, none, 0)
+	})
+
+	t.Run("GetAll_DefaultLimitIs10", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 15)
+
+		for _, limit := range []int{0, -1} {
+			all, err := repo.GetAll("", limit, 0)
+			require.NoError(t, err)
+			assert.Len(t, all, 10)
+		}
+	})
+
+	t.Run("GetAll_NegativeOffsetIsClamped", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 5)
+
+		all, err := repo.GetAll("", 2, -7)
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, "1", all[0].ID)
+	})
+
+	t.Run("GetAll_LimitAndOffset", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 12)
+
+		page, err := repo.GetAll("item", 3, 4)
+		require.NoError(t, err)
+		require.Len(t, page, 3)
+		assert.Equal(t, "5", page[0].ID)
+		assert.Equal(t, "7", page[2].ID)
+
+		last, err := repo.GetAll("", 10, 10)
+		require.NoError(t, err)
+		assert.Len(t, last, 2)
+
+		beyond, err := repo.GetAll("", 10, 50)
+		require.NoError(t, err)
+		assert.NotNil(t, beyond)
+		assert.Len(t, beyond, 0)
+	})
+
+	t.Run("Update_SkipsZeroValuesAndID", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Viejo", Price: 20, Tags: []string{"a"}})
+
+		updated, err := repo.Update(&SuiteEntity{ID: "otro", Name: "Nuevo"}, "1")
+		require.NoError(t, err)
+		assert.Equal(t, "1", updated.ID)
+		assert.Equal(t, "Nuevo", updated.Name)
+		assert.Equal(t, 20.0, updated.Price)
+		assert.Equal(t, []string{"a"}, updated.Tags)
+		assert.Equal(t, "Nuevo:20.00", updated.Computed)
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Nuevo", found.Name)
+		assert.Equal(t, 20.0, found.Price)
+
+		_, err = repo.GetByID("otro")
+		assert.Error(t, err)
+	})
+
+	t.Run("Update_NoChangesFails", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Igual", Price: 5})
+
+		_, err := repo.Update(&SuiteEntity{}, "1")
+		assert.ErrorIs(t, err, dao.ErrInvalidUpdate)
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Igual", found.Name)
+	})
+
+	t.Run("Update_NotFound", func(t *testing.T) {
+		repo := newDAO(t)
+
+		_, err := repo.Update(&SuiteEntity{Name: "X"}, "no-existe")
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+	})
+
+	t.Run("Modify_NotFoundAndRejected", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Igual"})
+
+		_, err := repo.Modify("no-existe", func(e *SuiteEntity) error { return nil })
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		_, err = repo.Modify("1", func(e *SuiteEntity) error {
+			e.Name = "Cambiado"
+			return errors.New("no se permite")
+		})
+		assert.ErrorIs(t, err, dao.ErrInvalidUpdate)
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Igual", found.Name)
+	})
+
+	t.Run("Version_StartsAtOneAndBumpsOnEveryChange", func(t *testing.T) {
+		repo := newDAO(t)
+
+		created, err := repo.Create(&SuiteEntity{ID: "1", Name: "Mate", Metadata: models.Metadata{Version: 9}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), created.Version, "client-sent versions are ignored on create")
+
+		updated, err := repo.Update(&SuiteEntity{Name: "Termo"}, "1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), updated.Version)
+
+		modified, err := repo.Modify("1", func(e *SuiteEntity) error {
+			e.Price = 3
+			e.Version = 50
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), modified.Version, "mutate can't pick the version")
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), found.Version)
+	})
+
+	t.Run("LastChange_FollowsEveryWrite", func(t *testing.T) {
+		repo := newDAO(t)
+
+		empty, err := repo.LastChange()
+		require.NoError(t, err)
+
+		created, err := repo.Create(&SuiteEntity{ID: "1", Name: "Mate"})
+		require.NoError(t, err)
+		assert.False(t, created.UpdatedAt.IsZero(), "create stamps UpdatedAt")
+
+		afterCreate, err := repo.LastChange()
+		require.NoError(t, err)
+		assert.NotEqual(t, empty.Token, afterCreate.Token)
+		assert.False(t, afterCreate.At.IsZero())
+
+		// Las lecturas no cambian el estado
+		_, _ = repo.GetAll("", 10, 0)
+		again, err := repo.LastChange()
+		require.NoError(t, err)
+		assert.Equal(t, afterCreate.Token, again.Token)
+
+		_, err = repo.Update(&SuiteEntity{Name: "Termo"}, "1")
+		require.NoError(t, err)
+		afterUpdate, err := repo.LastChange()
+		require.NoError(t, err)
+		assert.NotEqual(t, afterCreate.Token, afterUpdate.Token)
+
+		_, err = repo.Delete("1")
+		require.NoError(t, err)
+		afterDelete, err := repo.LastChange()
+		require.NoError(t, err)
+		assert.NotEqual(t, afterUpdate.Token, afterDelete.Token)
+	})
+
+	t.Run("LastChange_IgnoresWritesThatChangeNothing", func(t *testing.T) {
+		repo := newDAO(t)
+
+		_, err := repo.Create(&SuiteEntity{ID: "1", Name: "Mate", Code: "MATE-01"})
+		require.NoError(t, err)
+
+		before, err := repo.LastChange()
+		require.NoError(t, err)
+
+		// Un alta rechazada por unicidad
+		_, err = repo.Create(&SuiteEntity{ID: "2", Name: "Otro", Code: "mate-01"})
+		require.ErrorIs(t, err, dao.ErrConflict)
+
+		// Un Modify que falla y otro que deja todo igual
+		_, err = repo.Modify("1", func(e *SuiteEntity) error { return errors.New("no") })
+		require.Error(t, err)
+
+		same, err := repo.Modify("1", func(e *SuiteEntity) error {
+			e.Name = "Mate"
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), same.Version, "a no-op Modify keeps the version")
+
+		after, err := repo.LastChange()
+		require.NoError(t, err)
+		assert.Equal(t, before.Token, after.Token)
+
+		revisions, err := repo.Revisions("1")
+		require.NoError(t, err)
+		assert.Len(t, revisions, 1, "nothing was archived")
+
+		_, err = repo.Modify("1", func(e *SuiteEntity) error {
+			e.Name = "Termo"
+			return nil
+		})
+		require.NoError(t, err)
+
+		after, err = repo.LastChange()
+		require.NoError(t, err)
+		assert.NotEqual(t, before.Token, after.Token)
+	})
+
+	t.Run("As_StampsTimestampsAndActor", func(t *testing.T) {
+		repo := newDAO(t)
+
+		created, err := repo.As("backoffice").Create(&SuiteEntity{
+			ID:       "1",
+			Name:     "Mate",
+			Metadata: models.Metadata{CreatedBy: "forged", CreatedAt: time.Unix(0, 0)},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "backoffice", created.CreatedBy)
+		assert.Equal(t, "backoffice", created.UpdatedBy)
+		assert.False(t, created.CreatedAt.IsZero())
+		assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+
+		updated, err := repo.As("sync").Update(&SuiteEntity{Name: "Termo"}, "1")
+		require.NoError(t, err)
+		assert.Equal(t, "backoffice", updated.CreatedBy)
+		assert.Equal(t, "sync", updated.UpdatedBy)
+		assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+		assert.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+
+		modified, err := repo.Modify("1", func(e *SuiteEntity) error {
+			e.Price = 3
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "", modified.UpdatedBy, "writes without As have no actor")
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, "backoffice", found.CreatedBy)
+		assert.True(t, modified.UpdatedAt.Equal(found.UpdatedAt))
+	})
+
+	t.Run("List_UpdatedSince", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 3)
+
+		items, err := repo.List(dao.ListQuery{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, items, 3)
+		since := items[2].UpdatedAt
+
+		_, err = repo.Update(&SuiteEntity{Name: "Cambiado"}, items[0].ID)
+		require.NoError(t, err)
+
+		delta, err := repo.List(dao.ListQuery{UpdatedSince: since.Add(time.Nanosecond)})
+		require.NoError(t, err)
+		require.Len(t, delta, 1)
+		assert.Equal(t, items[0].ID, delta[0].ID)
+
+		// Inclusivo: lo escrito justo en `since` también vuelve
+		delta, err = repo.List(dao.ListQuery{UpdatedSince: since})
+		require.NoError(t, err)
+		assert.Len(t, delta, 2)
+
+		delta, err = repo.List(dao.ListQuery{Q: "nada", UpdatedSince: since})
+		require.NoError(t, err)
+		assert.Empty(t, delta)
+	})
+
+	t.Run("Update_StaleVersionIsRejected", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Original"})
+
+		// Dos clientes leyeron la versión 1: el primero gana, el segundo rebota.
+		_, err := repo.Update(&SuiteEntity{Name: "Primero", Metadata: models.Metadata{Version: 1}}, "1")
+		require.NoError(t, err)
+
+		_, err = repo.Update(&SuiteEntity{Name: "Segundo", Metadata: models.Metadata{Version: 1}}, "1")
+		assert.ErrorIs(t, err, dao.ErrVersionMismatch)
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Primero", found.Name)
+		assert.Equal(t, int64(2), found.Version)
+
+		// Con la versión vigente se aplica
+		_, err = repo.Update(&SuiteEntity{Name: "Segundo", Metadata: models.Metadata{Version: 2}}, "1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Delete_RemovesEntity", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 2)
+
+		ok, err := repo.Delete("1")
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		_, err = repo.GetByID("1")
+		assert.Error(t, err)
+
+		all, err := repo.GetAll("", 10, 0)
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, "2", all[0].ID)
+	})
+
+	t.Run("Delete_NotFound", func(t *testing.T) {
+		repo := newDAO(t)
+
+		ok, err := repo.Delete("no-existe")
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+		assert.False(t, ok)
+	})
+
+	t.Run("Delete_IsSoftAndHidesByDefault", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 2)
+
+		_, err := repo.As("backoffice").Delete("1")
+		require.NoError(t, err)
+
+		deleted, err := repo.Find("1", dao.IncludeDeleted)
+		require.NoError(t, err)
+		require.NotNil(t, deleted.DeletedAt)
+		assert.Equal(t, "backoffice", deleted.DeletedBy)
+		assert.Equal(t, int64(2), deleted.Version, "a delete is a write")
+
+		_, err = repo.Find("2", dao.OnlyDeleted)
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		all, err := repo.List(dao.ListQuery{Deleted: dao.IncludeDeleted})
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		trash, err := repo.List(dao.ListQuery{Deleted: dao.OnlyDeleted})
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		assert.Equal(t, "1", trash[0].ID)
+
+		_, err = repo.Update(&SuiteEntity{Name: "Zombie"}, "1")
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		_, err = repo.Modify("1", func(e *SuiteEntity) error { return nil })
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		_, err = repo.Delete("1")
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		_, err = repo.Create(&SuiteEntity{ID: "1", Name: "Otra"})
+		assert.ErrorIs(t, err, dao.ErrConflict, "the ID stays taken while in the trash")
+	})
+
+	t.Run("Restore_BringsEntityBack", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 1)
+
+		_, err := repo.Restore("1")
+		assert.ErrorIs(t, err, dao.ErrConflict, "not deleted")
+
+		_, err = repo.Restore("no-existe")
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		_, err = repo.Delete("1")
+		require.NoError(t, err)
+
+		restored, err := repo.As("sync").Restore("1")
+		require.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Empty(t, restored.DeletedBy)
+		assert.Equal(t, "sync", restored.UpdatedBy)
+		assert.Equal(t, int64(3), restored.Version)
+		assert.NotEmpty(t, restored.Computed, "Init runs on the restored entity")
+
+		found, err := repo.GetByID("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Item 1", found.Name)
+	})
+
+	t.Run("Purge_RemovesOldTombstonesOnly", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 3)
+
+		n, err := repo.Purge(time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		_, err = repo.Delete("1")
+		require.NoError(t, err)
+		_, err = repo.Delete("2")
+		require.NoError(t, err)
+
+		before, err := repo.LastChange()
+		require.NoError(t, err)
+
+		n, err = repo.Purge(time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, n, "tombstones newer than the cutoff stay")
+
+		after, err := repo.LastChange()
+		require.NoError(t, err)
+		assert.Equal(t, before.Token, after.Token, "an empty purge doesn't write")
+
+		n, err = repo.Purge(time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		_, err = repo.Find("1", dao.IncludeDeleted)
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		all, err := repo.List(dao.ListQuery{Deleted: dao.IncludeDeleted})
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, "3", all[0].ID)
+
+		// Purgado, el ID vuelve a estar libre
+		_, err = repo.Create(&SuiteEntity{ID: "1", Name: "Nuevo"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Revisions_RecordEveryWrite", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Mate", Price: 10})
+
+		_, err := repo.As("sync").Update(&SuiteEntity{Price: 12}, "1")
+		require.NoError(t, err)
+		_, err = repo.Modify("1", func(e *SuiteEntity) error {
+			e.Tags = []string{"oferta"}
+			return nil
+		})
+		require.NoError(t, err)
+		_, err = repo.Delete("1")
+		require.NoError(t, err)
+		_, err = repo.Restore("1")
+		require.NoError(t, err)
+
+		// Una escritura rechazada no deja versión archivada
+		_, err = repo.Modify("1", func(e *SuiteEntity) error { return errors.New("no") })
+		require.Error(t, err)
+
+		revisions, err := repo.Revisions("1")
+		require.NoError(t, err)
+		require.Len(t, revisions, 5)
+
+		for i, rev := range revisions {
+			assert.Equal(t, int64(i+1), rev.Rev)
+			assert.Equal(t, rev.Rev, rev.Entity.Version)
+		}
+
+		assert.Equal(t, 10.0, revisions[0].Entity.Price)
+		assert.Equal(t, "Mate:10.00", revisions[0].Entity.Computed, "Init runs on archived versions")
+		assert.Equal(t, 12.0, revisions[1].Entity.Price)
+		assert.Equal(t, "sync", revisions[1].UpdatedBy)
+		assert.Equal(t, []string{"oferta"}, revisions[2].Entity.Tags)
+		assert.True(t, revisions[3].Deleted)
+		assert.False(t, revisions[4].Deleted)
+
+		rev, err := repo.Revision("1", 2)
+		require.NoError(t, err)
+		assert.Equal(t, 12.0, rev.Entity.Price)
+
+		_, err = repo.Revision("1", 9)
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		_, err = repo.Revisions("no-existe")
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+	})
+
+	t.Run("Revert_SavesOldContentAsNewRevision", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Mate", Price: 10})
+		repo.Update(&SuiteEntity{Name: "Termo", Price: 99}, "1")
+
+		_, err := repo.Revert("1", 1, 1)
+		assert.ErrorIs(t, err, dao.ErrVersionMismatch)
+
+		_, err = repo.Revert("1", 2, 0)
+		assert.ErrorIs(t, err, dao.ErrInvalidUpdate, "already at that revision")
+
+		_, err = repo.Revert("1", 7, 0)
+		assert.ErrorIs(t, err, dao.ErrNotFound)
+
+		reverted, err := repo.As("backoffice").Revert("1", 1, 2)
+		require.NoError(t, err)
+		assert.Equal(t, "Mate", reverted.Name)
+		assert.Equal(t, 10.0, reverted.Price)
+		assert.Equal(t, int64(3), reverted.Version)
+		assert.Equal(t, "backoffice", reverted.UpdatedBy)
+		assert.Equal(t, "Mate:10.00", reverted.Computed)
+
+		revisions, err := repo.Revisions("1")
+		require.NoError(t, err)
+		require.Len(t, revisions, 3)
+		assert.Equal(t, 99.0, revisions[1].Entity.Price, "the reverted-over version stays in history")
+
+		_, err = repo.Delete("1")
+		require.NoError(t, err)
+		_, err = repo.Revert("1", 2, 0)
+		assert.ErrorIs(t, err, dao.ErrNotFound, "deleted entities must be restored first")
+	})
+
+	t.Run("Revisions_StartOverAfterPurge", func(t *testing.T) {
+		repo := newDAO(t)
+		repo.Create(&SuiteEntity{ID: "1", Name: "Viejo"})
+		repo.Update(&SuiteEntity{Name: "Viejo 2"}, "1")
+		repo.Delete("1")
+
+		_, err := repo.Purge(time.Now().Add(time.Second))
+		require.NoError(t, err)
+
+		// El reloj puede no avanzar entre el purge y el alta
+		time.Sleep(time.Millisecond)
+
+		_, err = repo.Create(&SuiteEntity{ID: "1", Name: "Nuevo"})
+		require.NoError(t, err)
+
+		revisions, err := repo.Revisions("1")
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		assert.Equal(t, "Nuevo", revisions[0].Entity.Name)
+	})
+
+	t.Run("Unique_RejectsDuplicatesIgnoringCase", func(t *testing.T) {
+		repo := newDAO(t)
+
+		_, err := repo.Create(&SuiteEntity{ID: "1", Name: "Mate", Code: "MATE-01"})
+		require.NoError(t, err)
+		_, err = repo.Create(&SuiteEntity{ID: "2", Name: "Termo", Code: "TERMO-01"})
+		require.NoError(t, err)
+
+		var violation *dao.UniqueViolation
+
+		_, err = repo.Create(&SuiteEntity{ID: "3", Name: "Otro mate", Code: "mate-01"})
+		assert.ErrorIs(t, err, dao.ErrConflict)
+		require.ErrorAs(t, err, &violation)
+		assert.Equal(t, "1", violation.ID)
+		assert.Equal(t, "code", violation.Field)
+
+		_, err = repo.Update(&SuiteEntity{Code: "Mate-01"}, "2")
+		require.ErrorAs(t, err, &violation)
+		assert.Equal(t, "1", violation.ID)
+
+		_, err = repo.Modify("2", func(e *SuiteEntity) error {
+			e.Code = "mate-01"
+			return nil
+		})
+		assert.ErrorIs(t, err, dao.ErrConflict)
+
+		// Cambiar sólo mayúsculas del propio valor no choca consigo mismo.
+		_, err = repo.Update(&SuiteEntity{Code: "Mate-01"}, "1")
+		assert.NoError(t, err)
+
+		// Las entidades en la papelera no cuentan, pero no se pueden
+		// restaurar si otra tomó el valor.
+		_, err = repo.Delete("1")
+		require.NoError(t, err)
+		_, err = repo.Create(&SuiteEntity{ID: "3", Name: "Otro mate", Code: "mate-01"})
+		require.NoError(t, err)
+
+		_, err = repo.Restore("1")
+		require.ErrorAs(t, err, &violation)
+		assert.Equal(t, "3", violation.ID)
+
+		// Los valores vacíos no se comparan.
+		_, err = repo.Create(&SuiteEntity{ID: "4", Name: "Sin código"})
+		require.NoError(t, err)
+		_, err = repo.Create(&SuiteEntity{ID: "5", Name: "Tampoco"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Equals_UsesIndexAndFollowsWrites", func(t *testing.T) {
+		repo := newDAO(t)
+
+		for i, group := range []string{"a", "b", "a", "a", "b"} {
+			_, err := repo.Create(&SuiteEntity{ID: fmt.Sprintf("%d", i+1), Name: fmt.Sprintf("Item %d", i+1), Group: group})
+			require.NoError(t, err)
+		}
+
+		ids := func(query dao.ListQuery) []string {
+			t.Helper()
+
+			items, err := repo.List(query)
+			require.NoError(t, err)
+
+			var out []string
+			for _, item := range items {
+				out = append(out, item.ID)
+			}
+			return out
+		}
+
+		assert.Equal(t, []string{"1", "3", "4"}, ids(dao.ListQuery{Equals: map[string]string{"group": "a"}}))
+		assert.Equal(t, []string{"3", "4"}, ids(dao.ListQuery{Equals: map[string]string{"group": "a"}, Offset: 1}))
+		assert.Equal(t, []string{"4"}, ids(dao.ListQuery{Equals: map[string]string{"group": "a"}, Q: "item 4"}))
+
+		// Campos sin índice también filtran; los desconocidos no coinciden.
+		assert.Equal(t, []string{"2"}, ids(dao.ListQuery{Equals: map[string]string{"name": "Item 2"}}))
+		assert.Empty(t, ids(dao.ListQuery{Equals: map[string]string{"nope": "a"}}))
+
+		// El índice sigue a las escrituras.
+		_, err := repo.Update(&SuiteEntity{Group: "b"}, "1")
+		require.NoError(t, err)
+		_, err = repo.Delete("3")
+		require.NoError(t, err)
+		_, err = repo.Create(&SuiteEntity{ID: "6", Name: "Item 6", Group: "a"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"4", "6"}, ids(dao.ListQuery{Equals: map[string]string{"group": "a"}}))
+		assert.Equal(t, []string{"1", "2", "5"}, ids(dao.ListQuery{Equals: map[string]string{"group": "b"}}))
+	})
+
+	t.Run("Equals_IndexFollowsPurgeAndNewValues", func(t *testing.T) {
+		repo := newDAO(t)
+
+		for i, group := range []string{"a", "b", "a", "b", "a", "b"} {
+			_, err := repo.Create(&SuiteEntity{ID: fmt.Sprintf("%d", i+1), Name: fmt.Sprintf("Item %d", i+1), Group: group})
+			require.NoError(t, err)
+		}
+
+		ids := func(group string) []string {
+			t.Helper()
+
+			items, err := repo.List(dao.ListQuery{Equals: map[string]string{"group": group}})
+			require.NoError(t, err)
+
+			var out []string
+			for _, item := range items {
+				out = append(out, item.ID)
+			}
+			return out
+		}
+
+		// La primera consulta arma el índice; lo que sigue lo actualiza.
+		require.Equal(t, []string{"1", "3", "5"}, ids("a"))
+
+		// Purge saca entidades del medio: las posiciones de las que siguen
+		// se corren.
+		_, err := repo.Delete("1")
+		require.NoError(t, err)
+		_, err = repo.Delete("4")
+		require.NoError(t, err)
+		n, err := repo.Purge(time.Now().Add(time.Second))
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		assert.Equal(t, []string{"3", "5"}, ids("a"))
+		assert.Equal(t, []string{"2", "6"}, ids("b"))
+
+		// Un valor que no existía, un cambio con Modify y una entidad nueva.
+		_, err = repo.Update(&SuiteEntity{Group: "c"}, "5")
+		require.NoError(t, err)
+		_, err = repo.Modify("2", func(entity *SuiteEntity) error {
+			entity.Group = "a"
+			return nil
+		})
+		require.NoError(t, err)
+		_, err = repo.Create(&SuiteEntity{ID: "7", Name: "Item 7", Group: "c"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"2", "3"}, ids("a"))
+		assert.Equal(t, []string{"6"}, ids("b"))
+		assert.Equal(t, []string{"5", "7"}, ids("c"))
+	})
+
+	t.Run("Filters_InTakesAListOfValues", func(t *testing.T) {
+		repo := newDAO(t)
+
+		for _, id := range []string{"a", "b", "a,b", "c"} {
+			_, err := repo.Create(&SuiteEntity{ID: id, Name: "Item " + id})
+			require.NoError(t, err)
+		}
+
+		ids := func(values []string) []string {
+			t.Helper()
+
+			items, err := repo.List(dao.ListQuery{Filters: []dao.Filter{{Field: "id", Op: dao.OpIn, Values: values}}, Limit: 10})
+			require.NoError(t, err)
+
+			out := []string{}
+			for _, item := range items {
+				out = append(out, item.ID)
+			}
+			return out
+		}
+
+		// Values no se separa por comas: un ID puede tenerlas.
+		assert.Equal(t, []string{"a,b"}, ids([]string{"a,b"}))
+		assert.Equal(t, []string{"a", "c"}, ids([]string{"c", "a"}))
+		assert.Empty(t, ids([]string{}))
+
+		// Más valores que las variables que acepta SQLite en una consulta.
+		many := []string{"b"}
+		for i := range 40000 {
+			many = append(many, fmt.Sprintf("missing-%d", i))
+		}
+		assert.Equal(t, []string{"b", "a,b"}, ids(append(many, "a,b")))
+
+		// Value sigue separando por comas.
+		items, err := repo.List(dao.ListQuery{Filters: []dao.Filter{{Field: "id", Op: dao.OpIn, Value: "a,b"}}})
+		require.NoError(t, err)
+		assert.Len(t, items, 2)
+
+		_, err = repo.List(dao.ListQuery{Filters: []dao.Filter{{Field: "id", Op: dao.OpEq, Values: []string{"a"}}}})
+		assert.ErrorIs(t, err, dao.ErrInvalidQuery)
+	})
+
+	t.Run("Filters_CompareAndSort", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 6)
+
+		_, err := repo.Update(&SuiteEntity{Group: "b"}, "2")
+		require.NoError(t, err)
+		_, err = repo.Update(&SuiteEntity{Group: "a"}, "5")
+		require.NoError(t, err)
+
+		ids := func(query dao.ListQuery) []string {
+			t.Helper()
+
+			items, err := repo.List(query)
+			require.NoError(t, err)
+
+			out := []string{}
+			for _, item := range items {
+				out = append(out, item.ID)
+			}
+			return out
+		}
+
+		filters := func(filters ...dao.Filter) dao.ListQuery {
+			return dao.ListQuery{Filters: filters}
+		}
+
+		assert.Equal(t, []string{"2", "3", "4"}, ids(filters(
+			dao.Filter{Field: "price", Op: dao.OpGt, Value: "1"},
+			dao.Filter{Field: "price", Op: dao.OpLte, Value: "4"},
+		)))
+		assert.Equal(t, []string{"1", "6"}, ids(filters(dao.Filter{Field: "price", Op: dao.OpIn, Value: "1,6,9"})))
+		assert.Equal(t, []string{"4"}, ids(filters(dao.Filter{Field: "name", Op: dao.OpContains, Value: "ITEM 4"})))
+		assert.Equal(t, []string{"2", "5"}, ids(filters(dao.Filter{Field: "version", Op: dao.OpEq, Value: "2"})))
+
+		// Un string que falta en el JSON (omitempty) vale "".
+		assert.Equal(t, []string{"1", "3", "4", "6"}, ids(filters(dao.Filter{Field: "group", Op: dao.OpEq, Value: ""})))
+
+		// Orden: grupo desc, precio desc entre empates; paginado después.
+		sorted := dao.ListQuery{Sort: []dao.SortKey{{Field: "group", Desc: true}, {Field: "price", Desc: true}}}
+		assert.Equal(t, []string{"2", "5", "6", "4", "3", "1"}, ids(sorted))
+		sorted.Offset, sorted.Limit = 1, 2
+		assert.Equal(t, []string{"5", "6"}, ids(sorted))
+
+		// Filtro por fecha y orden por fecha (sqlite lo resuelve en Go).
+		updated, err := repo.GetByID("5")
+		require.NoError(t, err)
+		since := dao.Filter{Field: "updated_at", Op: dao.OpGte, Value: updated.UpdatedAt.Format(time.RFC3339Nano)}
+		assert.Equal(t, []string{"5"}, ids(filters(since)))
+		assert.Equal(t, []string{"5", "2", "6"}, ids(dao.ListQuery{Sort: []dao.SortKey{{Field: "updated_at", Desc: true}}, Limit: 3}))
+
+		for _, query := range []dao.ListQuery{
+			filters(dao.Filter{Field: "nope", Op: dao.OpEq, Value: "1"}),
+			filters(dao.Filter{Field: "price", Op: "like", Value: "1"}),
+			filters(dao.Filter{Field: "price", Op: dao.OpGt, Value: "caro"}),
+			filters(dao.Filter{Field: "price", Op: dao.OpContains, Value: "1"}),
+			filters(dao.Filter{Field: "tags", Op: dao.OpEq, Value: "a"}),
+			{Sort: []dao.SortKey{{Field: "nope"}}},
+		} {
+			_, err := repo.List(query)
+			assert.ErrorIs(t, err, dao.ErrInvalidQuery, query)
+		}
+	})
+
+	t.Run("Page_CursorsSurviveWritesInBetween", func(t *testing.T) {
+		repo := newDAO(t)
+		seedSuite(t, repo, 5)
+
+		ids := func(page dao.Page[SuiteEntity]) []string {
+			out := []string{}
+			for _, item := range page.Items {
+				out = append(out, item.ID)
+			}
+			return out
+		}
+
+		first, err := repo.Page(dao.ListQuery{Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1", "2"}, ids(first))
+		assert.Equal(t, 5, first.Total)
+		assert.Empty(t, first.PrevCursor)
+		require.NotEmpty(t, first.NextCursor)
+
+		// Una baja y un alta antes del borde no corren la página siguiente.
+		_, err = repo.Delete("1")
+		require.NoError(t, err)
+		_, err = repo.Create(&SuiteEntity{ID: "6", Name: "Item 6", Price: 6})
+		require.NoError(t, err)
+
+		second, err := repo.Page(dao.ListQuery{Limit: 2, Cursor: first.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"3", "4"}, ids(second))
+		assert.Equal(t, 5, second.Total)
+
+		third, err := repo.Page(dao.ListQuery{Limit: 2, Cursor: second.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"5", "6"}, ids(third))
+		assert.Empty(t, third.NextCursor)
+
+		back, err := repo.Page(dao.ListQuery{Limit: 2, Cursor: third.PrevCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"3", "4"}, ids(back))
+
+		// Si el borde desaparece, la página sigue desde donde estaba.
+		_, err = repo.Delete("4")
+		require.NoError(t, err)
+
+		after, err := repo.Page(dao.ListQuery{Limit: 2, Cursor: second.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"5", "6"}, ids(after))
+
+		// Con orden, el cursor sigue el orden y no sirve para otro.
+		sorted := dao.ListQuery{Limit: 2, Sort: []dao.SortKey{{Field: "price", Desc: true}}}
+		page, err := repo.Page(sorted)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"6", "5"}, ids(page))
+
+		sorted.Cursor = page.NextCursor
+		page, err = repo.Page(sorted)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"3", "2"}, ids(page))
+
+		_, err = repo.Page(dao.ListQuery{Cursor: page.PrevCursor})
+		assert.ErrorIs(t, err, dao.ErrInvalidQuery)
+		_, err = repo.Page(dao.ListQuery{Cursor: "no-es-un-cursor"})
+		assert.ErrorIs(t, err, dao.ErrInvalidQuery)
+
+		// Con offset, la página también trae total y cursores.
+		page, err = repo.Page(dao.ListQuery{Limit: 2, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"3", "5"}, ids(page))
+		assert.Equal(t, 4, page.Total)
+		assert.NotEmpty(t, page.PrevCursor)
+		assert.NotEmpty(t, page.NextCursor)
+	})
+}
+
+// seedSuite crea n entidades con IDs "1".."n", nombres "Item i" y precio i.
+func seedSuite(t *testing.T, repo dao.CrudDAO[SuiteEntity], n int) {
+	t.Helper()
+
+	for i := 1; i <= n; i++ {
+		_, err := repo.Create(&SuiteEntity{
+			ID:    fmt.Sprintf("%d", i),
+			Name:  fmt.Sprintf("Item %d", i),
+			Price: float64(i),
+		})
+		require.NoError(t, err)
+	}
+}
+
+/* ===========================================================
+   Backends
+   =========================================================== */
+
+func TestCrudDAOSuite_JSON(t *testing.T) {
+	RunCrudDAOSuite(t, func(t *testing.T) dao.CrudDAO[SuiteEntity] {
+		return &dal.CrudDAL[SuiteEntity]{Filename: filepath.Join(t.TempDir(), "suite")}
+	})
+}
+
+func TestCrudDAOSuite_Memory(t *testing.T) {
+	RunCrudDAOSuite(t, func(t *testing.T) dao.CrudDAO[SuiteEntity] {
+		return dal.NewMemoryDAL[SuiteEntity]()
+	})
+}
+
+func TestCrudDAOSuite_SQLite(t *testing.T) {
+	RunCrudDAOSuite(t, func(t *testing.T) dao.CrudDAO[SuiteEntity] {
+		db, err := dal.OpenSQLite(filepath.Join(t.TempDir(), "suite.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		repo, err := dal.NewSQLiteDAL[SuiteEntity](db, "suite")
+		require.NoError(t, err)
+
+		return repo
+	})
+}
+
+func TestCrudDAOSuite_Journal(t *testing.T) {
+	RunCrudDAOSuite(t, func(t *testing.T) dao.CrudDAO[SuiteEntity] {
+		repo, err := dal.OpenJournalDAL[SuiteEntity](filepath.Join(t.TempDir(), "suite"), dal.JournalOptions{CompactEvery: 3})
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+
+		return repo
+	})
+}
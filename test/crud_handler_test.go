@@ -1,11 +1,12 @@
 package main_test
 
 import (
-	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"project/internal/item_detail/repo/datasource/dao"
 	"project/internal/item_detail/rest"
@@ -48,27 +49,79 @@ func (m *MockCrudDAO) GetAll(q string, limit int, offset int) ([]*MockEntityHand
 func (m *MockCrudDAO) GetByID(id string) (*MockEntityHandler, error) {
 	v, ok := m.Data[id]
 	if !ok {
-		return nil, errors.New("not found")
+		return nil, dao.Errorf(dao.ErrNotFound, "not found")
 	}
 	return v, nil
 }
 
 func (m *MockCrudDAO) Update(e *MockEntityHandler, id string) (*MockEntityHandler, error) {
 	if _, ok := m.Data[id]; !ok {
-		return nil, errors.New("not found")
+		return nil, dao.Errorf(dao.ErrNotFound, "not found")
 	}
 	m.Data[id] = e
 	return e, nil
 }
 
+func (m *MockCrudDAO) Modify(id string, mutate func(e *MockEntityHandler) error) (*MockEntityHandler, error) {
+	v, ok := m.Data[id]
+	if !ok {
+		return nil, dao.Errorf(dao.ErrNotFound, "not found")
+	}
+	if err := mutate(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
 func (m *MockCrudDAO) Delete(id string) (bool, error) {
 	if _, ok := m.Data[id]; !ok {
-		return false, errors.New("not found")
+		return false, dao.Errorf(dao.ErrNotFound, "not found")
 	}
 	delete(m.Data, id)
 	return true, nil
 }
 
+func (m *MockCrudDAO) Find(id string, deleted dao.DeletedFilter) (*MockEntityHandler, error) {
+	return m.GetByID(id)
+}
+
+func (m *MockCrudDAO) Restore(id string) (*MockEntityHandler, error) {
+	return nil, dao.Errorf(dao.ErrNotFound, "not found")
+}
+
+func (m *MockCrudDAO) Purge(before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *MockCrudDAO) Revisions(id string) ([]dao.Revision[MockEntityHandler], error) {
+	return nil, dao.Errorf(dao.ErrNotFound, "not found")
+}
+
+func (m *MockCrudDAO) Revision(id string, rev int64) (*dao.Revision[MockEntityHandler], error) {
+	return nil, dao.Errorf(dao.ErrNotFound, "not found")
+}
+
+func (m *MockCrudDAO) Revert(id string, rev int64, expected int64) (*MockEntityHandler, error) {
+	return nil, dao.Errorf(dao.ErrNotFound, "not found")
+}
+
+func (m *MockCrudDAO) List(query dao.ListQuery) ([]*MockEntityHandler, error) {
+	return m.GetAll(query.Q, query.Limit, query.Offset)
+}
+
+func (m *MockCrudDAO) Page(query dao.ListQuery) (dao.Page[MockEntityHandler], error) {
+	items, err := m.List(query)
+	return dao.Page[MockEntityHandler]{Items: items, Total: len(m.Data)}, err
+}
+
+func (m *MockCrudDAO) As(actor string) dao.CrudDAO[MockEntityHandler] {
+	return m
+}
+
+func (m *MockCrudDAO) LastChange() (dao.Change, error) {
+	return dao.Change{Token: strconv.Itoa(len(m.Data))}, nil
+}
+
 // Para cumplir la interfaz CrudDAO[T]
 var _ dao.CrudDAO[MockEntityHandler] = (*MockCrudDAO)(nil)
 
@@ -0,0 +1,71 @@
+package main_test
+
+import (
+	"net/http"
+	"testing"
+
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETag_PatchHonoursIfMatch(t *testing.T) {
+	t.Log("🔍 TEST: GET exposes the version as ETag and PATCH honours If-Match")
+
+	e, st := newTestServer(t)
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"})
+
+	rec := doRequest(e, http.MethodGet, "/api/v1/categories/c1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/c1", `{"name":"Termos"}`, "If-Match", `"1"`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	// Otro cliente que todavía tiene la versión 1
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/c1", `{"name":"Bombillas"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/c1", `{"name":"Bombillas"}`, "If-Match", `W/"2"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	c, err := st.Categories.GetByID("c1")
+	require.NoError(t, err)
+	assert.Equal(t, "Termos", c.Name)
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/c1", `{"name":"Bombillas"}`, "If-Match", "*")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/c1", `{"name":"Yerbas"}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+
+	// La versión del body no es una precondición: sólo cuenta If-Match
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/c1", `{"name":"Mates","version":1}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Equal(t, `"5"`, rec.Header().Get("ETag"))
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/c1", `{"name":"Termos","version":5}`, "If-Match", `"4"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	t.Log("✅ Stale writers get 412, fresh ones go through")
+}
+
+func TestETag_ProductAttributeChangeHonoursIfMatch(t *testing.T) {
+	t.Log("🔍 TEST: PATCH /products/:id/category also checks If-Match")
+
+	e, st := newTestServer(t)
+	seed(t, st.Products, createTestProduct())
+	seed(t, st.Categories, models.Category{ID: "cat-2", Name: "Termos"})
+
+	rec := doRequest(e, http.MethodPatch, "/api/v1/products/1/category", `{"id":"cat-2"}`, "If-Match", `"7"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/products/1/category", `{"id":"cat-2"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	t.Log("✅ Attribute changes use the same precondition")
+}
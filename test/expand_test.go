@@ -0,0 +1,247 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/service"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingDAO cuenta las lecturas de entidades que llegan al DAO.
+type countingDAO[T any] struct {
+	dao.CrudDAO[T]
+	reads int
+}
+
+func (d *countingDAO[T]) GetByID(id string) (*T, error) {
+	d.reads++
+	return d.CrudDAO.GetByID(id)
+}
+
+func (d *countingDAO[T]) List(query dao.ListQuery) ([]*T, error) {
+	d.reads++
+	return d.CrudDAO.List(query)
+}
+
+type expandedJSON struct {
+	ID       string `json:"id"`
+	Category struct {
+		Href   string           `json:"href"`
+		Entity *models.Category `json:"entity"`
+	} `json:"category"`
+	Seller struct {
+		Href   string         `json:"href"`
+		Entity *models.Seller `json:"entity"`
+	} `json:"seller"`
+	ImageLinks []struct {
+		Href   string        `json:"href"`
+		Entity *models.Image `json:"entity"`
+	} `json:"image_links"`
+}
+
+func TestExpand_InlinesRelatedEntitiesNextToTheLinks(t *testing.T) {
+	t.Log("🔍 TEST: ?expand= inlines category, seller and images in GET /products/:id and the list")
+
+	e, _ := newTestServer(t)
+	seedCatalog(t, e)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/images", `{"id": "img-2", "name": "Dorso", "url": "https://cdn.example.com/2.png"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products", productBody("p1", "cat-1", "sel-1", "img-1", "img-2"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = doRequest(e, http.MethodPost, "/api/v1/products", productBody("p2", "cat-1", "sel-1"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/p1?expand=category,seller,images", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var product expandedJSON
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+
+	assert.Equal(t, "p1", product.ID)
+	assert.Contains(t, product.Category.Href, "/categories/cat-1")
+	require.NotNil(t, product.Category.Entity)
+	assert.Equal(t, "Mates", product.Category.Entity.Name)
+	require.NotNil(t, product.Seller.Entity)
+	assert.Equal(t, "Don Mate", product.Seller.Entity.Name)
+	require.Len(t, product.ImageLinks, 2)
+	assert.Contains(t, product.ImageLinks[1].Href, "/images/img-2")
+	require.NotNil(t, product.ImageLinks[1].Entity)
+	assert.Equal(t, "https://cdn.example.com/2.png", product.ImageLinks[1].Entity.URL)
+
+	// El resto del producto sigue igual.
+	assert.Contains(t, rec.Body.String(), `"installmentPrice"`)
+	assert.Contains(t, rec.Body.String(), `"version":1`)
+
+	// Sólo se expande lo pedido; sin expand la respuesta es la de siempre.
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/p1?expand=seller", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var sellerOnly expandedJSON
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sellerOnly))
+	assert.Nil(t, sellerOnly.Category.Entity)
+	assert.NotNil(t, sellerOnly.Seller.Entity)
+	assert.Nil(t, sellerOnly.ImageLinks[0].Entity)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/p1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"entity"`)
+
+	// En el listado, cada item viene expandido.
+	rec = doRequest(e, http.MethodGet, "/api/v1/products?expand=category,images&sort=id", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var page struct {
+		Items []expandedJSON `json:"items"`
+		Total int            `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, "Mates", page.Items[1].Category.Entity.Name)
+	assert.Len(t, page.Items[0].ImageLinks, 2)
+	assert.Empty(t, page.Items[1].ImageLinks)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/cat-1/products?expand=seller", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"Don Mate"`)
+
+	for _, path := range []string{"/api/v1/products/p1?expand=owner", "/api/v1/products?expand=category,,seller"} {
+		rec = doRequest(e, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), `"error"`)
+	}
+
+	t.Log("✅ Related entities were inlined next to their links")
+}
+
+func TestExpand_ValidatorsFollowTheRelatedEntities(t *testing.T) {
+	t.Log("🔍 TEST: an expanded response gets a new ETag when a related entity changes")
+
+	e, _ := newTestServer(t)
+	seedCatalog(t, e)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/products", productBody("p1", "cat-1", "sel-1", "img-1"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	plain := rec.Header().Get("ETag")
+
+	for i, path := range []string{"/api/v1/products/p1?expand=category", "/api/v1/products?expand=category"} {
+		rec = doRequest(e, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		etag := rec.Header().Get("ETag")
+		assert.NotEqual(t, plain, etag)
+
+		rec = doRequest(e, http.MethodGet, path, "", "If-None-Match", etag)
+		assert.Equal(t, http.StatusNotModified, rec.Code, path)
+
+		rec = doRequest(e, http.MethodPatch, "/api/v1/categories/cat-1", fmt.Sprintf(`{"name": "Mates %d"}`, i))
+		require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+		rec = doRequest(e, http.MethodGet, path, "", "If-None-Match", etag)
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), fmt.Sprintf(`"name":"Mates %d"`, i))
+	}
+
+	// La representación sin expand sigue validando con el ETag de la versión.
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/p1", "", "If-None-Match", plain)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	t.Log("✅ Expanded responses were revalidated against the related entities")
+}
+
+func TestExpand_BatchesLookups(t *testing.T) {
+	t.Log("🔍 TEST: expanding 50 products reads each related collection once")
+
+	categories := &countingDAO[models.Category]{CrudDAO: dal.NewMemoryDAL[models.Category]()}
+	sellers := &countingDAO[models.Seller]{CrudDAO: dal.NewMemoryDAL[models.Seller]()}
+	images := &countingDAO[models.Image]{CrudDAO: dal.NewMemoryDAL[models.Image]()}
+
+	for i := range 5 {
+		_, err := categories.Create(&models.Category{ID: fmt.Sprintf("cat-%d", i), Name: fmt.Sprintf("Categoría %d", i)})
+		require.NoError(t, err)
+		_, err = sellers.Create(&models.Seller{ID: fmt.Sprintf("sel-%d", i), Name: "Vendedor", Address: "Calle 1"})
+		require.NoError(t, err)
+	}
+
+	var products []*models.Product
+
+	for i := range 50 {
+		image := fmt.Sprintf("img-%d", i)
+		_, err := images.Create(&models.Image{ID: image, Name: "Foto", URL: "https://cdn.example.com/" + image})
+		require.NoError(t, err)
+
+		product := &models.Product{
+			ID:         fmt.Sprintf("p%d", i),
+			CategoryId: fmt.Sprintf("cat-%d", i%5),
+			SellerId:   fmt.Sprintf("sel-%d", i%7), // sel-5 y sel-6 no existen
+			Images:     []string{image, "img-0"},
+		}
+		product.Init()
+		products = append(products, product)
+	}
+
+	categories.reads, sellers.reads, images.reads = 0, 0, 0
+
+	expander := service.NewProductExpander(categories, sellers, images)
+	expanded, err := expander.Expand(products, []string{service.ExpandCategory, service.ExpandSeller, service.ExpandImages})
+	require.NoError(t, err)
+	require.Len(t, expanded, 50)
+
+	assert.Equal(t, 1, categories.reads)
+	assert.Equal(t, 1, sellers.reads)
+	assert.Equal(t, 1, images.reads)
+
+	for i, item := range expanded {
+		product := item.(*service.ExpandedProduct)
+
+		assert.Equal(t, fmt.Sprintf("p%d", i), product.ID)
+		assert.Equal(t, fmt.Sprintf("Categoría %d", i%5), product.Category.Entity.Name)
+		assert.Equal(t, fmt.Sprintf("img-%d", i), product.ImageLinks[0].Entity.ID)
+		assert.Equal(t, "img-0", product.ImageLinks[1].Entity.ID)
+
+		if i%7 >= 5 {
+			assert.Nil(t, product.Seller.Entity, "missing seller stays a link")
+		} else {
+			assert.NotNil(t, product.Seller.Entity)
+		}
+	}
+
+	// Sin relaciones pedidas no se lee nada.
+	_, err = expander.Expand(products, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, categories.reads+sellers.reads+images.reads)
+
+	t.Log("✅ Each related collection was read once")
+}
+
+func TestExpand_IDsWithCommas(t *testing.T) {
+	t.Log("🔍 TEST: ?expand= finds related entities whose IDs have commas")
+
+	e, _ := newTestServer(t)
+	seedCatalog(t, e)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/images", `{"id": "img-1,img-9", "name": "Frente", "url": "https://cdn.example.com/9.png"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = doRequest(e, http.MethodPost, "/api/v1/products", productBody("p1", "cat-1", "sel-1", "img-1,img-9"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/p1?expand=images", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var product expandedJSON
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+	require.Len(t, product.ImageLinks, 1)
+	require.NotNil(t, product.ImageLinks[0].Entity)
+	assert.Equal(t, "https://cdn.example.com/9.png", product.ImageLinks[0].Entity.URL)
+
+	t.Log("✅ The image was expanded by its full ID")
+}
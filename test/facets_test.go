@@ -0,0 +1,287 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"project/internal/item_detail/service"
+	models "project/pkg"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedFacetCatalog carga dos categorías, dos vendedores y cuatro productos
+// repartidos entre ellos.
+func seedFacetCatalog(t *testing.T, e *echo.Echo) {
+	t.Helper()
+
+	seedCatalog(t, e)
+
+	for _, req := range []struct{ path, body string }{
+		{"/api/v1/categories", `{"id": "cat-2", "name": "Bombillas"}`},
+		{"/api/v1/sellers", `{"id": "sel-2", "name": "La Yerbera", "address": "Calle Falsa 123"}`},
+	} {
+		rec := doRequest(e, http.MethodPost, req.path, req.body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	for _, p := range []struct {
+		id, name, category, seller string
+		price                      float64
+		stock, rate                int
+	}{
+		{"p1", "Mate de calabaza", "cat-1", "sel-1", 50, 3, 5},
+		{"p2", "Mate imperial", "cat-1", "sel-2", 150, 0, 4},
+		{"p3", "Bombilla de alpaca", "cat-2", "sel-1", 700, 5, 4},
+		{"p4", "Bombilla de oro", "cat-2", "sel-2", 6000, 1, 3},
+	} {
+		body := fmt.Sprintf(`{"id": %q, "name": %q, "price": %v, "discount": 5, "installments": 1, "stock": %d, "rate": %d,
+			"details": [{"name": "material", "description": "varios"}],
+			"characteristics": {"name": "General", "details": []},
+			"categoryId": %q, "sellerId": %q}`, p.id, p.name, p.price, p.stock, p.rate, p.category, p.seller)
+
+		rec := doRequest(e, http.MethodPost, "/api/v1/products", body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+}
+
+type facetedList struct {
+	Items  []json.RawMessage `json:"items"`
+	Total  int               `json:"total"`
+	Facets service.Facets    `json:"facets"`
+}
+
+func getFaceted(t *testing.T, e *echo.Echo, path string) facetedList {
+	t.Helper()
+
+	rec := doRequest(e, http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var list facetedList
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	return list
+}
+
+// counts resume un facet como valor (o nombre, si tiene) -> cantidad, en orden.
+func counts(buckets []service.FacetBucket) []string {
+	out := []string{}
+	for _, bucket := range buckets {
+		name := bucket.Value
+		if bucket.Label != "" {
+			name = bucket.Label
+		}
+		out = append(out, fmt.Sprintf("%s=%d", name, bucket.Count))
+	}
+	return out
+}
+
+func TestFacets_ListCountsEveryFacet(t *testing.T) {
+	t.Log("🔍 TEST: ?facets= adds counts by category, seller, price, stock and rating to a list page")
+
+	e, _ := newTestServer(t)
+	seedFacetCatalog(t, e)
+
+	list := getFaceted(t, e, "/api/v1/products?facets=category,seller,price,stock,rating&limit=1")
+
+	assert.Len(t, list.Items, 1)
+	assert.Equal(t, 4, list.Total)
+
+	assert.Equal(t, []string{"Bombillas=2", "Mates=2"}, counts(list.Facets["category"]))
+	assert.Equal(t, []string{"Don Mate=2", "La Yerbera=2"}, counts(list.Facets["seller"]))
+	assert.Equal(t, []string{"*-100=1", "100-500=1", "500-1000=1", "1000-5000=0", "5000-*=1"}, counts(list.Facets["price"]))
+	assert.Equal(t, []string{"in_stock=3", "out_of_stock=1"}, counts(list.Facets["stock"]))
+	assert.Equal(t, []string{"5=1", "4=2", "3=1"}, counts(list.Facets["rating"]))
+
+	assert.Equal(t, "cat-2", list.Facets["category"][0].Value)
+	assert.Equal(t, "categoryId[eq]=cat-2", list.Facets["category"][0].Filter)
+	assert.Nil(t, list.Facets["price"][0].From)
+	assert.Equal(t, 100.0, *list.Facets["price"][1].From)
+	assert.Equal(t, 500.0, *list.Facets["price"][1].To)
+
+	// El filtro de cada bucket lista exactamente los productos que cuenta.
+	for _, name := range []string{"category", "seller", "price", "stock", "rating"} {
+		for _, bucket := range list.Facets[name] {
+			filtered := getFaceted(t, e, "/api/v1/products?"+bucket.Filter)
+			assert.Equal(t, bucket.Count, filtered.Total, "%s %s", name, bucket.Filter)
+		}
+	}
+
+	// Sin ?facets= la respuesta es la de siempre.
+	rec := doRequest(e, http.MethodGet, "/api/v1/products?limit=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"facets"`)
+
+	t.Log("✅ Every facet was counted")
+}
+
+func TestFacets_CountsRespectTheOtherFilters(t *testing.T) {
+	t.Log("🔍 TEST: each facet applies every filter except the ones on its own field")
+
+	e, _ := newTestServer(t)
+	seedFacetCatalog(t, e)
+
+	list := getFaceted(t, e, "/api/v1/products?categoryId[eq]=cat-1&facets=category,seller,stock")
+
+	assert.Equal(t, 2, list.Total)
+	// Las otras categorías siguen contando, para poder cambiar de categoría...
+	assert.Equal(t, []string{"Bombillas=2", "Mates=2"}, counts(list.Facets["category"]))
+	// ...y el resto cuenta sólo los productos de la categoría elegida.
+	assert.Equal(t, []string{"Don Mate=1", "La Yerbera=1"}, counts(list.Facets["seller"]))
+	assert.Equal(t, []string{"in_stock=1", "out_of_stock=1"}, counts(list.Facets["stock"]))
+
+	list = getFaceted(t, e, "/api/v1/products?categoryId[eq]=cat-1&stock[gt]=0&facets=category,seller,stock")
+
+	assert.Equal(t, 1, list.Total)
+	assert.Equal(t, []string{"Bombillas=2", "Mates=1"}, counts(list.Facets["category"]))
+	assert.Equal(t, []string{"Don Mate=1"}, counts(list.Facets["seller"]))
+	assert.Equal(t, []string{"in_stock=1", "out_of_stock=1"}, counts(list.Facets["stock"]))
+
+	// En los productos de una categoría, la categoría acota todos los facets.
+	list = getFaceted(t, e, "/api/v1/categories/cat-2/products?facets=category,rating")
+	assert.Equal(t, []string{"Bombillas=2"}, counts(list.Facets["category"]))
+	assert.Equal(t, []string{"4=1", "3=1"}, counts(list.Facets["rating"]))
+
+	t.Log("✅ Facets respected the other filters")
+}
+
+func TestFacets_PriceBucketsAndValidation(t *testing.T) {
+	t.Log("🔍 TEST: price buckets come from ?price_buckets= and bad facet params are a 400")
+
+	e, _ := newTestServer(t)
+	seedFacetCatalog(t, e)
+
+	list := getFaceted(t, e, "/api/v1/products?facets=price&price_buckets=100,1000.5")
+	assert.Equal(t, []string{"*-100=1", "100-1000.5=2", "1000.5-*=1"}, counts(list.Facets["price"]))
+	assert.Equal(t, "price[gte]=100&price[lt]=1000.5", list.Facets["price"][1].Filter)
+	assert.NotContains(t, list.Facets, "category")
+
+	for _, query := range []string{"facets=color", "facets=price&price_buckets=500,100", "facets=price&price_buckets=-1", "facets=price&price_buckets=a"} {
+		rec := doRequest(e, http.MethodGet, "/api/v1/products?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Contains(t, rec.Body.String(), `"error"`)
+	}
+
+	t.Log("✅ Price buckets were configurable and validated")
+}
+
+func TestFacets_StockSkipsProductsWithoutStock(t *testing.T) {
+	t.Log("🔍 TEST: a product without stock isn't counted in a stock bucket its filter doesn't return")
+
+	e, st := newTestServer(t)
+	seedFacetCatalog(t, e)
+
+	// Un producto guardado sin stock (por ejemplo, anterior a la validación).
+	seed(t, st.Products, models.Product{ID: "p5", Name: "Termo", Price: 80, CategoryId: "cat-1", SellerId: "sel-1"})
+
+	list := getFaceted(t, e, "/api/v1/products?facets=stock")
+	assert.Equal(t, 5, list.Total)
+	assert.Equal(t, []string{"in_stock=3", "out_of_stock=1"}, counts(list.Facets["stock"]))
+
+	for _, bucket := range list.Facets["stock"] {
+		filtered := getFaceted(t, e, "/api/v1/products?"+bucket.Filter)
+		assert.Equal(t, bucket.Count, filtered.Total, bucket.Filter)
+	}
+
+	t.Log("✅ Stock buckets matched their filters")
+}
+
+func TestFacets_NamesFollowRenamesAndSearch(t *testing.T) {
+	t.Log("🔍 TEST: facet labels follow renames (and the ETag), and search results can be faceted")
+
+	e, _ := newTestServer(t)
+	seedFacetCatalog(t, e)
+
+	rec := doRequest(e, http.MethodGet, "/api/v1/products?facets=category", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/cat-2", `{"name": "Bombillas y filtros"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products?facets=category", "", "If-None-Match", etag)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"label":"Bombillas y filtros"`)
+
+	// Sin facets, el listado de productos no cambió.
+	rec = doRequest(e, http.MethodGet, "/api/v1/products", "")
+	etag = rec.Header().Get("ETag")
+	rec = doRequest(e, http.MethodGet, "/api/v1/products", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/search?q=mate&facets=category,price&price_buckets=100", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var page service.SearchPage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, []string{"Mates=2"}, counts(page.Facets["category"]))
+	assert.Equal(t, []string{"*-100=1", "100-*=1"}, counts(page.Facets["price"]))
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/search?q=mate", "")
+	assert.NotContains(t, rec.Body.String(), `"facets"`)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/search?q=mate&facets=nope", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	t.Log("✅ Labels followed renames and search results were faceted")
+}
+
+func TestFacets_SearchAcceptsListFilters(t *testing.T) {
+	t.Log("🔍 TEST: search accepts the list filters and applies them to the page and the facets")
+
+	e, _ := newTestServer(t)
+	seedFacetCatalog(t, e)
+
+	search := func(query string) service.SearchPage {
+		t.Helper()
+
+		rec := doRequest(e, http.MethodGet, "/api/v1/products/search?"+query, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var page service.SearchPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page
+	}
+
+	ids := func(page service.SearchPage) []string {
+		out := []string{}
+		for _, item := range page.Items {
+			out = append(out, item.Product.ID)
+		}
+		return out
+	}
+
+	page := search("q=mate+bombilla&sellerId[eq]=sel-2&facets=category,seller")
+	assert.Equal(t, 2, page.Total)
+	assert.ElementsMatch(t, []string{"p2", "p4"}, ids(page))
+	assert.ElementsMatch(t, []string{"Mates=1", "Bombillas=1"}, counts(page.Facets["category"]))
+	// El facet de un campo filtrado cuenta sin su propio filtro.
+	assert.Len(t, page.Facets["seller"], 2)
+
+	page = search("q=mate&price[gte]=100&facets=price&price_buckets=100")
+	assert.Equal(t, []string{"p2"}, ids(page))
+	assert.Equal(t, []string{"*-100=1", "100-*=1"}, counts(page.Facets["price"]))
+
+	// El total y el paginado son los de los resultados filtrados.
+	all := search("q=mate+bombilla&stock[gt]=0")
+	require.Equal(t, 3, all.Total)
+	page = search("q=mate+bombilla&stock[gt]=0&limit=1&offset=1")
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, ids(all)[1:2], ids(page))
+
+	page = search("q=calabaza&sellerId[eq]=sel-2&facets=category")
+	assert.Equal(t, 0, page.Total)
+	assert.Empty(t, page.Items)
+
+	for _, query := range []string{"q=mate&price[between]=1", "q=mate&price[gt=1", "q=mate&color[eq]=rojo"} {
+		rec := doRequest(e, http.MethodGet, "/api/v1/products/search?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Contains(t, rec.Body.String(), `"error"`)
+	}
+
+	t.Log("✅ Search results and facets followed the list filters")
+}
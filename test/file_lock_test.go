@@ -0,0 +1,75 @@
+package main_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	lockProcesses       = 4
+	lockWritesPerWorker = 25
+)
+
+// TestHelperProcess_JSONWriter no es un test real: es el "otro proceso" que
+// lanza TestCrossProcess_JSONCreates_NoLostWrites (otra instancia de la API,
+// un job de importación, una CLI...). Sin las variables de entorno no hace nada.
+func TestHelperProcess_JSONWriter(t *testing.T) {
+	filename := os.Getenv("LOCK_HELPER_FILE")
+	if filename == "" {
+		t.Skip("helper process only")
+	}
+
+	worker := os.Getenv("LOCK_HELPER_WORKER")
+	writes, _ := strconv.Atoi(os.Getenv("LOCK_HELPER_WRITES"))
+
+	repo := &dal.CrudDAL[models.Category]{Filename: filename}
+
+	for i := 0; i < writes; i++ {
+		_, err := repo.Create(&models.Category{
+			ID:   fmt.Sprintf("%s-%d", worker, i),
+			Name: fmt.Sprintf("Cat %s-%d", worker, i),
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestCrossProcess_JSONCreates_NoLostWrites(t *testing.T) {
+	t.Log("🔍 TEST: Several processes writing the same JSON file don't clobber each other")
+
+	filename := filepath.Join(t.TempDir(), "Shared")
+
+	cmds := make([]*exec.Cmd, 0, lockProcesses)
+
+	for w := 0; w < lockProcesses; w++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess_JSONWriter$", "-test.count=1")
+		cmd.Env = append(os.Environ(),
+			"LOCK_HELPER_FILE="+filename,
+			fmt.Sprintf("LOCK_HELPER_WORKER=w%d", w),
+			fmt.Sprintf("LOCK_HELPER_WRITES=%d", lockWritesPerWorker),
+		)
+
+		require.NoError(t, cmd.Start())
+		cmds = append(cmds, cmd)
+	}
+
+	for _, cmd := range cmds {
+		assert.NoError(t, cmd.Wait())
+	}
+
+	repo := &dal.CrudDAL[models.Category]{Filename: filename}
+	all, err := repo.GetAll("", 10000, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, lockProcesses*lockWritesPerWorker)
+
+	t.Log("✅ Every write from every process was kept")
+}
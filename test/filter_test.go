@@ -0,0 +1,82 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_ProductsByFieldAndSort(t *testing.T) {
+	t.Log("🔍 TEST: ?price[lte]=50&stock[gt]=0&sort=-sales_number,price filters and sorts products")
+
+	e, _ := newTestServer(t)
+	seedCatalog(t, e)
+
+	for _, p := range []struct {
+		id           string
+		price        float64
+		stock, sales int
+	}{
+		{"p1", 30, 5, 10},
+		{"p2", 80, 2, 50},
+		{"p3", 45, 0, 90},
+		{"p4", 20, 1, 10},
+		{"p5", 50, 3, 40},
+	} {
+		body := fmt.Sprintf(`{"id": %q, "name": "Mate %s", "price": %v, "discount": 5, "installments": 1,
+			"stock": %d, "sales_number": %d, "details": [{"name": "material", "description": "calabaza"}],
+			"characteristics": {"name": "Imperial", "details": [{"name": "virola", "description": "alpaca"}]},
+			"categoryId": "cat-1", "sellerId": "sel-1"}`, p.id, p.id, p.price, p.stock, p.sales)
+
+		rec := doRequest(e, http.MethodPost, "/api/v1/products", body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	ids := func(path string) []string {
+		t.Helper()
+
+		rec := doRequest(e, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var page dao.Page[models.Product]
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+
+		out := []string{}
+		for _, product := range page.Items {
+			out = append(out, product.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"p5", "p4", "p1"}, ids("/api/v1/products?price[lte]=50&stock[gt]=0&sort=-sales_number,price"))
+	assert.Equal(t, []string{"p3", "p2"}, ids("/api/v1/products?sort=-sales_number&limit=2"))
+	assert.Equal(t, []string{"p1", "p5"}, ids("/api/v1/products?price[gte]=30&price[lte]=50&stock[ne]=0"))
+	assert.Equal(t, []string{"p2", "p4"}, ids("/api/v1/products?id[in]=p2,p4,p9"))
+
+	for _, path := range []string{
+		"/api/v1/products?colour[eq]=red",
+		"/api/v1/products?price[like]=5",
+		"/api/v1/products?price[lte]=barato",
+		"/api/v1/products?price[lte=5",
+		"/api/v1/products?sort=-",
+		"/api/v1/products?sort=weight",
+		"/api/v1/products?discountPrice[lt]=10",
+		"/api/v1/categories?name[gt]=a&sort=price",
+	} {
+		rec := doRequest(e, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), path)
+		assert.NotEmpty(t, body["error"], path)
+	}
+
+	t.Log("✅ Products were filtered and sorted, bad fields and operators were a 400")
+}
@@ -0,0 +1,65 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_ProductsByCategoryAndSeller(t *testing.T) {
+	t.Log("🔍 TEST: /categories/:id/products and /sellers/:id/products list the products pointing to them")
+
+	e, _ := newTestServer(t)
+	seedCatalog(t, e)
+
+	for _, req := range []struct{ path, body string }{
+		{"/api/v1/categories", `{"id":"cat-2","name":"Termos"}`},
+		{"/api/v1/sellers", `{"id":"sel-2","name":"Mates del Sur","address":"Calle 2"}`},
+		{"/api/v1/products", productBody("p1", "cat-1", "sel-1")},
+		{"/api/v1/products", productBody("p2", "cat-2", "sel-1")},
+		{"/api/v1/products", productBody("p3", "cat-1", "sel-2")},
+		{"/api/v1/products", productBody("p4", "cat-1", "sel-1")},
+	} {
+		rec := doRequest(e, http.MethodPost, req.path, req.body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	ids := func(path string) []string {
+		t.Helper()
+
+		rec := doRequest(e, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var page dao.Page[models.Product]
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+
+		out := []string{}
+		for _, product := range page.Items {
+			out = append(out, product.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"p1", "p3", "p4"}, ids("/api/v1/categories/cat-1/products"))
+	assert.Equal(t, []string{"p3", "p4"}, ids("/api/v1/categories/cat-1/products?limit=2&offset=1"))
+	assert.Equal(t, []string{"p1", "p2", "p4"}, ids("/api/v1/sellers/sel-1/products"))
+	assert.Equal(t, []string{"p2"}, ids("/api/v1/categories/cat-2/products"))
+
+	// Los productos en la papelera no aparecen.
+	rec := doRequest(e, http.MethodDelete, "/api/v1/products/p1", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"p3", "p4"}, ids("/api/v1/categories/cat-1/products"))
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/cat-9/products", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+	rec = doRequest(e, http.MethodGet, "/api/v1/sellers/sel-1/products?updated_since=ayer", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	t.Log("✅ Products were listed by category and seller through the index")
+}
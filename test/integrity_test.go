@@ -0,0 +1,250 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/service"
+	models "project/pkg"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// productBody arma el JSON de un POST /products con las referencias dadas.
+func productBody(id string, categoryID string, sellerID string, images ...string) string {
+	raw, _ := json.Marshal(images)
+
+	return fmt.Sprintf(`{
+		"id": %q, "name": "Mate", "price": 10, "discount": 5, "installments": 1, "stock": 3,
+		"details": [{"name": "material", "description": "calabaza"}],
+		"characteristics": {"name": "Imperial", "details": [{"name": "virola", "description": "alpaca"}]},
+		"categoryId": %q, "sellerId": %q, "images": %s
+	}`, id, categoryID, sellerID, raw)
+}
+
+// seedCatalog deja una categoría, un vendedor y una imagen para referenciar.
+func seedCatalog(t *testing.T, e *echo.Echo) {
+	t.Helper()
+
+	for _, req := range []struct{ path, body string }{
+		{"/api/v1/categories", `{"id":"cat-1","name":"Mates"}`},
+		{"/api/v1/sellers", `{"id":"sel-1","name":"Don Mate","address":"Av. Siempre Viva 123"}`},
+		{"/api/v1/images", `{"id":"img-1","name":"Frente","url":"https://cdn.example.com/1.png"}`},
+	} {
+		rec := doRequest(e, http.MethodPost, req.path, req.body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+}
+
+func TestIntegrity_ProductWritesRejectBrokenReferences(t *testing.T) {
+	t.Log("🔍 TEST: creating or patching a product with a missing reference is a 422 naming it")
+
+	e, _ := newTestServer(t)
+	seedCatalog(t, e)
+
+	tests := []struct {
+		body  string
+		field string
+	}{
+		{productBody("p1", "cat-9", "sel-1", "img-1"), `categoryId: category \"cat-9\"`},
+		{productBody("p1", "cat-1", "sel-9", "img-1"), `sellerId: seller \"sel-9\"`},
+		{productBody("p1", "cat-1", "sel-1", "img-1", "img-9"), `images: image \"img-9\"`},
+	}
+
+	for _, tt := range tests {
+		rec := doRequest(e, http.MethodPost, "/api/v1/products", tt.body)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), tt.field)
+	}
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/products", productBody("p1", "cat-1", "sel-1", "img-1"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/products/p1", `{"sellerId":"sel-9"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/products/p1", `{"price":12}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/products/p1/category", `{"id":"cat-9"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `category \"cat-9\" doesn't exist`)
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/p1/images", `{"id":"img-9"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/p1", "")
+	var product models.Product
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+	assert.Equal(t, "cat-1", product.CategoryId)
+	assert.Equal(t, []string{"img-1"}, product.Images)
+
+	t.Log("✅ Broken references never reach the storage")
+}
+
+func TestIntegrity_RestrictBlocksDeletesInUse(t *testing.T) {
+	t.Log("🔍 TEST: with the default policy a category in use can't be deleted")
+
+	e, _ := newTestServer(t)
+	seedCatalog(t, e)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/products", productBody("p1", "cat-1", "sel-1", "img-1"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = doRequest(e, http.MethodDelete, "/api/v1/categories/cat-1", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "p1")
+
+	rec = doRequest(e, http.MethodDelete, "/api/v1/images/img-1", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(e, http.MethodDelete, "/api/v1/categories/nope", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Sin productos activos que la usen, se puede borrar
+	rec = doRequest(e, http.MethodDelete, "/api/v1/products/p1", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = doRequest(e, http.MethodDelete, "/api/v1/categories/cat-1", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	// Restaurar el producto volvería a apuntar a una categoría borrada
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/p1/restore", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	t.Log("✅ Referenced entities are protected")
+}
+
+func TestIntegrity_DeleteRacingCreatesLeavesNoDanglingReferences(t *testing.T) {
+	t.Log("🔍 TEST: a category deleted while products are being created is never left referenced")
+
+	e, st := newTestServer(t)
+	seedCatalog(t, e)
+
+	for round := range 20 {
+		category := fmt.Sprintf("race-%d", round)
+		seed(t, st.Categories, models.Category{ID: category, Name: "Carrera " + category})
+
+		var wg sync.WaitGroup
+
+		for i := range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				doRequest(e, http.MethodPost, "/api/v1/products", productBody(fmt.Sprintf("%s-p%d", category, i), category, "sel-1"))
+			}()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doRequest(e, http.MethodDelete, "/api/v1/categories/"+category, "")
+		}()
+
+		wg.Wait()
+
+		// O la categoría sigue, o ningún producto activo la usa.
+		if _, err := st.Categories.GetByID(category); err == nil {
+			continue
+		}
+
+		users, err := st.Products.List(dao.ListQuery{Equals: map[string]string{"categoryId": category}})
+		require.NoError(t, err)
+		assert.Empty(t, users, category)
+	}
+
+	t.Log("✅ Reference checks and writes were atomic")
+}
+
+func TestIntegrity_CascadeSendsProductsToTrash(t *testing.T) {
+	t.Log("🔍 TEST: DELETE_POLICY=cascade deletes the products that use the seller")
+
+	t.Setenv("DELETE_POLICY", "cascade")
+	e, st := newTestServer(t)
+	seedCatalog(t, e)
+
+	for _, id := range []string{"p1", "p2"} {
+		rec := doRequest(e, http.MethodPost, "/api/v1/products", productBody(id, "cat-1", "sel-1"))
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	rec := doRequest(e, http.MethodDelete, "/api/v1/sellers/sel-1", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	for _, id := range []string{"p1", "p2"} {
+		product, err := st.Products.Find(id, dao.IncludeDeleted)
+		require.NoError(t, err)
+		assert.True(t, product.Deleted())
+		assert.Equal(t, "default", product.DeletedBy)
+	}
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/sellers/sel-1/restore", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/p1/restore", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	t.Log("✅ Cascade moves dependents to the trash")
+}
+
+func TestIntegrity_NullifyDetachesReferences(t *testing.T) {
+	t.Log("🔍 TEST: DELETE_POLICY=nullify deletes the entities and clears them from the products")
+
+	t.Setenv("DELETE_POLICY", "nullify")
+	e, st := newTestServer(t)
+	seedCatalog(t, e)
+	seed(t, st.Images, models.Image{ID: "img-2", Name: "Dorso", URL: "https://cdn.example.com/2.png"})
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/products", productBody("p1", "cat-1", "sel-1", "img-1", "img-2"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	for _, path := range []string{"/api/v1/images/img-1", "/api/v1/categories/cat-1", "/api/v1/sellers/sel-1"} {
+		rec = doRequest(e, http.MethodDelete, path, "")
+		assert.Equal(t, http.StatusNoContent, rec.Code, path)
+	}
+
+	product, err := st.Products.GetByID("p1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"img-2"}, product.Images)
+	assert.Empty(t, product.CategoryId)
+	assert.Empty(t, product.SellerId)
+
+	// El producto sin categoría ni vendedor se sigue pudiendo editar
+	rec = doRequest(e, http.MethodPatch, "/api/v1/products/p1", `{"price":20}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/p1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "/categories/")
+
+	t.Log("✅ Nullify keeps the products and drops the deleted references")
+}
+
+func TestParseDeletePolicy(t *testing.T) {
+	t.Log("🔍 TEST: ParseDeletePolicy accepts the three policies and defaults to restrict")
+
+	tests := map[string]service.DeletePolicy{
+		"":         service.RestrictDelete,
+		"restrict": service.RestrictDelete,
+		"Cascade":  service.CascadeDelete,
+		" nullify": service.NullifyDelete,
+	}
+
+	for raw, want := range tests {
+		policy, err := service.ParseDeletePolicy(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, want, policy)
+	}
+
+	policy, err := service.ParseDeletePolicy("orphan")
+	assert.Error(t, err)
+	assert.Equal(t, service.RestrictDelete, policy)
+
+	t.Log("✅ Delete policies parse as documented")
+}
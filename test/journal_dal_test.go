@@ -0,0 +1,214 @@
+package main_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/utils"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openJournal(t *testing.T, path string, compactEvery int) *dal.JournalDAL[models.Category] {
+	repo, err := dal.OpenJournalDAL[models.Category](path, dal.JournalOptions{CompactEvery: compactEvery})
+	require.NoError(t, err)
+
+	return repo
+}
+
+func TestJournalDAL_ReopenRebuildsState(t *testing.T) {
+	t.Log("🔍 TEST: Creates, updates and deletes survive closing and reopening the journal")
+
+	path := filepath.Join(t.TempDir(), "Category")
+
+	repo := openJournal(t, path, 0)
+	repo.Create(&models.Category{ID: "1", Name: "Mates"})
+	repo.Create(&models.Category{ID: "2", Name: "Termos"})
+	repo.Create(&models.Category{ID: "3", Name: "Bombillas"})
+	_, err := repo.Update(&models.Category{Name: "Mates de calabaza"}, "1")
+	require.NoError(t, err)
+	_, err = repo.Delete("2")
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	repo = openJournal(t, path, 0)
+	defer repo.Close()
+
+	all, err := repo.GetAll("", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "Mates de calabaza", all[0].Name)
+	assert.Equal(t, "3", all[1].ID)
+
+	t.Log("✅ State rebuilt from the journal")
+}
+
+func TestJournalDAL_TornTailIsDiscarded(t *testing.T) {
+	t.Log("🔍 TEST: A half-written last entry is dropped and the journal keeps working")
+
+	path := filepath.Join(t.TempDir(), "Category")
+
+	repo := openJournal(t, path, 0)
+	repo.Create(&models.Category{ID: "1", Name: "Mates"})
+	require.NoError(t, repo.Close())
+
+	// Lo que deja un kill -9 en medio de un write
+	f, err := os.OpenFile(path+".journal", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":2,"ops":[{"op":"insert","id":"2","da`)
+	require.NoError(t, err)
+	f.Close()
+
+	repo = openJournal(t, path, 0)
+	_, err = repo.GetByID("2")
+	assert.Error(t, err)
+
+	_, err = repo.Create(&models.Category{ID: "3", Name: "Termos"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	repo = openJournal(t, path, 0)
+	defer repo.Close()
+
+	all, err := repo.GetAll("", 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	t.Log("✅ Torn tail discarded, later writes kept")
+}
+
+func TestJournalDAL_CorruptMiddleEntryFailsToOpen(t *testing.T) {
+	t.Log("🔍 TEST: A damaged entry followed by more data is reported, not skipped")
+
+	path := filepath.Join(t.TempDir(), "Category")
+	require.NoError(t, os.WriteFile(path+".journal", []byte(
+		"{\"seq\":1,\"ops\":[{\"op\":\"insert\",\"id\":\"1\",\"data\":{\"id\":\"1\"}}]}\n"+
+			"basura\n"+
+			"{\"seq\":3,\"ops\":[{\"op\":\"insert\",\"id\":\"3\",\"data\":{\"id\":\"3\"}}]}\n",
+	), 0644))
+
+	_, err := dal.OpenJournalDAL[models.Category](path, dal.JournalOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "corrupt journal")
+
+	t.Log("✅ Corruption surfaced at startup")
+}
+
+func TestJournalDAL_CompactionWritesSnapshot(t *testing.T) {
+	t.Log("🔍 TEST: After the threshold the journal is folded into a snapshot")
+
+	path := filepath.Join(t.TempDir(), "Category")
+
+	repo := openJournal(t, path, 5)
+	for i := 0; i < 23; i++ {
+		_, err := repo.Create(&models.Category{ID: fmt.Sprintf("%d", i), Name: fmt.Sprintf("Cat %d", i)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Close())
+
+	_, err := os.Stat(path + ".snapshot.json")
+	require.NoError(t, err)
+
+	repo = openJournal(t, path, 5)
+	require.NoError(t, repo.Compact())
+
+	info, err := os.Stat(path + ".journal")
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	all, err := repo.GetAll("", 100, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 23)
+	require.NoError(t, repo.Close())
+
+	repo = openJournal(t, path, 5)
+	defer repo.Close()
+
+	all, err = repo.GetAll("", 100, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 23)
+
+	t.Log("✅ Snapshot plus journal reproduce every write")
+}
+
+func TestJournalDAL_SecondOpenIsRejected(t *testing.T) {
+	t.Log("🔍 TEST: The same journal can't be opened twice at the same time")
+
+	if runtime.GOOS == "windows" {
+		t.Skip("flock not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "Category")
+
+	repo := openJournal(t, path, 0)
+	defer repo.Close()
+
+	_, err := dal.OpenJournalDAL[models.Category](path, dal.JournalOptions{})
+	assert.ErrorIs(t, err, utils.ErrFileLocked)
+
+	t.Log("✅ Second writer rejected")
+}
+
+// TestHelperProcess_JournalWriter es el proceso que TestJournalDAL_SurvivesKill
+// mata con SIGKILL: escribe sin parar e informa por stdout cada ID confirmado.
+func TestHelperProcess_JournalWriter(t *testing.T) {
+	path := os.Getenv("JOURNAL_HELPER_PATH")
+	if path == "" {
+		t.Skip("helper process only")
+	}
+
+	repo := openJournal(t, path, 7)
+
+	out := bufio.NewWriter(os.Stdout)
+	for i := 0; ; i++ {
+		id := fmt.Sprintf("c%d", i)
+
+		_, err := repo.Create(&models.Category{ID: id, Name: "Cat " + id})
+		require.NoError(t, err)
+
+		fmt.Fprintln(out, id)
+		out.Flush()
+	}
+}
+
+func TestJournalDAL_SurvivesKill(t *testing.T) {
+	t.Log("🔍 TEST: kill -9 in the middle of writes and compactions loses no acknowledged write")
+
+	path := filepath.Join(t.TempDir(), "Category")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess_JournalWriter$", "-test.count=1")
+	cmd.Env = append(os.Environ(), "JOURNAL_HELPER_PATH="+path)
+
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	acked := []string{}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && len(acked) < 200 {
+		acked = append(acked, scanner.Text())
+	}
+
+	require.NoError(t, cmd.Process.Kill())
+	cmd.Wait()
+
+	require.Len(t, acked, 200)
+
+	repo := openJournal(t, path, 7)
+	defer repo.Close()
+
+	for _, id := range acked {
+		_, err := repo.GetByID(id)
+		assert.NoError(t, err, "acknowledged write %s lost", id)
+	}
+
+	t.Log("✅ Every acknowledged write was recovered")
+}
@@ -0,0 +1,116 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/utils"
+	models "project/pkg"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheCounter lee el valor actual de un contador de la caché JSON para una colección.
+func cacheCounter(t *testing.T, name string, collection string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "collection" && label.GetValue() == collection {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestJSONCache_ReadsAfterWriteAreHits(t *testing.T) {
+	t.Log("🔍 TEST: Reads after our own writes are served from memory")
+
+	filename := filepath.Join(t.TempDir(), "CacheHits")
+	repo := &dal.CrudDAL[models.Image]{Filename: filename}
+
+	_, err := repo.Create(&models.Image{ID: "img-1", Name: "Foto", URL: "http://a"})
+	require.NoError(t, err)
+
+	hits := cacheCounter(t, "storage_json_cache_hits_total", "CacheHits")
+	misses := cacheCounter(t, "storage_json_cache_misses_total", "CacheHits")
+
+	for i := 0; i < 5; i++ {
+		img, err := repo.GetByID("img-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Foto", img.Name)
+	}
+
+	assert.Equal(t, hits+5, cacheCounter(t, "storage_json_cache_hits_total", "CacheHits"))
+	assert.Equal(t, misses, cacheCounter(t, "storage_json_cache_misses_total", "CacheHits"))
+
+	t.Log("✅ No file decode after the write")
+}
+
+func TestJSONCache_ExternalWriteInvalidates(t *testing.T) {
+	t.Log("🔍 TEST: A change made by someone else to the file is picked up on the next read")
+
+	filename := filepath.Join(t.TempDir(), "CacheExternal")
+	repo := &dal.CrudDAL[models.Category]{Filename: filename}
+
+	_, err := repo.Create(&models.Category{ID: "c1", Name: "Original"})
+	require.NoError(t, err)
+
+	_, err = repo.GetByID("c1")
+	require.NoError(t, err)
+
+	// Otro proceso reemplazando el archivo (rename atómico)
+	require.NoError(t, utils.WriteJSON(filename, []models.Category{{ID: "c1", Name: "Renombrada"}}))
+
+	c, err := repo.GetByID("c1")
+	require.NoError(t, err)
+	assert.Equal(t, "Renombrada", c.Name)
+
+	// Edición a mano, escribiendo el archivo en el lugar
+	require.NoError(t, os.WriteFile(filename+".json", []byte(`[{"id":"c1","name":"Editada a mano"}]`), 0644))
+
+	c, err = repo.GetByID("c1")
+	require.NoError(t, err)
+	assert.Equal(t, "Editada a mano", c.Name)
+
+	t.Log("✅ The cache follows the file on disk")
+}
+
+func TestJSONCache_ReturnedEntitiesAreCopies(t *testing.T) {
+	t.Log("🔍 TEST: Mutating a returned entity doesn't change what's cached")
+
+	filename := filepath.Join(t.TempDir(), "CacheCopies")
+	repo := &dal.CrudDAL[models.Product]{Filename: filename}
+
+	_, err := repo.Create(&models.Product{ID: "p1", Name: "Producto", Images: []string{"a"}})
+	require.NoError(t, err)
+
+	p, err := repo.GetByID("p1")
+	require.NoError(t, err)
+	p.Name = "Cambiado"
+	p.Images[0] = "z"
+
+	all, err := repo.GetAll("", 10, 0)
+	require.NoError(t, err)
+	all[0].Images[0] = "y"
+
+	again, err := repo.GetByID("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "Producto", again.Name)
+	assert.Equal(t, []string{"a"}, again.Images)
+
+	t.Log("✅ Callers can't corrupt the in-memory copy")
+}
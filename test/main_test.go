@@ -3,9 +3,11 @@ package main_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"project/cmd/routes"
+	"project/internal/item_detail/repo/datasource/dal"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -15,8 +17,11 @@ func TestUpRoute(t *testing.T) {
 	// Crear Echo
 	e := echo.New()
 
+	storage, err := dal.OpenStorage(dal.StorageConfig{Driver: "json", DataDir: t.TempDir()})
+	assert.NoError(t, err)
+
 	// Registrar las rutas igual que en main.go
-	router := routes.Routes(e)
+	router := routes.Routes(e, storage)
 
 	// Crear request GET /
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -29,3 +34,35 @@ func TestUpRoute(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "UP", rec.Body.String())
 }
+
+const testAPIKey = "test-key"
+
+// newTestServer arma el router completo sobre un storage en memoria.
+func newTestServer(t *testing.T) (*echo.Echo, *dal.Storage) {
+	t.Helper()
+	t.Setenv("API_KEY", testAPIKey)
+
+	st, err := dal.OpenStorage(dal.StorageConfig{Driver: "memory"})
+	assert.NoError(t, err)
+
+	return routes.Routes(echo.New(), st), st
+}
+
+// doRequest ejecuta una request autenticada contra el router. headers va de a pares nombre/valor.
+func doRequest(e *echo.Echo, method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-API-Key", testAPIKey)
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
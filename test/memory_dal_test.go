@@ -0,0 +1,118 @@
+package main_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDAL_CreateAndGetByID(t *testing.T) {
+	t.Log("🔍 TEST: Ensures the memory DAL stores entities and runs Init()")
+
+	t.Parallel()
+
+	repo := dal.NewMemoryDAL[models.Product]()
+
+	created, err := repo.Create(&models.Product{Name: "Mate", Price: 100, Discount: 10, CategoryId: "c1"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+
+	p, err := repo.GetByID(created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mate", p.Name)
+	assert.Equal(t, 90.0, p.DiscountPrice)
+	assert.Contains(t, p.Category.Href, "/categories/c1")
+
+	_, err = repo.GetByID("no-existe")
+	assert.Error(t, err)
+
+	t.Log("✅ Entity stored and initialized")
+}
+
+func TestMemoryDAL_ReturnsIndependentCopies(t *testing.T) {
+	t.Log("🔍 TEST: Ensures mutating a returned entity does not change the stored one")
+
+	t.Parallel()
+
+	repo := dal.NewMemoryDAL[models.Product]()
+
+	repo.Create(&models.Product{
+		ID:      "1",
+		Name:    "Original",
+		Details: []models.ProductDetail{{Name: "Color", Description: "Rojo"}},
+	})
+
+	p, _ := repo.GetByID("1")
+	p.Name = "Cambiado"
+	p.Details[0].Description = "Azul"
+
+	again, _ := repo.GetByID("1")
+	assert.Equal(t, "Original", again.Name)
+	assert.Equal(t, "Rojo", again.Details[0].Description)
+
+	t.Log("✅ Stored entity unaffected by caller mutations")
+}
+
+func TestMemoryDAL_FailedUpdateLeavesDataIntact(t *testing.T) {
+	t.Log("🔍 TEST: Ensures an Update() without changes fails and keeps the entity")
+
+	t.Parallel()
+
+	repo := dal.NewMemoryDAL[models.Category]()
+	repo.Create(&models.Category{ID: "1", Name: "Hogar"})
+
+	_, err := repo.Update(&models.Category{}, "1")
+	assert.Error(t, err)
+
+	cat, _ := repo.GetByID("1")
+	assert.Equal(t, "Hogar", cat.Name)
+
+	t.Log("✅ Failed update did not touch the stored entity")
+}
+
+func TestMemoryDAL_ConcurrentCreates(t *testing.T) {
+	t.Log("🔍 TEST: Ensures concurrent Create() calls are all kept")
+
+	t.Parallel()
+
+	repo := dal.NewMemoryDAL[models.Category]()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repo.Create(&models.Category{ID: fmt.Sprintf("%d", i), Name: fmt.Sprintf("Cat %d", i)})
+		}(i)
+	}
+	wg.Wait()
+
+	all, err := repo.GetAll("", 100, 0)
+	assert.NoError(t, err)
+	assert.Len(t, all, 50)
+
+	t.Log("✅ No creates were lost")
+}
+
+func TestOpenStorage_Memory(t *testing.T) {
+	t.Log("🔍 TEST: Ensures STORAGE_DRIVER=memory needs no data directory")
+
+	t.Parallel()
+
+	st, err := dal.OpenStorage(dal.StorageConfig{Driver: "memory"})
+	assert.NoError(t, err)
+
+	_, err = st.Images.Create(&models.Image{ID: "img", Name: "Foto", URL: "http://x/y.png"})
+	assert.NoError(t, err)
+
+	img, err := st.Images.GetByID("img")
+	assert.NoError(t, err)
+	assert.Equal(t, "Foto", img.Name)
+
+	t.Log("✅ Memory storage selectable through the registry")
+}
@@ -0,0 +1,150 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pageLink devuelve el target del rel pedido en un header Link.
+func pageLink(header string, rel string) string {
+	match := regexp.MustCompile(`<([^>]*)>; rel="` + rel + `"`).FindStringSubmatch(header)
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+func TestPagination_CursorsTotalsAndLinks(t *testing.T) {
+	t.Log("🔍 TEST: list endpoints return total and cursors, and Link headers walk the pages")
+
+	e, _ := newTestServer(t)
+
+	for i := 1; i <= 5; i++ {
+		rec := doRequest(e, http.MethodPost, "/api/v1/categories", fmt.Sprintf(`{"id":"c%d","name":"Categoría %d"}`, i, i))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	get := func(path string) (dao.Page[models.Category], http.Header) {
+		t.Helper()
+
+		rec := doRequest(e, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var page dao.Page[models.Category]
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+
+		return page, rec.Header()
+	}
+
+	ids := func(page dao.Page[models.Category]) []string {
+		out := []string{}
+		for _, item := range page.Items {
+			out = append(out, item.ID)
+		}
+		return out
+	}
+
+	page, header := get("/api/v1/categories?limit=2&q=categor")
+	assert.Equal(t, []string{"c1", "c2"}, ids(page))
+	assert.Equal(t, 5, page.Total)
+	assert.Empty(t, page.PrevCursor)
+
+	links := header.Get("Link")
+	assert.Equal(t, "/api/v1/categories?limit=2&q=categor", pageLink(links, "first"))
+	assert.Empty(t, pageLink(links, "prev"))
+
+	next := pageLink(links, "next")
+	require.NotEmpty(t, next)
+	target, err := url.Parse(next)
+	require.NoError(t, err)
+	assert.Equal(t, page.NextCursor, target.Query().Get("cursor"))
+	assert.Equal(t, "categor", target.Query().Get("q"))
+
+	// Un alta en medio del recorrido no repite ni saltea elementos.
+	rec := doRequest(e, http.MethodPost, "/api/v1/categories", `{"id":"c0","name":"Categoría 0"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = doRequest(e, http.MethodDelete, "/api/v1/categories/c1", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	page, header = get(next)
+	assert.Equal(t, []string{"c3", "c4"}, ids(page))
+	assert.Equal(t, 5, page.Total)
+
+	page, header = get(pageLink(header.Get("Link"), "next"))
+	assert.Equal(t, []string{"c5", "c0"}, ids(page))
+	assert.Empty(t, page.NextCursor)
+	assert.Empty(t, pageLink(header.Get("Link"), "next"))
+
+	page, _ = get(pageLink(header.Get("Link"), "prev"))
+	assert.Equal(t, []string{"c3", "c4"}, ids(page))
+
+	// limit/offset siguen funcionando, con total y cursores.
+	page, header = get("/api/v1/categories?limit=2&offset=2")
+	assert.Equal(t, []string{"c4", "c5"}, ids(page))
+	assert.NotEmpty(t, pageLink(header.Get("Link"), "prev"))
+	assert.NotEmpty(t, pageLink(header.Get("Link"), "next"))
+
+	for _, path := range []string{
+		"/api/v1/categories?cursor=basura",
+		"/api/v1/categories?cursor=" + url.QueryEscape(page.NextCursor) + "&offset=2",
+		"/api/v1/categories?cursor=" + url.QueryEscape(page.NextCursor) + "&sort=-name",
+	} {
+		rec := doRequest(e, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+	}
+
+	t.Log("✅ Pages carried totals, cursors and Link headers, and stayed stable across writes")
+}
+
+func TestPagination_CursorKeepsInsertionOrderWhenEdgeIsGone(t *testing.T) {
+	t.Log("🔍 TEST: a cursor whose edge was deleted resumes in insertion order, not by created_at and ID")
+
+	dir := t.TempDir()
+
+	// Datos importados: mismo created_at y los IDs en otro orden que el de alta.
+	at := `"created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z","version":1`
+	data := `[{"id":"c3","name":"Mates",` + at + `},{"id":"c1","name":"Termos",` + at + `},{"id":"c2","name":"Bombillas",` + at + `}]`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Category.json"), []byte(data), 0644))
+
+	st := openDriver(t, "json", dir)
+	defer st.Close()
+
+	first, err := st.Categories.Page(dao.ListQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, first.Items, 1)
+	assert.Equal(t, "c3", first.Items[0].ID)
+
+	_, err = st.Categories.Delete("c3")
+	require.NoError(t, err)
+
+	page, err := st.Categories.Page(dao.ListQuery{Limit: 1, Cursor: first.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "c1", page.Items[0].ID)
+
+	// Purgado, las siguientes se corren una posición: el cursor sigue igual.
+	_, err = st.Categories.Purge(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	page, err = st.Categories.Page(dao.ListQuery{Limit: 2, Cursor: first.NextCursor})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, "c1", page.Items[0].ID)
+	assert.Equal(t, "c2", page.Items[1].ID)
+
+	t.Log("✅ The next page started right after the deleted edge")
+}
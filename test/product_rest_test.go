@@ -2,14 +2,12 @@ package main_test
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/repo/datasource/dao"
 	"project/internal/item_detail/rest"
 	"project/internal/item_detail/service"
-	"project/internal/item_detail/utils"
 	models "project/pkg"
 	"strings"
 	"testing"
@@ -49,35 +47,26 @@ func createTestProduct() models.Product {
 }
 
 /* ===========================================================
-   Helper: reescribe JSON y lo restaura o elimina al final
+   Helper: storage en memoria aislado por test
    =========================================================== */
 
-func SafeRewriteJSON(t *testing.T, filename string, data interface{}) {
-	// Ver si existía
-	original, err := os.ReadFile(filename)
-	existed := (err == nil)
+func newTestStorage(t *testing.T) *dal.Storage {
+	t.Helper()
 
-	// WriteJSON requiere nombre SIN .json
-	base := strings.TrimSuffix(filename, ".json")
-
-	err = utils.WriteJSON(base, data)
+	st, err := dal.OpenStorage(dal.StorageConfig{Driver: "memory"})
 	assert.NoError(t, err)
 
-	// Limpieza automática al finalizar el test
-	t.Cleanup(func() {
-		if existed {
-			// Restaurar archivo original
-			_ = ioutil.WriteFile(filename, original, 0644)
-		} else {
-			// Borrar archivo creado por el test
-			_ = os.Remove(filename)
-		}
-	})
+	return st
 }
 
-/* ===========================================================
-   Test GetProduct()
-   =========================================================== */
+func seed[T any](t *testing.T, d dao.CrudDAO[T], items ...T) {
+	t.Helper()
+
+	for i := range items {
+		_, err := d.Create(&items[i])
+		assert.NoError(t, err)
+	}
+}
 
 /* ===========================================================
    Test GetProduct()
@@ -86,18 +75,16 @@ func SafeRewriteJSON(t *testing.T, filename string, data interface{}) {
 func TestGetProduct(t *testing.T) {
 	t.Log("🔍 TEST: Validates GetProduct returns product data for a valid ID")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	// Crear producto temporal
 	product := createTestProduct()
-	SafeRewriteJSON(t, "Product.json", []models.Product{product})
-
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
+	seed(t, st.Products, product)
 
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images)
 	handler := rest.NewProductHandler(svc)
 
 	req := httptest.NewRequest("GET", "/products/1", nil)
@@ -122,21 +109,19 @@ func TestGetProduct(t *testing.T) {
 func TestGetCategories(t *testing.T) {
 	t.Log("🔍 TEST: Ensures categories for a product are correctly returned")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	product := createTestProduct()
 
-	SafeRewriteJSON(t, "Product.json", []models.Product{product})
-	SafeRewriteJSON(t, "Category.json", []models.Category{
-		{ID: "100", Name: "Electronics"},
-	})
-
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
+	seed(t, st.Products, product)
+	seed(t, st.Categories,
+		models.Category{ID: "100", Name: "Electronics"},
+	)
 
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images)
 	handler := rest.NewProductHandler(svc)
 
 	req := httptest.NewRequest("GET", "/products/1/categories", nil)
@@ -161,21 +146,19 @@ func TestGetCategories(t *testing.T) {
 func TestGetSellers(t *testing.T) {
 	t.Log("🔍 TEST: Ensures sellers related to a product are correctly resolved")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	product := createTestProduct()
 
-	SafeRewriteJSON(t, "Product.json", []models.Product{product})
-	SafeRewriteJSON(t, "Seller.json", []models.Seller{
-		{ID: "200", Name: "TestSeller"},
-	})
+	seed(t, st.Products, product)
+	seed(t, st.Sellers,
+		models.Seller{ID: "200", Name: "TestSeller"},
+	)
 
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
-
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images)
 	handler := rest.NewProductHandler(svc)
 
 	req := httptest.NewRequest("GET", "/products/1/sellers", nil)
@@ -200,21 +183,19 @@ func TestGetSellers(t *testing.T) {
 func TestGetImages(t *testing.T) {
 	t.Log("🔍 TEST: Ensures images linked to the product are correctly fetched")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	product := createTestProduct()
 
-	SafeRewriteJSON(t, "Product.json", []models.Product{product})
-	SafeRewriteJSON(t, "Image.json", []models.Image{
-		{ID: "9afa306b-1a10-472f-965b-09dd511d56d1", Name: "TestImages"},
-	})
+	seed(t, st.Products, product)
+	seed(t, st.Images,
+		models.Image{ID: "9afa306b-1a10-472f-965b-09dd511d56d1", Name: "TestImages"},
+	)
 
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
-
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images)
 	handler := rest.NewProductHandler(svc)
 
 	req := httptest.NewRequest("GET", "/products/1/images", nil)
@@ -239,17 +220,15 @@ func TestGetImages(t *testing.T) {
 func TestGetCharacteristic(t *testing.T) {
 	t.Log("🔍 TEST: Validates product characteristic retrieval")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	product := createTestProduct()
-	SafeRewriteJSON(t, "Product.json", []models.Product{product})
+	seed(t, st.Products, product)
 
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
-
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images)
 	handler := rest.NewProductHandler(svc)
 
 	req := httptest.NewRequest("GET", "/products/1/characteristic", nil)
@@ -274,22 +253,20 @@ func TestGetCharacteristic(t *testing.T) {
 func TestChangeCategories(t *testing.T) {
 	t.Log("🔍 TEST: Confirms product category is updated correctly")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	product := createTestProduct()
 
-	SafeRewriteJSON(t, "Product.json", []models.Product{product})
-	SafeRewriteJSON(t, "Category.json", []models.Category{
-		{ID: "100", Name: "Electronics"},
-		{ID: "999", Name: "Games"},
-	})
+	seed(t, st.Products, product)
+	seed(t, st.Categories,
+		models.Category{ID: "100", Name: "Electronics"},
+		models.Category{ID: "999", Name: "Games"},
+	)
 
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
-
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images).WithTransactor(st)
 	handler := rest.NewProductHandler(svc)
 
 	body := `{"id":"999"}`
@@ -306,8 +283,8 @@ func TestChangeCategories(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusCreated, rec.Code)
 
-	updatedBytes, _ := ioutil.ReadFile("Product.json")
-	assert.Contains(t, string(updatedBytes), `"categoryId": "999"`)
+	updated, _ := st.Products.GetByID("1")
+	assert.Equal(t, "999", updated.CategoryId)
 
 	t.Log("✅ Category updated successfully")
 }
@@ -319,22 +296,20 @@ func TestChangeCategories(t *testing.T) {
 func TestChangeSellers(t *testing.T) {
 	t.Log("🔍 TEST: Confirms product seller is updated correctly")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	product := createTestProduct()
 
-	SafeRewriteJSON(t, "Product.json", []models.Product{product})
-	SafeRewriteJSON(t, "Seller.json", []models.Seller{
-		{ID: "200", Name: "Old"},
-		{ID: "333", Name: "NewSeller"},
-	})
-
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
+	seed(t, st.Products, product)
+	seed(t, st.Sellers,
+		models.Seller{ID: "200", Name: "Old"},
+		models.Seller{ID: "333", Name: "NewSeller"},
+	)
 
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images).WithTransactor(st)
 	handler := rest.NewProductHandler(svc)
 
 	body := `{"id":"333"}`
@@ -351,8 +326,8 @@ func TestChangeSellers(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusCreated, rec.Code)
 
-	updatedBytes, _ := ioutil.ReadFile("Product.json")
-	assert.Contains(t, string(updatedBytes), `"sellerId": "333"`)
+	updated, _ := st.Products.GetByID("1")
+	assert.Equal(t, "333", updated.SellerId)
 
 	t.Log("✅ Seller updated successfully")
 }
@@ -364,16 +339,18 @@ func TestChangeSellers(t *testing.T) {
 func TestGetAllProducts_FilterByQuery_SingleMatch(t *testing.T) {
 	t.Log("🔍 TEST: Returns only the product that matches the ?q= filter")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	products := []models.Product{
 		{ID: "1", Name: "Laptop Gamer"},
 		{ID: "2", Name: "Mouse"},
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	req := httptest.NewRequest(http.MethodGet, "/products?q=gamer", nil)
@@ -394,17 +371,19 @@ func TestGetAllProducts_FilterByQuery_SingleMatch(t *testing.T) {
 func TestGetAllProducts_FilterByQuery_MultipleMatch(t *testing.T) {
 	t.Log("🔍 TEST: Returns all products matching the substring in ?q=")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	products := []models.Product{
 		{ID: "1", Name: "MacBook Pro"},
 		{ID: "2", Name: "Mac Mini"},
 		{ID: "3", Name: "Monitor Samsung"},
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	req := httptest.NewRequest(http.MethodGet, "/products?q=mac", nil)
@@ -427,16 +406,18 @@ func TestGetAllProducts_FilterByQuery_MultipleMatch(t *testing.T) {
 func TestGetAllProducts_FilterByQuery_NoMatch(t *testing.T) {
 	t.Log("🔍 TEST: When no items match, the endpoint must return an empty array []")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	products := []models.Product{
 		{ID: "1", Name: "Camera"},
 		{ID: "2", Name: "Speaker"},
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	req := httptest.NewRequest(http.MethodGet, "/products?q=xyz", nil)
@@ -447,7 +428,7 @@ func TestGetAllProducts_FilterByQuery_NoMatch(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.Equal(t, "[]", strings.TrimSpace(rec.Body.String()))
+	assert.JSONEq(t, `{"items": [], "total": 0}`, rec.Body.String())
 
 	t.Log("✅ No matches: returned [] as expected")
 }
@@ -455,16 +436,18 @@ func TestGetAllProducts_FilterByQuery_NoMatch(t *testing.T) {
 func TestGetAllProducts_NoQueryParam_ReturnsAll(t *testing.T) {
 	t.Log("🔍 TEST: Without ?q=, the endpoint must return all products")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	products := []models.Product{
 		{ID: "1", Name: "Table"},
 		{ID: "2", Name: "Chair"},
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	req := httptest.NewRequest(http.MethodGet, "/products", nil)
@@ -486,15 +469,17 @@ func TestGetAllProducts_NoQueryParam_ReturnsAll(t *testing.T) {
 func TestGetAllProducts_FilterByQuery_CaseInsensitive(t *testing.T) {
 	t.Log("🔍 TEST: Query filter must be case-insensitive")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	products := []models.Product{
 		{ID: "1", Name: "Monitor LG"},
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	req := httptest.NewRequest(http.MethodGet, "/products?q=monitor", nil)
@@ -513,17 +498,19 @@ func TestGetAllProducts_FilterByQuery_CaseInsensitive(t *testing.T) {
 func TestGetAllProducts_Pagination_DefaultLimit(t *testing.T) {
 	t.Log("🔍 TEST: Ensures default pagination returns the first 10 items")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	// Create 15 items
 	products := []models.Product{}
 	for i := 1; i <= 15; i++ {
 		products = append(products, models.Product{ID: fmt.Sprintf("%d", i), Name: fmt.Sprintf("Item %d", i)})
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	req := httptest.NewRequest(http.MethodGet, "/products?limit=10&offset=0", nil)
@@ -552,17 +539,19 @@ func TestGetAllProducts_Pagination_DefaultLimit(t *testing.T) {
 func TestGetAllProducts_Pagination_OffsetWorks(t *testing.T) {
 	t.Log("🔍 TEST: Ensures offset skips items correctly")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	// Create 10 items
 	products := []models.Product{}
 	for i := 1; i <= 10; i++ {
 		products = append(products, models.Product{ID: fmt.Sprintf("%d", i), Name: fmt.Sprintf("Item %d", i)})
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	// offset=5 → should return items 6–10
@@ -591,17 +580,19 @@ func TestGetAllProducts_Pagination_OffsetWorks(t *testing.T) {
 func TestGetAllProducts_Pagination_LimitWorks(t *testing.T) {
 	t.Log("🔍 TEST: Ensures custom limit restricts returned items")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	// Create 20 items
 	products := []models.Product{}
 	for i := 1; i <= 20; i++ {
 		products = append(products, models.Product{ID: fmt.Sprintf("%d", i), Name: fmt.Sprintf("Item %d", i)})
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	req := httptest.NewRequest(http.MethodGet, "/products?limit=3&offset=0", nil)
@@ -625,16 +616,18 @@ func TestGetAllProducts_Pagination_LimitWorks(t *testing.T) {
 func TestGetAllProducts_Pagination_OffsetOutOfRange(t *testing.T) {
 	t.Log("🔍 TEST: When offset exceeds dataset size, an empty array must be returned")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	products := []models.Product{
 		{ID: "1", Name: "Item 1"},
 		{ID: "2", Name: "Item 2"},
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	req := httptest.NewRequest(http.MethodGet, "/products?offset=10&limit=5", nil)
@@ -643,7 +636,7 @@ func TestGetAllProducts_Pagination_OffsetOutOfRange(t *testing.T) {
 
 	err := handler.GetAllEntities(c)
 	assert.NoError(t, err)
-	assert.Equal(t, "[]", strings.TrimSpace(rec.Body.String()))
+	assert.JSONEq(t, `{"items": [], "total": 2}`, rec.Body.String())
 
 	t.Log("✅ Offset out of range returned empty array []")
 }
@@ -651,16 +644,18 @@ func TestGetAllProducts_Pagination_OffsetOutOfRange(t *testing.T) {
 func TestGetAllProducts_Pagination_DefaultsApplied(t *testing.T) {
 	t.Log("🔍 TEST: Negative or missing pagination params should apply defaults (limit=10, offset=0)")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	products := []models.Product{}
 	for i := 1; i <= 12; i++ {
 		products = append(products, models.Product{ID: fmt.Sprintf("%d", i), Name: fmt.Sprintf("Item %d", i)})
 	}
-	SafeRewriteJSON(t, "Product.json", products)
+	seed(t, st.Products, products...)
 
-	productDal := dal.NewProductDAL()
-	svc := service.NewCrudService(productDal)
+	svc := service.NewCrudService(st.Products)
 	handler := rest.NewCrudHandler(svc)
 
 	// No limit and no offset
@@ -718,25 +713,23 @@ func createProductForImages() models.Product {
 func TestAddImages_AddsNewImage(t *testing.T) {
 	t.Log("🔍 TEST: Ensures AddImages() appends a new image correctly")
 
+	t.Parallel()
+
 	e := echo.New()
+	st := newTestStorage(t)
 
 	// --- Product with 1 initial image ---
 	product := createProductForImages()
-	SafeRewriteJSON(t, "Product.json", []models.Product{product})
+	seed(t, st.Products, product)
 
 	// --- Image exists ---
-	SafeRewriteJSON(t, "Image.json", []models.Image{
-		{ID: "existing-img", Name: "Existing"},
-		{ID: "new-image", Name: "NewImage"},
-	})
-
-	// DAL reales
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
-
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	seed(t, st.Images,
+		models.Image{ID: "existing-img", Name: "Existing"},
+		models.Image{ID: "new-image", Name: "NewImage"},
+	)
+
+	// Storage en memoria
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images).WithTransactor(st)
 	handler := rest.NewProductHandler(svc)
 
 	// Body con el ID de la imagen a agregar
@@ -753,12 +746,11 @@ func TestAddImages_AddsNewImage(t *testing.T) {
 	err := handler.AddImages(c)
 	assert.NoError(t, err)
 
-	// Validate DB (JSON)
-	updatedBytes, _ := ioutil.ReadFile("Product.json")
-	bodyStr := string(updatedBytes)
+	// Validate storage
+	updated, _ := st.Products.GetByID("prod-1")
 
-	assert.Contains(t, bodyStr, `"existing-img"`)
-	assert.Contains(t, bodyStr, `"new-image"`)
+	assert.Contains(t, updated.Images, "existing-img")
+	assert.Contains(t, updated.Images, "new-image")
 
 	t.Log("✅ Image appended successfully")
 }
@@ -770,21 +762,17 @@ func TestAddImages_AddsNewImage(t *testing.T) {
 func TestAddImages_ProductNotFound(t *testing.T) {
 	t.Log("🔍 TEST: Ensures AddImages() returns 404 for missing product")
 
-	e := echo.New()
-
-	// Product JSON vacío
-	SafeRewriteJSON(t, "Product.json", []models.Product{})
+	t.Parallel()
 
-	SafeRewriteJSON(t, "Image.json", []models.Image{
-		{ID: "img-1", Name: "TestImage"},
-	})
+	e := echo.New()
+	st := newTestStorage(t)
 
-	productDal := dal.NewProductDAL()
-	sellerDal := dal.NewSellerDAL()
-	categoryDal := dal.NewCategoryDAL()
-	imageDal := dal.NewImageDAL()
+	// Sin productos cargados
+	seed(t, st.Images,
+		models.Image{ID: "img-1", Name: "TestImage"},
+	)
 
-	svc := service.NewProductService(productDal, sellerDal, categoryDal, imageDal)
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images).WithTransactor(st)
 	handler := rest.NewProductHandler(svc)
 
 	body := `{"id":"img-1"}`
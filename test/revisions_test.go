@@ -0,0 +1,109 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/utils"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevisions_HistoryDiffAndRevert(t *testing.T) {
+	t.Log("🔍 TEST: a price change can be listed, inspected, diffed and reverted")
+
+	e, st := newTestServer(t)
+	product := createTestProduct()
+	product.Price = 100
+	seed(t, st.Products, product)
+
+	rec := doRequest(e, http.MethodPatch, "/api/v1/products/1", `{"price": 80}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/1/revisions", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var revisions []dao.Revision[models.Product]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &revisions))
+	require.Len(t, revisions, 2)
+	assert.Equal(t, int64(1), revisions[0].Rev)
+	assert.Equal(t, int64(2), revisions[1].Rev)
+	assert.Equal(t, "default", revisions[1].UpdatedBy)
+	assert.Nil(t, revisions[0].Entity, "the listing doesn't carry the content")
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/1/revisions/1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var first dao.Revision[models.Product]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &first))
+	require.NotNil(t, first.Entity)
+	assert.Equal(t, 100.0, first.Entity.Price)
+
+	etag := rec.Header().Get("ETag")
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/1/revisions/1", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/1/revisions/diff?from=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var diff struct {
+		From    int64               `json:"from"`
+		To      int64               `json:"to"`
+		Changes []utils.FieldChange `json:"changes"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &diff))
+	assert.Equal(t, int64(1), diff.From)
+	assert.Equal(t, int64(2), diff.To)
+	assert.Contains(t, diff.Changes, utils.FieldChange{Field: "price", From: 100.0, To: 80.0})
+
+	for _, change := range diff.Changes {
+		assert.NotEqual(t, "version", change.Field, "metadata isn't part of the diff")
+	}
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/1/revisions/1/revert", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/1/revisions/1/revert", "", "If-Match", `"2"`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	var reverted models.Product
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reverted))
+	assert.Equal(t, 100.0, reverted.Price)
+	assert.Equal(t, int64(3), reverted.Version)
+
+	t.Log("✅ Revisions are browsable and a revert is just another revision")
+}
+
+func TestRevisions_InvalidRequests(t *testing.T) {
+	t.Log("🔍 TEST: revision endpoints reject malformed or unknown revisions")
+
+	e, st := newTestServer(t)
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/api/v1/categories/c1/revisions/uno", http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/categories/c1/revisions/5", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/categories/c1/revisions/diff", http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/categories/c1/revisions/diff?from=1&to=x", http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/categories/c1/revisions/diff?from=1&to=4", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/categories/nope/revisions", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/categories/c1/revisions/x/revert", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/categories/c1/revisions/1/revert", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		rec := doRequest(e, tt.method, tt.path, "")
+		assert.Equal(t, tt.status, rec.Code, "%s %s", tt.method, tt.path)
+	}
+
+	t.Log("✅ Invalid revision requests are rejected")
+}
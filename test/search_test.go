@@ -0,0 +1,334 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"project/internal/item_detail/search"
+	"project/internal/item_detail/service"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch_AnalyzerFoldsAndStems(t *testing.T) {
+	t.Log("🔍 TEST: accents, case, plurals and gender reduce to the same term")
+
+	same := [][]string{
+		{"Auriculares", "auricular"},
+		{"Canción", "CANCIONES", "cancion"},
+		{"inalámbricos", "Inalámbrica"},
+		{"luces", "luz"},
+		{"dulces", "dulce"},
+		{"rápidamente", "rápido"},
+		{"Mates", "mate"},
+	}
+
+	for _, words := range same {
+		for _, word := range words[1:] {
+			assert.Equal(t, search.Terms(words[0]), search.Terms(word), "%s ~ %s", words[0], word)
+		}
+	}
+
+	assert.Equal(t, []string{"mat", "calabaz"}, search.Terms("El mate de la calabaza"))
+	assert.Equal(t, []string{"x200"}, search.Terms("X200"))
+	assert.Empty(t, search.Terms("de la y"))
+
+	t.Log("✅ Words were folded, stemmed and stopwords dropped")
+}
+
+func TestSearch_IndexRanksAndUpdatesIncrementally(t *testing.T) {
+	t.Log("🔍 TEST: the inverted index ranks by relevance and follows Put/Remove")
+
+	ix := search.NewIndex(search.Field{Name: "name", Weight: 3}, search.Field{Name: "body", Weight: 1})
+
+	ix.Put("a", "Parlante portátil", "Con bluetooth y batería")
+	ix.Put("b", "Auriculares bluetooth", "Auriculares inalámbricos con micrófono")
+	ix.Put("c", "Mate de calabaza", "Incluye bombilla")
+
+	hits := ix.Search("auricular bluetooth")
+	require.Len(t, hits, 2)
+	assert.Equal(t, "b", hits[0].ID)
+	assert.Equal(t, "a", hits[1].ID)
+	assert.Greater(t, hits[0].Score, hits[1].Score)
+
+	highlights := ix.Highlights(hits[0])
+	assert.Equal(t, "<em>Auriculares</em> <em>bluetooth</em>", highlights["name"])
+	assert.Equal(t, "<em>Auriculares</em> inalámbricos con micrófono", highlights["body"])
+
+	// Reindexar reemplaza los términos viejos; Remove los saca.
+	ix.Put("b", "Cargador", "Cable USB-C")
+	ix.Remove("c")
+
+	hits = ix.Search("auriculares")
+	assert.Empty(t, hits)
+	assert.Empty(t, ix.Search("calabaza"))
+	assert.Equal(t, 2, ix.Len())
+
+	require.Len(t, ix.Search("cables"), 1)
+
+	t.Log("✅ Results were ranked and kept up to date")
+}
+
+func TestSearch_SnippetIsEscapedAndTrimmed(t *testing.T) {
+	t.Log("🔍 TEST: snippets escape HTML and cut long texts around the match")
+
+	snippet, ok := search.Snippet(`Para "celular" <i>nuevo</i>`, search.Terms("celulares"))
+	require.True(t, ok)
+	assert.Equal(t, `Para &#34;<em>celular</em>&#34; &lt;i&gt;nuevo&lt;/i&gt;`, snippet)
+
+	long := "uno dos tres cuatro cinco seis siete ocho nueve diez once doce trece catorce quince dieciseis diecisiete dieciocho termo veinte " +
+		"veintiuno veintidos veintitres veinticuatro veinticinco veintiseis veintisiete veintiocho veintinueve treinta treintaiuno treintaidos"
+	snippet, ok = search.Snippet(long, search.Terms("termos"))
+	require.True(t, ok)
+	assert.Equal(t, "…dieciseis diecisiete dieciocho <em>termo</em> veinte veintiuno veintidos veintitres veinticuatro veinticinco veintiseis veintisiete veintiocho veintinueve treinta treintaiuno…", snippet)
+
+	_, ok = search.Snippet("sin coincidencias", search.Terms("mate"))
+	assert.False(t, ok)
+
+	t.Log("✅ Snippets were escaped and trimmed")
+}
+
+func TestSearch_ProductsEndpoint(t *testing.T) {
+	t.Log("🔍 TEST: GET /products/search finds words in name, description and details, ranked and highlighted")
+
+	e, st := newTestServer(t)
+	seedCatalog(t, e)
+
+	create := func(id, name, description, detail string) {
+		t.Helper()
+
+		body := fmt.Sprintf(`{"id": %q, "name": %q, "description": %q, "price": 10, "discount": 5, "installments": 1, "stock": 3,
+			"details": [{"name": "conectividad", "description": %q}],
+			"characteristics": {"name": "General", "details": [{"name": "garantía", "description": "un año"}]},
+			"categoryId": "cat-1", "sellerId": "sel-1"}`, id, name, description, detail)
+
+		rec := doRequest(e, http.MethodPost, "/api/v1/products", body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	create("p1", "Auriculares Bluetooth X1", "Auriculares inalámbricos con cancelación de ruido", "Bluetooth 5.0")
+	create("p2", "Parlante portátil", "Sonido potente para exteriores", "Bluetooth")
+	create("p3", "Funda para celular", "Funda de silicona", "no aplica")
+
+	results := func(q string) service.SearchPage {
+		t.Helper()
+
+		rec := doRequest(e, http.MethodGet, "/api/v1/products/search?q="+url.QueryEscape(q), "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var page service.SearchPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page
+	}
+
+	ids := func(page service.SearchPage) []string {
+		out := []string{}
+		for _, item := range page.Items {
+			out = append(out, item.Product.ID)
+		}
+		return out
+	}
+
+	page := results("auricular bluetooth")
+	assert.Equal(t, []string{"p1", "p2"}, ids(page))
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, "<em>Auriculares</em> <em>Bluetooth</em> X1", page.Items[0].Highlights["name"])
+	assert.Equal(t, "conectividad: <em>Bluetooth</em>. garantía: un año", page.Items[1].Highlights["details"])
+	assert.Equal(t, "Auriculares Bluetooth X1", page.Items[0].Product.Name)
+
+	// Sin acentos y en plural encuentra lo mismo; las palabras de los detalles también cuentan.
+	assert.Equal(t, []string{"p1"}, ids(results("CANCELACION")))
+	assert.Equal(t, []string{"p3"}, ids(results("celulares")))
+	assert.ElementsMatch(t, []string{"p1", "p2", "p3"}, ids(results("garantia")))
+
+	// El índice sigue a las escrituras.
+	rec := doRequest(e, http.MethodPatch, "/api/v1/products/p3", `{"description": "Funda con soporte bluetooth"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	rec = doRequest(e, http.MethodDelete, "/api/v1/products/p1", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	page = results("bluetooth")
+	assert.ElementsMatch(t, []string{"p2", "p3"}, ids(page))
+	assert.Empty(t, ids(results("silicona")))
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/p1/restore", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"p1"}, ids(results("ruido")))
+
+	// También las escrituras que no pasan por los handlers.
+	_, err := st.Products.Modify("p2", func(product *models.Product) error {
+		product.Name = "Parlante sumergible"
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p2"}, ids(results("sumergibles")))
+
+	// Paginado.
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/search?q=bluetooth&limit=1&offset=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, 3, page.Total)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/search?q=", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	t.Log("✅ Search ranked, highlighted and followed writes")
+}
+
+func TestSearch_FuzzyMatchesTypos(t *testing.T) {
+	t.Log("🔍 TEST: query terms missing from the index match terms a few edits away")
+
+	ix := search.NewIndex(search.Field{Name: "name", Weight: 1})
+
+	ix.Put("funda", "Funda para celular")
+	ix.Put("auris", "Auriculares inalámbricos")
+	ix.Put("carro", "Carro de compras")
+	ix.Put("cargo", "Pantalón cargo")
+
+	hits := ix.Search("celuar")
+	require.Len(t, hits, 1)
+	assert.Equal(t, "funda", hits[0].ID)
+	assert.Equal(t, "Funda para <em>celular</em>", ix.Highlights(hits[0])["name"])
+
+	hits = ix.Search("auriculres inalambricso")
+	require.Len(t, hits, 1)
+	assert.Equal(t, "auris", hits[0].ID)
+
+	// Una palabra que está en el índice no se expande a sus vecinas.
+	hits = ix.Search("carro")
+	require.Len(t, hits, 1)
+	assert.Equal(t, "carro", hits[0].ID)
+
+	// Una coincidencia aproximada puntúa menos que la exacta.
+	exact := ix.Search("celular")
+	require.Len(t, exact, 1)
+	assert.Greater(t, exact[0].Score, ix.Search("celuar")[0].Score)
+
+	// Las palabras cortas no toleran errores.
+	assert.Empty(t, ix.Search("pra"))
+	assert.Empty(t, ix.Search("xyzzyx"))
+
+	t.Log("✅ Typos were matched within the edit distance")
+}
+
+func TestSearch_SuggesterCompletesPrefixes(t *testing.T) {
+	t.Log("🔍 TEST: the suggester completes any word of a name, by score, without accents or duplicates")
+
+	s := search.NewSuggester()
+
+	s.Put("p:1", "product", "Auriculares Sony", 20)
+	s.Put("p:2", "product", "Auriculares genéricos", 60)
+	s.Put("p:3", "product", "Canción de cuna", 5)
+	s.Put("p:4", "product", "auriculares sony", 10)
+	s.Put("c:1", "category", "Audio", 100)
+
+	texts := func(suggestions []search.Suggestion) []string {
+		out := []string{}
+		for _, suggestion := range suggestions {
+			out = append(out, suggestion.Text)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"Audio", "Auriculares genéricos", "Auriculares Sony"}, texts(s.Suggest("au", 10)))
+	assert.Equal(t, "p:1", s.Suggest("auriculares so", 10)[0].Key)
+	assert.Equal(t, []string{"Auriculares Sony"}, texts(s.Suggest("SON", 10)))
+	assert.Equal(t, []string{"Canción de cuna"}, texts(s.Suggest("cancion", 10)))
+	assert.Equal(t, []string{"Audio"}, texts(s.Suggest("au", 1)))
+	assert.Empty(t, s.Suggest("mate", 10))
+	assert.Empty(t, s.Suggest("  ", 10))
+
+	s.Remove("c:1")
+	s.Put("p:2", "product", "Auriculares genéricos", 1)
+	assert.Equal(t, []string{"Auriculares Sony", "Auriculares genéricos"}, texts(s.Suggest("au", 10)))
+
+	t.Log("✅ Prefixes were completed and ranked")
+}
+
+func TestSearch_SuggestEndpoint(t *testing.T) {
+	t.Log("🔍 TEST: GET /products/suggest completes product and category names ranked by sales and rate")
+
+	e, st := newTestServer(t)
+	seedCatalog(t, e)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/categories", `{"id": "cat-2", "name": "Audio"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	create := func(id, name, category string, sales, rate int) {
+		t.Helper()
+
+		body := fmt.Sprintf(`{"id": %q, "name": %q, "price": 10, "discount": 5, "installments": 1, "stock": 3,
+			"sales_number": %d, "rate": %d, "details": [{"name": "color", "description": "negro"}],
+			"characteristics": {"name": "General", "details": []},
+			"categoryId": %q, "sellerId": "sel-1"}`, id, name, sales, rate, category)
+
+		rec := doRequest(e, http.MethodPost, "/api/v1/products", body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	create("p1", "Auriculares Sony", "cat-2", 10, 5)
+	create("p2", "Auriculares genéricos", "cat-2", 50, 1)
+	create("p3", "Audífonos baratos", "cat-2", 0, 0)
+	create("p4", "Funda para celular", "cat-1", 3, 4)
+
+	suggest := func(prefix string) []service.Suggestion {
+		t.Helper()
+
+		rec := doRequest(e, http.MethodGet, "/api/v1/products/suggest?prefix="+url.QueryEscape(prefix), "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var suggestions []service.Suggestion
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &suggestions))
+		return suggestions
+	}
+
+	assert.Equal(t, []service.Suggestion{
+		{Text: "Audio", Type: "category", ID: "cat-2"},
+		{Text: "Auriculares genéricos", Type: "product", ID: "p2"},
+		{Text: "Auriculares Sony", Type: "product", ID: "p1"},
+		{Text: "Audífonos baratos", Type: "product", ID: "p3"},
+	}, suggest("au"))
+
+	assert.Equal(t, []service.Suggestion{{Text: "Mates", Type: "category", ID: "cat-1"}}, suggest("mat"))
+
+	// Las ventas nuevas, los renombres y los borrados se ven en la próxima consulta.
+	_, err := st.Products.Modify("p1", func(product *models.Product) error {
+		product.SalesNumber = 500
+		return nil
+	})
+	require.NoError(t, err)
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/categories/cat-2", `{"name": "Sonido"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	rec = doRequest(e, http.MethodDelete, "/api/v1/products/p3", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	assert.Equal(t, []service.Suggestion{
+		{Text: "Auriculares Sony", Type: "product", ID: "p1"},
+		{Text: "Auriculares genéricos", Type: "product", ID: "p2"},
+	}, suggest("au"))
+	assert.Equal(t, "Sonido", suggest("so")[0].Text)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/suggest?prefix=au&limit=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"text": "Auriculares Sony", "type": "product", "id": "p1"}]`, rec.Body.String())
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/suggest", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// La búsqueda tolera errores de tipeo.
+	rec = doRequest(e, http.MethodGet, "/api/v1/products/search?q=celuar", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page service.SearchPage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "p4", page.Items[0].Product.ID)
+
+	t.Log("✅ Suggestions were ranked and followed writes")
+}
@@ -0,0 +1,236 @@
+package main_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftDelete_DeleteHidesAndRestoreBringsBack(t *testing.T) {
+	t.Log("🔍 TEST: DELETE sends the entity to the trash and POST /:id/restore brings it back")
+
+	e, st := newTestServer(t)
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"}, models.Category{ID: "c2", Name: "Termos"})
+
+	rec := doRequest(e, http.MethodDelete, "/api/v1/categories/c1", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1?include_deleted=true", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var deleted models.Category
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deleted))
+	require.NotNil(t, deleted.DeletedAt)
+	assert.Equal(t, "default", deleted.DeletedBy)
+
+	var list dao.Page[models.Category]
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list.Items, 1)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories?include_deleted=true", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list.Items, 2)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/trash", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "c1", list.Items[0].ID)
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/categories/c1/restore", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories/c1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/categories/c1/restore", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/categories/nope/restore", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(e, http.MethodGet, "/api/v1/categories?include_deleted=quizas", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	t.Log("✅ Deletes are recoverable from the trash")
+}
+
+func TestSoftDelete_TrashListingHasItsOwnETag(t *testing.T) {
+	t.Log("🔍 TEST: the trash and the listing don't share cached responses")
+
+	e, st := newTestServer(t)
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"})
+
+	list := doRequest(e, http.MethodGet, "/api/v1/categories", "")
+	trash := doRequest(e, http.MethodGet, "/api/v1/categories/trash", "")
+	assert.NotEqual(t, list.Header().Get("ETag"), trash.Header().Get("ETag"))
+
+	t.Log("✅ Each listing validates its own representation")
+}
+
+func TestSoftDelete_PurgeJobRemovesExpiredTombstones(t *testing.T) {
+	t.Log("🔍 TEST: the purge job hard-deletes tombstones older than the retention")
+
+	st, err := dal.OpenStorage(dal.StorageConfig{
+		Driver:         "json",
+		DataDir:        t.TempDir(),
+		TrashRetention: 50 * time.Millisecond,
+		PurgeInterval:  10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer st.Close()
+
+	seed(t, st.Categories, models.Category{ID: "c1", Name: "Mates"}, models.Category{ID: "c2", Name: "Termos"})
+	seed(t, st.Products, createTestProduct())
+
+	_, err = st.Categories.Delete("c1")
+	require.NoError(t, err)
+	_, err = st.Products.Delete("1")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, catErr := st.Categories.Find("c1", dao.IncludeDeleted)
+		_, prodErr := st.Products.Find("1", dao.IncludeDeleted)
+		return errors.Is(catErr, dao.ErrNotFound) && errors.Is(prodErr, dao.ErrNotFound)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	_, err = st.Categories.GetByID("c2")
+	assert.NoError(t, err, "active entities are never purged")
+
+	assert.NoError(t, st.Close())
+
+	t.Log("✅ Expired tombstones are gone")
+}
+
+func TestSoftDelete_PurgeRemovesHistory(t *testing.T) {
+	t.Log("🔍 TEST: purging a tombstone also deletes its archived versions")
+
+	historyOf := func(t *testing.T, path string) func() string {
+		return func() string {
+			raw, err := os.ReadFile(path)
+			if errors.Is(err, os.ErrNotExist) {
+				return ""
+			}
+			require.NoError(t, err)
+			return string(raw)
+		}
+	}
+
+	backends := map[string]func(t *testing.T) (dao.CrudDAO[SuiteEntity], func() string){
+		"json": func(t *testing.T) (dao.CrudDAO[SuiteEntity], func() string) {
+			path := filepath.Join(t.TempDir(), "suite")
+			return &dal.CrudDAL[SuiteEntity]{Filename: path}, historyOf(t, path+".history.jsonl")
+		},
+		"journal": func(t *testing.T) (dao.CrudDAO[SuiteEntity], func() string) {
+			path := filepath.Join(t.TempDir(), "suite")
+			repo, err := dal.OpenJournalDAL[SuiteEntity](path, dal.JournalOptions{})
+			require.NoError(t, err)
+			t.Cleanup(func() { repo.Close() })
+			return repo, historyOf(t, path+".history.jsonl")
+		},
+		"sqlite": func(t *testing.T) (dao.CrudDAO[SuiteEntity], func() string) {
+			db, err := dal.OpenSQLite(filepath.Join(t.TempDir(), "suite.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+
+			repo, err := dal.NewSQLiteDAL[SuiteEntity](db, "suite")
+			require.NoError(t, err)
+
+			return repo, func() string {
+				rows, err := db.Query("SELECT data FROM suite_history ORDER BY seq")
+				require.NoError(t, err)
+				defer rows.Close()
+
+				var out strings.Builder
+				for rows.Next() {
+					var data string
+					require.NoError(t, rows.Scan(&data))
+					out.WriteString(data + "\n")
+				}
+				return out.String()
+			}
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			repo, history := open(t)
+
+			for _, id := range []string{"1", "2"} {
+				_, err := repo.Create(&SuiteEntity{ID: id, Name: "Mate " + id})
+				require.NoError(t, err)
+				_, err = repo.Update(&SuiteEntity{Name: "Termo " + id}, id)
+				require.NoError(t, err)
+			}
+
+			// Se leen las revisiones antes del purge, así el historial ya
+			// tiene su índice armado.
+			revisions, err := repo.Revisions("2")
+			require.NoError(t, err)
+			require.Len(t, revisions, 2)
+
+			_, err = repo.Delete("1")
+			require.NoError(t, err)
+			assert.Contains(t, history(), `"Mate 1"`)
+
+			n, err := repo.Purge(time.Now().Add(time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+
+			assert.NotContains(t, history(), `"Mate 1"`)
+			assert.NotContains(t, history(), `"Termo 1"`)
+			assert.Contains(t, history(), `"Mate 2"`, "other entities keep their history")
+
+			// Lo que se archiva después del purge se sigue encontrando.
+			_, err = repo.Update(&SuiteEntity{Name: "Yerba 2"}, "2")
+			require.NoError(t, err)
+
+			revisions, err = repo.Revisions("2")
+			require.NoError(t, err)
+			require.Len(t, revisions, 3)
+			assert.Equal(t, "Mate 2", revisions[0].Entity.Name)
+			assert.Equal(t, "Termo 2", revisions[1].Entity.Name)
+		})
+	}
+
+	t.Log("✅ Purged entities left no history behind")
+}
+
+func TestStorageConfigFromEnv_TrashRetention(t *testing.T) {
+	t.Log("🔍 TEST: TRASH_RETENTION and PURGE_INTERVAL configure the purge job")
+
+	t.Setenv("TRASH_RETENTION", "")
+	t.Setenv("PURGE_INTERVAL", "")
+	cfg := dal.StorageConfigFromEnv()
+	assert.Equal(t, dal.DefaultTrashRetention, cfg.TrashRetention)
+	assert.Equal(t, dal.DefaultPurgeInterval, cfg.PurgeInterval)
+
+	t.Setenv("TRASH_RETENTION", "72h")
+	t.Setenv("PURGE_INTERVAL", "5m")
+	cfg = dal.StorageConfigFromEnv()
+	assert.Equal(t, 72*time.Hour, cfg.TrashRetention)
+	assert.Equal(t, 5*time.Minute, cfg.PurgeInterval)
+
+	t.Setenv("TRASH_RETENTION", "0")
+	assert.Zero(t, dal.StorageConfigFromEnv().TrashRetention, "0 disables the purge")
+
+	t.Log("✅ Retention is configurable")
+}
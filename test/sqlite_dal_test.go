@@ -0,0 +1,257 @@
+package main_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/repo/datasource/dao"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTempSQLiteCategoryDAL(t *testing.T) *dal.SQLiteDAL[models.Category] {
+	t.Log("🧪 Setting up a temporary SQLite database for Category tests")
+
+	db, err := dal.OpenSQLite(filepath.Join(t.TempDir(), "catalog.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	repo, err := dal.NewSQLiteDAL[models.Category](db, "categories")
+	assert.NoError(t, err)
+
+	return repo
+}
+
+func TestSQLiteDAL_CreateAndGetByID(t *testing.T) {
+	t.Log("🔍 TEST: Ensures Create() stores a row that GetByID() can read back")
+
+	repo := newTempSQLiteCategoryDAL(t)
+
+	created, err := repo.Create(&models.Category{Name: "Electrónica"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+
+	cat, err := repo.GetByID(created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Electrónica", cat.Name)
+
+	t.Log("✅ Category stored and read back from SQLite")
+}
+
+func TestSQLiteDAL_GetByID_NotFound(t *testing.T) {
+	t.Log("🔍 TEST: Ensures GetByID() returns an error when the ID does not exist")
+
+	repo := newTempSQLiteCategoryDAL(t)
+
+	_, err := repo.GetByID("no-existe")
+	assert.Error(t, err)
+
+	t.Log("✅ Correctly returned error for unknown ID")
+}
+
+func TestSQLiteDAL_GetAll_FilterIsCaseInsensitive(t *testing.T) {
+	t.Log("🔍 TEST: Ensures GetAll() filters by name ignoring case, including accents")
+
+	repo := newTempSQLiteCategoryDAL(t)
+
+	repo.Create(&models.Category{ID: "1", Name: "ELECTRÓNICA"})
+	repo.Create(&models.Category{ID: "2", Name: "Hogar"})
+	repo.Create(&models.Category{ID: "3", Name: "Electrodomésticos"})
+
+	all, err := repo.GetAll("electró", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, "1", all[0].ID)
+
+	all, err = repo.GetAll("ELECTR", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	t.Log("✅ Filter matched the same rows as the JSON store would")
+}
+
+func TestSQLiteDAL_GetAll_PaginationKeepsInsertionOrder(t *testing.T) {
+	t.Log("🔍 TEST: Ensures limit/offset follow insertion order and defaults apply")
+
+	repo := newTempSQLiteCategoryDAL(t)
+
+	for i := 1; i <= 12; i++ {
+		repo.Create(&models.Category{ID: fmt.Sprintf("%d", i), Name: fmt.Sprintf("Cat %d", i)})
+	}
+
+	page, err := repo.GetAll("", 3, 4)
+	assert.NoError(t, err)
+	assert.Len(t, page, 3)
+	assert.Equal(t, "5", page[0].ID)
+	assert.Equal(t, "7", page[2].ID)
+
+	page, err = repo.GetAll("", -1, -5)
+	assert.NoError(t, err)
+	assert.Len(t, page, 10)
+	assert.Equal(t, "1", page[0].ID)
+
+	page, err = repo.GetAll("", 5, 50)
+	assert.NoError(t, err)
+	assert.Len(t, page, 0)
+
+	t.Log("✅ Pagination behaved like the JSON store")
+}
+
+func TestSQLiteDAL_UpdateAndDelete(t *testing.T) {
+	t.Log("🔍 TEST: Ensures Update() merges fields and Delete() removes the row")
+
+	repo := newTempSQLiteCategoryDAL(t)
+
+	repo.Create(&models.Category{ID: "2", Name: "Deportes"})
+
+	updated, err := repo.Update(&models.Category{Name: "Camping"}, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, "Camping", updated.Name)
+
+	found, _ := repo.GetAll("camp", 10, 0)
+	assert.Len(t, found, 1)
+
+	_, err = repo.Update(&models.Category{Name: "X"}, "nope")
+	assert.Error(t, err)
+
+	ok, err := repo.Delete("2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = repo.Delete("2")
+	assert.Error(t, err)
+
+	t.Log("✅ Update and Delete worked against SQLite")
+}
+
+func TestSQLiteDAL_CreatesIndexes(t *testing.T) {
+	t.Log("🔍 TEST: Ensures the table has indexes on id and name")
+
+	path := filepath.Join(t.TempDir(), "catalog.db")
+
+	db, err := dal.OpenSQLite(path)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = dal.NewSQLiteDAL[models.Product](db, "products")
+	assert.NoError(t, err)
+
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'products'")
+	assert.NoError(t, err)
+	defer rows.Close()
+
+	indexes := []string{}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		indexes = append(indexes, name)
+	}
+
+	assert.Contains(t, indexes, "products_id_idx")
+	assert.Contains(t, indexes, "products_name_idx")
+
+	t.Log("✅ Indexes created")
+}
+
+func TestSQLiteDAL_NameSearchUsesTrigramIndex(t *testing.T) {
+	t.Log("🔍 TEST: Ensures ?q= substring search goes through the FTS5 trigram table and follows every write")
+
+	path := filepath.Join(t.TempDir(), "catalog.db")
+
+	db, err := dal.OpenSQLite(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	repo, err := dal.NewSQLiteDAL[models.Category](db, "categories")
+	require.NoError(t, err)
+
+	names := func(q string) []string {
+		t.Helper()
+
+		all, err := repo.GetAll(q, 10, 0)
+		require.NoError(t, err)
+
+		out := []string{}
+		for _, c := range all {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+
+	repo.Create(&models.Category{ID: "1", Name: "Mates y Bombillas"})
+	repo.Create(&models.Category{ID: "2", Name: `Termo "Stanley"`})
+	repo.Create(&models.Category{ID: "3", Name: "Yerbas"})
+
+	// El índice trigram tiene las filas.
+	var indexed int
+	require.NoError(t, db.QueryRow(`SELECT count(*) FROM categories_name_fts WHERE categories_name_fts MATCH '"bombi"'`).Scan(&indexed))
+	assert.Equal(t, 1, indexed)
+
+	assert.Equal(t, []string{"Mates y Bombillas"}, names("S Y BOMB"))
+	assert.Equal(t, []string{`Termo "Stanley"`}, names(`"stan`))
+	assert.Equal(t, []string{"Mates y Bombillas", "Yerbas"}, names("as"), "short queries still match substrings")
+	assert.Empty(t, names("bombillas y"))
+
+	// Renombres y borrados se reflejan en el índice.
+	_, err = repo.Update(&models.Category{Name: "Yerba mate"}, "3")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Yerba mate"}, names("ba ma"))
+	assert.Empty(t, names("yerbas"))
+
+	_, err = repo.Delete("1")
+	require.NoError(t, err)
+	assert.Empty(t, names("bombi"))
+
+	t.Log("✅ Name search was answered by the trigram index")
+}
+
+func TestSQLiteDAL_MigratesUpdatedAtColumn(t *testing.T) {
+	t.Log("🔍 TEST: Ensures tables created before updated_at get the column filled from the stored JSON")
+
+	db, err := dal.OpenSQLite(filepath.Join(t.TempDir(), "catalog.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// Tabla con el esquema anterior, con una fila que ya tiene datos de control
+	_, err = db.Exec(`
+		CREATE TABLE categories (
+			seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+			id          TEXT NOT NULL,
+			name_search TEXT,
+			data        TEXT NOT NULL
+		);
+		INSERT INTO categories (id, name_search, data) VALUES
+			('old', 'vieja', '{"id":"old","name":"Vieja","version":1,"updated_at":"2020-01-01T00:00:00Z"}'),
+			('new', 'nueva', '{"id":"new","name":"Nueva","version":1,"updated_at":"2030-01-01T00:00:00Z"}');
+	`)
+	require.NoError(t, err)
+
+	repo, err := dal.NewSQLiteDAL[models.Category](db, "categories")
+	require.NoError(t, err)
+
+	items, err := repo.List(dao.ListQuery{UpdatedSince: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "new", items[0].ID)
+
+	// Las filas que ya estaban entran al índice del nombre.
+	found, err := repo.GetAll("vieja", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "old", found[0].ID)
+
+	// Abrir de nuevo no vuelve a migrar
+	_, err = dal.NewSQLiteDAL[models.Category](db, "categories")
+	assert.NoError(t, err)
+
+	found, err = repo.GetAll("nuev", 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	t.Log("✅ Existing rows are found by updated_since after the migration")
+}
@@ -0,0 +1,95 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/rest"
+	"project/internal/item_detail/service"
+	models "project/pkg"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callCategoryHandler arma el contexto de echo y llama al handler de categorías.
+func callCategoryHandler(
+	t *testing.T,
+	repo dao.CrudDAO[models.Category],
+	handler func(h *rest.CrudHandler[models.Category], c echo.Context) error,
+	method string,
+	id string,
+	body string,
+) *httptest.ResponseRecorder {
+	e := echo.New()
+
+	req := httptest.NewRequest(method, "/categories/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+
+	h := rest.NewCrudHandler(service.NewCrudService(repo))
+	require.NoError(t, handler(h, c))
+
+	return rec
+}
+
+func TestStorageErrors_StatusCodes(t *testing.T) {
+	t.Log("🔍 TEST: Storage error categories map to 404/409/422")
+
+	t.Parallel()
+
+	repo := dal.NewMemoryDAL[models.Category]()
+	seed(t, repo, models.Category{ID: "c1", Name: "Mates"})
+
+	update := (*rest.CrudHandler[models.Category]).UpdateEntity
+	create := (*rest.CrudHandler[models.Category]).CreateEntity
+	remove := (*rest.CrudHandler[models.Category]).DeleteEntity
+
+	rec := callCategoryHandler(t, repo, update, "PATCH", "no-existe", `{"name":"X"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = callCategoryHandler(t, repo, update, "PATCH", "c1", `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = callCategoryHandler(t, repo, create, "POST", "", `{"id":"c1","name":"Otra"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = callCategoryHandler(t, repo, remove, "DELETE", "no-existe", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	t.Log("✅ Each category answered with its status code")
+}
+
+func TestStorageErrors_UnreadableFileIs503(t *testing.T) {
+	t.Log("🔍 TEST: An unreadable JSON file answers 503, not 404")
+
+	filename := filepath.Join(t.TempDir(), "Category")
+	require.NoError(t, os.WriteFile(filename+".json", []byte(`[{"id":"c1",`), 0644))
+
+	repo := &dal.CrudDAL[models.Category]{Filename: filename}
+
+	_, err := repo.GetByID("c1")
+	assert.ErrorIs(t, err, dao.ErrStorageUnavailable)
+
+	get := (*rest.CrudHandler[models.Category]).GetEntityByID
+	list := (*rest.CrudHandler[models.Category]).GetAllEntities
+
+	rec := callCategoryHandler(t, repo, get, "GET", "c1", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = callCategoryHandler(t, repo, list, "GET", "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	t.Log("✅ Storage failures reported as 503")
+}
@@ -0,0 +1,135 @@
+package main_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenStorage_JSON_UsesDataDir(t *testing.T) {
+	t.Log("🔍 TEST: Ensures the json driver writes its files inside DATA_DIR")
+
+	dir := filepath.Join(t.TempDir(), "catalog")
+
+	st, err := dal.OpenStorage(dal.StorageConfig{Driver: "json", DataDir: dir})
+	assert.NoError(t, err)
+
+	_, err = st.Categories.Create(&models.Category{ID: "1", Name: "Hogar"})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "Category.json"))
+	assert.NoError(t, err)
+
+	t.Log("✅ Category.json created inside the data directory")
+}
+
+func TestOpenStorage_SQLite_UsesDataDir(t *testing.T) {
+	t.Log("🔍 TEST: Ensures the sqlite driver creates its database inside DATA_DIR")
+
+	dir := t.TempDir()
+
+	st, err := dal.OpenStorage(dal.StorageConfig{Driver: "sqlite", DataDir: dir})
+	assert.NoError(t, err)
+	defer st.Close()
+
+	_, err = st.Sellers.Create(&models.Seller{ID: "1", Name: "Tienda", Address: "Calle 1"})
+	assert.NoError(t, err)
+
+	seller, err := st.Sellers.GetByID("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Tienda", seller.Name)
+
+	_, err = os.Stat(filepath.Join(dir, "catalog.db"))
+	assert.NoError(t, err)
+
+	t.Log("✅ catalog.db created inside the data directory")
+}
+
+func TestOpenStorage_UnknownDriver(t *testing.T) {
+	t.Log("🔍 TEST: Ensures an unknown driver fails listing the available ones")
+
+	_, err := dal.OpenStorage(dal.StorageConfig{Driver: "mongo", DataDir: t.TempDir()})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"mongo"`)
+	assert.Contains(t, err.Error(), "json")
+
+	t.Log("✅ Unknown driver rejected with a clear error")
+}
+
+func TestOpenStorage_DataDirNotWritable(t *testing.T) {
+	t.Log("🔍 TEST: Ensures startup fails fast when DATA_DIR can't be written")
+
+	// Un archivo en lugar de un directorio nunca es escribible como directorio
+	file := filepath.Join(t.TempDir(), "not-a-dir")
+	assert.NoError(t, os.WriteFile(file, []byte("x"), 0644))
+
+	_, err := dal.OpenStorage(dal.StorageConfig{Driver: "json", DataDir: filepath.Join(file, "data")})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "data directory")
+
+	t.Log("✅ Non-writable data directory rejected at startup")
+}
+
+func TestStorageConfigFromEnv_Defaults(t *testing.T) {
+	t.Log("🔍 TEST: Ensures STORAGE_DRIVER and DATA_DIR have sane defaults")
+
+	t.Setenv("STORAGE_DRIVER", "")
+	t.Setenv("DATA_DIR", "")
+
+	cfg := dal.StorageConfigFromEnv()
+	wd, _ := os.Getwd()
+
+	assert.Equal(t, "json", cfg.Driver)
+	assert.Equal(t, wd, cfg.DataDir)
+
+	t.Setenv("STORAGE_DRIVER", "sqlite")
+	t.Setenv("DATA_DIR", "relative/dir")
+
+	cfg = dal.StorageConfigFromEnv()
+
+	assert.Equal(t, "sqlite", cfg.Driver)
+	assert.True(t, filepath.IsAbs(cfg.DataDir))
+
+	t.Log("✅ Defaults applied and DATA_DIR resolved to an absolute path")
+}
+
+func TestOpenStorage_Journal_ReopensWithData(t *testing.T) {
+	t.Log("🔍 TEST: Ensures the journal driver keeps data across restarts")
+
+	dir := t.TempDir()
+	t.Setenv("JOURNAL_COMPACT_EVERY", "2")
+
+	cfg := dal.StorageConfigFromEnv()
+	cfg.Driver = "journal"
+	cfg.DataDir = dir
+	assert.Equal(t, 2, cfg.JournalCompactEvery)
+
+	st, err := dal.OpenStorage(cfg)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = st.Images.Create(&models.Image{ID: fmt.Sprintf("img-%d", i), Name: "Foto", URL: fmt.Sprintf("http://img/%d", i)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, st.Close())
+
+	_, err = os.Stat(filepath.Join(dir, "Image.journal"))
+	assert.NoError(t, err)
+
+	st, err = dal.OpenStorage(cfg)
+	require.NoError(t, err)
+	defer st.Close()
+
+	all, err := st.Images.GetAll("", 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 5)
+
+	t.Log("✅ Journal storage reopened with every image")
+}
@@ -0,0 +1,504 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project/internal/item_detail/repo/datasource/dal"
+	"project/internal/item_detail/repo/datasource/dao"
+	"project/internal/item_detail/service"
+	models "project/pkg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var transactionDrivers = []string{"memory", "json", "sqlite", "journal"}
+
+func openDriver(t *testing.T, driver string, dir string) *dal.Storage {
+	t.Helper()
+
+	st, err := dal.OpenStorage(dal.StorageConfig{Driver: driver, DataDir: dir})
+	require.NoError(t, err)
+
+	return st
+}
+
+func TestTransact_CommitsEveryCollectionTogether(t *testing.T) {
+	t.Log("🔍 TEST: writes to several collections inside Transact are all visible after commit")
+
+	for _, driver := range transactionDrivers {
+		t.Run(driver, func(t *testing.T) {
+			st := openDriver(t, driver, t.TempDir())
+			defer st.Close()
+
+			err := st.Transact(func(uow dao.UnitOfWork) error {
+				if _, err := uow.Images().Create(&models.Image{ID: "img-1", Name: "Frente", URL: "http://img/1"}); err != nil {
+					return err
+				}
+
+				// Dentro de la transacción se ven sus propias escrituras.
+				image, err := uow.Images().GetByID("img-1")
+				if err != nil {
+					return err
+				}
+
+				_, err = uow.Products().Create(&models.Product{ID: "p1", Name: "Mate", Images: []string{image.ID}})
+				return err
+			})
+			require.NoError(t, err)
+
+			product, err := st.Products.GetByID("p1")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"img-1"}, product.Images)
+
+			_, err = st.Images.GetByID("img-1")
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Log("✅ Every driver committed both collections")
+}
+
+func TestTransact_ErrorDiscardsEveryWrite(t *testing.T) {
+	t.Log("🔍 TEST: when fn fails nothing written inside the transaction is kept")
+
+	boom := errors.New("boom")
+
+	for _, driver := range transactionDrivers {
+		t.Run(driver, func(t *testing.T) {
+			st := openDriver(t, driver, t.TempDir())
+			defer st.Close()
+
+			_, err := st.Sellers.Create(&models.Seller{ID: "sel-1", Name: "Don Mate", Address: "Calle 1"})
+			require.NoError(t, err)
+
+			err = st.Transact(func(uow dao.UnitOfWork) error {
+				if _, err := uow.Images().Create(&models.Image{ID: "img-1", Name: "Frente", URL: "http://img/1"}); err != nil {
+					return err
+				}
+				if _, err := uow.Sellers().Delete("sel-1"); err != nil {
+					return err
+				}
+				if _, err := uow.Products().Create(&models.Product{ID: "p1", Name: "Mate"}); err != nil {
+					return err
+				}
+
+				return boom
+			})
+			assert.ErrorIs(t, err, boom)
+
+			_, err = st.Images.GetByID("img-1")
+			assert.ErrorIs(t, err, dao.ErrNotFound)
+			_, err = st.Products.GetByID("p1")
+			assert.ErrorIs(t, err, dao.ErrNotFound)
+			_, err = st.Sellers.GetByID("sel-1")
+			assert.NoError(t, err)
+
+			// La colección sigue aceptando escrituras sueltas.
+			_, err = st.Images.Create(&models.Image{ID: "img-2", Name: "Dorso", URL: "http://img/2"})
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Log("✅ Every driver rolled back the whole transaction")
+}
+
+func TestTransact_FailedOperationDoesNotAbortTransaction(t *testing.T) {
+	t.Log("🔍 TEST: an operation that fails inside the transaction is undone on its own")
+
+	for _, driver := range transactionDrivers {
+		t.Run(driver, func(t *testing.T) {
+			st := openDriver(t, driver, t.TempDir())
+			defer st.Close()
+
+			stock := 1
+			_, err := st.Products.Create(&models.Product{ID: "p1", Name: "Mate", Stock: &stock})
+			require.NoError(t, err)
+
+			err = st.Transact(func(uow dao.UnitOfWork) error {
+				_, err := uow.Products().Modify("p1", func(product *models.Product) error {
+					product.Stock = nil
+					return dao.Errorf(dao.ErrInvalidUpdate, "rejected")
+				})
+				if !errors.Is(err, dao.ErrInvalidUpdate) {
+					return errors.New("expected the modify to fail")
+				}
+
+				_, err = uow.Products().Create(&models.Product{ID: "p2", Name: "Bombilla"})
+				return err
+			})
+			require.NoError(t, err)
+
+			product, err := st.Products.GetByID("p1")
+			require.NoError(t, err)
+			require.NotNil(t, product.Stock)
+			assert.Equal(t, 1, *product.Stock)
+
+			_, err = st.Products.GetByID("p2")
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Log("✅ The rejected modify was discarded and the rest committed")
+}
+
+func TestTransactIn_OnlyUsesItsScope(t *testing.T) {
+	t.Log("🔍 TEST: TransactIn writes only the collections it declared and reads only the ones it listed")
+
+	for _, driver := range transactionDrivers {
+		t.Run(driver, func(t *testing.T) {
+			st := openDriver(t, driver, t.TempDir())
+			defer st.Close()
+
+			_, err := st.Categories.Create(&models.Category{ID: "cat-1", Name: "Mates"})
+			require.NoError(t, err)
+
+			scope := dao.Scope{Writes: []dao.Collection{dao.ProductsCollection}, Reads: []dao.Collection{dao.CategoriesCollection}}
+			err = st.TransactIn(scope, func(uow dao.UnitOfWork) error {
+				_, err := uow.Categories().GetByID("cat-1")
+				assert.NoError(t, err)
+
+				_, err = uow.Categories().Create(&models.Category{ID: "cat-2", Name: "Termos"})
+				assert.ErrorIs(t, err, dao.ErrStorageUnavailable, "categories are read-only")
+
+				_, err = uow.Sellers().GetByID("sel-1")
+				assert.ErrorIs(t, err, dao.ErrStorageUnavailable, "sellers aren't in the scope")
+
+				_, err = uow.Products().Create(&models.Product{ID: "p1", Name: "Mate", CategoryId: "cat-1"})
+				return err
+			})
+			require.NoError(t, err)
+
+			_, err = st.Products.GetByID("p1")
+			assert.NoError(t, err)
+			_, err = st.Categories.GetByID("cat-2")
+			assert.ErrorIs(t, err, dao.ErrNotFound)
+		})
+	}
+
+	t.Log("✅ Every driver enforced the scope")
+}
+
+func TestTransactIn_DisjointScopesDontBlock(t *testing.T) {
+	t.Log("🔍 TEST: a transaction on products commits while another one holds categories")
+
+	// SQLite serializa las escrituras de toda la base: sólo los drivers con
+	// locks por colección.
+	for _, driver := range []string{"memory", "json", "journal"} {
+		t.Run(driver, func(t *testing.T) {
+			st := openDriver(t, driver, t.TempDir())
+			defer st.Close()
+
+			held, release := make(chan struct{}), make(chan struct{})
+			done := make(chan error, 1)
+			go func() {
+				done <- st.TransactIn(dao.Scope{Writes: []dao.Collection{dao.CategoriesCollection}}, func(uow dao.UnitOfWork) error {
+					close(held)
+					<-release
+					_, err := uow.Categories().Create(&models.Category{ID: "cat-1", Name: "Mates"})
+					return err
+				})
+			}()
+			<-held
+
+			err := st.TransactIn(dao.Scope{Writes: []dao.Collection{dao.ProductsCollection}}, func(uow dao.UnitOfWork) error {
+				_, err := uow.Products().Create(&models.Product{ID: "p1", Name: "Mate"})
+				return err
+			})
+			require.NoError(t, err)
+
+			close(release)
+			require.NoError(t, <-done)
+
+			_, err = st.Categories.GetByID("cat-1")
+			assert.NoError(t, err)
+			_, err = st.Products.GetByID("p1")
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Log("✅ Transactions over different collections ran side by side")
+}
+
+func TestTransact_JSON_RollsBackInterruptedCommit(t *testing.T) {
+	t.Log("🔍 TEST: a leftover undo log restores the files of a half-written JSON transaction")
+
+	dir := t.TempDir()
+
+	st := openDriver(t, "json", dir)
+	_, err := st.Products.Create(&models.Product{ID: "p1", Name: "Mate"})
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	before, err := os.ReadFile(filepath.Join(dir, "Product.json"))
+	require.NoError(t, err)
+
+	// El proceso murió después de reemplazar Product.json y antes de borrar el log.
+	undo, err := json.Marshal([]map[string]json.RawMessage{
+		{"file": json.RawMessage(`"Product"`), "data": json.RawMessage(before)},
+		{"file": json.RawMessage(`"Image"`), "data": json.RawMessage(`[]`)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transaction.undo.json"), undo, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Product.json"), []byte(`[{"id":"p2","name":"Bombilla"}]`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Image.json"), []byte(`[{"id":"img-1","name":"Frente","url":"http://img"}]`), 0644))
+
+	st = openDriver(t, "json", dir)
+	defer st.Close()
+
+	_, err = st.Products.GetByID("p1")
+	assert.NoError(t, err)
+	_, err = st.Products.GetByID("p2")
+	assert.ErrorIs(t, err, dao.ErrNotFound)
+	_, err = st.Images.GetByID("img-1")
+	assert.ErrorIs(t, err, dao.ErrNotFound)
+
+	_, err = os.Stat(filepath.Join(dir, "transaction.undo.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	t.Log("✅ The interrupted transaction was rolled back on open")
+}
+
+func TestTransact_JSON_AbortedCommitRestoresHistory(t *testing.T) {
+	t.Log("🔍 TEST: a JSON transaction that fails to commit leaves the history files as they were")
+
+	dir := t.TempDir()
+	st := openDriver(t, "json", dir)
+
+	for _, id := range []string{"p1", "p2"} {
+		_, err := st.Products.Create(&models.Product{ID: id, Name: "Mate"})
+		require.NoError(t, err)
+	}
+	_, err := st.Products.Delete("p2")
+	require.NoError(t, err)
+
+	history := filepath.Join(dir, "Product.history.jsonl")
+	before, err := os.ReadFile(history)
+	require.NoError(t, err)
+
+	err = st.Transact(func(uow dao.UnitOfWork) error {
+		// Agrega una versión de p1 y purga p2 del historial
+		if _, err := uow.Products().Delete("p1"); err != nil {
+			return err
+		}
+		if _, err := uow.Products().Purge(time.Now().Add(time.Hour)); err != nil {
+			return err
+		}
+
+		if _, err := uow.Images().Create(&models.Image{ID: "img-1", Name: "Frente", URL: "http://img/1"}); err != nil {
+			return err
+		}
+
+		// Image.json no se va a poder reemplazar: la confirmación falla
+		// después de escribir Product.json.
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "Image.json", "busy"), 0755))
+		return nil
+	})
+	require.ErrorIs(t, err, dao.ErrStorageUnavailable)
+
+	after, err := os.ReadFile(history)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "the history was restored on abort")
+
+	// Image.json no se pudo restaurar: el log queda y se termina al abrir.
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "Image.json")))
+	require.NoError(t, st.Close())
+
+	st = openDriver(t, "json", dir)
+	defer st.Close()
+
+	after, err = os.ReadFile(history)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	revisions, err := st.Products.Revisions("p2")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 2, "p2 keeps its history")
+
+	_, err = st.Products.GetByID("p1")
+	assert.NoError(t, err)
+
+	leftovers, err := filepath.Glob(filepath.Join(dir, "transaction.undo*"))
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
+
+	t.Log("✅ The history files went back with the collections")
+}
+
+func TestTransact_Journal_CompletesPartialCommit(t *testing.T) {
+	t.Log("🔍 TEST: a journal transaction that reached only one journal is completed on open")
+
+	dir := t.TempDir()
+
+	st := openDriver(t, "journal", dir)
+	err := st.Transact(func(uow dao.UnitOfWork) error {
+		if _, err := uow.Products().Create(&models.Product{ID: "p1", Name: "Mate", Images: []string{"img-1"}}); err != nil {
+			return err
+		}
+
+		_, err := uow.Images().Create(&models.Image{ID: "img-1", Name: "Frente", URL: "http://img"})
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	// El proceso murió después de escribir el journal de productos y antes
+	// del de imágenes.
+	path := filepath.Join(dir, "Image.journal")
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.SplitAfter(raw, []byte("\n"))
+	require.NoError(t, os.WriteFile(path, bytes.Join(lines[:len(lines)-2], nil), 0644))
+
+	st = openDriver(t, "journal", dir)
+	_, err = st.Images.GetByID("img-1")
+	assert.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	// Lo completado quedó en el journal: un segundo arranque no lo repite.
+	st = openDriver(t, "journal", dir)
+	defer st.Close()
+
+	images, err := st.Images.GetAll("", 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, images, 1)
+
+	t.Log("✅ The missing journal entry was rebuilt from the committed one")
+}
+
+func TestProductService_CreateWithImages(t *testing.T) {
+	t.Log("🔍 TEST: POST /products/with-images saves the product and its images together")
+
+	e, st := newTestServer(t)
+	seedCatalog(t, e)
+
+	body := `{"product": ` + productBody("p1", "cat-1", "sel-1", "img-1") + `,
+		"images": [{"id": "img-2", "name": "Dorso", "url": "https://cdn.example.com/2.png"}]}`
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/products/with-images", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	product, err := st.Products.GetByID("p1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"img-1", "img-2"}, product.Images)
+
+	// Un producto que no se puede crear no deja imágenes huérfanas.
+	body = `{"product": ` + productBody("p1", "cat-1", "sel-1") + `,
+		"images": [{"id": "img-3", "name": "Lado", "url": "https://cdn.example.com/3.png"}]}`
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/with-images", body)
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+	body = `{"product": ` + productBody("p2", "cat-9", "sel-1") + `,
+		"images": [{"id": "img-4", "name": "Lado", "url": "https://cdn.example.com/4.png"}]}`
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/with-images", body)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	for _, id := range []string{"img-3", "img-4"} {
+		_, err = st.Images.GetByID(id)
+		assert.ErrorIs(t, err, dao.ErrNotFound, id)
+	}
+
+	t.Log("✅ Product and images were created together or not at all")
+}
+
+func TestProductService_CreateWithImages_KeepsCallerProduct(t *testing.T) {
+	t.Log("🔍 TEST: CreateWithImages doesn't add the image IDs to the caller's product")
+
+	st := openDriver(t, "memory", "")
+	defer st.Close()
+	seed(t, st.Categories, models.Category{ID: "cat-1", Name: "Mates"})
+	seed(t, st.Sellers, models.Seller{ID: "sel-1", Name: "Mates del Norte", Address: "Calle 1"})
+
+	svc := service.NewProductService(st.Products, st.Sellers, st.Categories, st.Images).WithTransactor(st)
+
+	stock := 3
+	product := &models.Product{
+		ID: "p1", Name: "Mate", Price: 10, Discount: 5, Installments: 1, Stock: &stock,
+		Details:    []models.ProductDetail{{Name: "material", Description: "calabaza"}},
+		CategoryId: "cat-9", SellerId: "sel-1", Images: []string{},
+	}
+	image := &models.Image{ID: "img-1", Name: "Frente", URL: "https://cdn.example.com/1.png"}
+
+	_, err := svc.CreateWithImages(product, []*models.Image{image})
+	require.ErrorIs(t, err, dao.ErrInvalidReference)
+	assert.Empty(t, product.Images, "the failed attempt left the product untouched")
+
+	// El mismo producto se puede reintentar sin arrastrar la imagen anterior
+	product.CategoryId = "cat-1"
+	created, err := svc.CreateWithImages(product, []*models.Image{image})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"img-1"}, created.Images)
+	assert.Empty(t, product.Images)
+
+	t.Log("✅ The caller's product kept its own images")
+}
+
+func TestProductService_AddImage_AlreadyLinked(t *testing.T) {
+	t.Log("🔍 TEST: adding an image the product already has doesn't link it twice")
+
+	e, st := newTestServer(t)
+	seedCatalog(t, e)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/products", productBody("p1", "cat-1", "sel-1", "img-1"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	before, err := st.Products.GetByID("p1")
+	require.NoError(t, err)
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/p1/images", `{"id":"img-1"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	product, err := st.Products.GetByID("p1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"img-1"}, product.Images)
+	assert.Equal(t, before.Version, product.Version, "nothing was written")
+
+	t.Log("✅ The image was linked only once")
+}
+
+func TestProductService_AddNewImageAndChangeSeller(t *testing.T) {
+	t.Log("🔍 TEST: adding a new image and re-pointing the seller are single operations")
+
+	e, st := newTestServer(t)
+	seedCatalog(t, e)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/products", productBody("p1", "cat-1", "sel-1"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/p1/images", `{"id":"img-2","name":"Dorso","url":"https://cdn.example.com/2.png"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	product, err := st.Products.GetByID("p1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"img-2"}, product.Images)
+
+	// Con el producto inexistente la imagen nueva tampoco se guarda.
+	rec = doRequest(e, http.MethodPost, "/api/v1/products/p9/images", `{"id":"img-3","name":"Lado","url":"https://cdn.example.com/3.png"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+	_, err = st.Images.GetByID("img-3")
+	assert.ErrorIs(t, err, dao.ErrNotFound)
+
+	rec = doRequest(e, http.MethodPost, "/api/v1/sellers", `{"id":"sel-2","name":"Mates del Sur","address":"Calle 2"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/products/p1/seller", `{"id":"sel-2"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = doRequest(e, http.MethodPatch, "/api/v1/products/p1/seller", `{"id":"sel-9"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	product, err = st.Products.GetByID("p1")
+	require.NoError(t, err)
+	assert.Equal(t, "sel-2", product.SellerId)
+
+	t.Log("✅ Image and seller changes were applied atomically")
+}
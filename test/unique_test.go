@@ -0,0 +1,49 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnique_DuplicatesAreConflicts(t *testing.T) {
+	t.Log("🔍 TEST: repeated category names (any case) and image URLs are a 409 with the conflicting ID")
+
+	e, _ := newTestServer(t)
+
+	rec := doRequest(e, http.MethodPost, "/api/v1/categories", `{"id":"cat-1","name":"Electrónica"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = doRequest(e, http.MethodPost, "/api/v1/categories", `{"id":"cat-2","name":"Hogar"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = doRequest(e, http.MethodPost, "/api/v1/images", `{"id":"img-1","name":"Frente","url":"https://cdn.example.com/1.png"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	tests := []struct {
+		method, path, body string
+		field, id          string
+	}{
+		{http.MethodPost, "/api/v1/categories", `{"name":"ELECTRÓNICA"}`, "name", "cat-1"},
+		{http.MethodPatch, "/api/v1/categories/cat-2", `{"name":"electrónica"}`, "name", "cat-1"},
+		{http.MethodPost, "/api/v1/images", `{"name":"Otra","url":"https://cdn.example.com/1.png"}`, "url", "img-1"},
+	}
+
+	for _, tt := range tests {
+		rec := doRequest(e, tt.method, tt.path, tt.body)
+		require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, tt.field, body["field"])
+		assert.Equal(t, tt.id, body["conflicting_id"])
+		assert.Contains(t, body["error"], "already used")
+	}
+
+	// Las URLs distinguen mayúsculas.
+	rec = doRequest(e, http.MethodPost, "/api/v1/images", `{"name":"Otra","url":"https://cdn.example.com/1.PNG"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	t.Log("✅ Duplicates were rejected naming the entity that holds the value")
+}
@@ -147,3 +147,128 @@ func TestUpdateJSON_AddNewField(t *testing.T) {
 
 	t.Log("✅ New field added successfully while preserving existing data")
 }
+
+func TestReadJSON_EmptyFile_ReturnsNil(t *testing.T) {
+	t.Log("🔍 TEST: Ensures ReadJSON treats an empty file as no data")
+
+	path := tempFilePath(t, "empty")
+
+	err := os.WriteFile(path+".json", []byte{}, 0644)
+	assert.NoError(t, err)
+
+	var dest []map[string]interface{}
+	err = utils.ReadJSON(path, &dest)
+
+	assert.NoError(t, err)
+	assert.Nil(t, dest)
+
+	t.Log("✅ Empty file correctly returned nil")
+}
+
+func TestReadJSON_InvalidJSON_IsCorrupt(t *testing.T) {
+	t.Log("🔍 TEST: Ensures decode errors can be detected with errors.Is(ErrCorruptJSON)")
+
+	path := tempFilePath(t, "truncated")
+
+	err := os.WriteFile(path+".json", []byte(`[{"id":"1","name":"Trunc`), 0644)
+	assert.NoError(t, err)
+
+	var dest []map[string]interface{}
+	err = utils.ReadJSON(path, &dest)
+
+	assert.ErrorIs(t, err, utils.ErrCorruptJSON)
+
+	t.Log("✅ Truncated file reported as corrupt")
+}
+
+func TestWriteJSON_ReplacesWithoutLeavingTempFiles(t *testing.T) {
+	t.Log("🔍 TEST: Ensures WriteJSON replaces the file and cleans up its temp file")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "atomic")
+
+	assert.NoError(t, utils.WriteJSON(path, []string{"a"}))
+	assert.NoError(t, utils.WriteJSON(path, []string{"b", "c"}))
+
+	var dest []string
+	assert.NoError(t, utils.ReadJSON(path, &dest))
+	assert.Equal(t, []string{"b", "c"}, dest)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "atomic.json", entries[0].Name())
+
+	t.Log("✅ File replaced and no temp files left behind")
+}
+
+func TestWriteJSON_EncodeErrorKeepsOriginal(t *testing.T) {
+	t.Log("🔍 TEST: Ensures a failed encode leaves the previous file intact")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keep")
+
+	assert.NoError(t, utils.WriteJSON(path, []string{"original"}))
+
+	// Los channels no se pueden codificar → el encoder falla a mitad de camino
+	err := utils.WriteJSON(path, map[string]interface{}{"bad": make(chan int)})
+	assert.Error(t, err)
+
+	var dest []string
+	assert.NoError(t, utils.ReadJSON(path, &dest))
+	assert.Equal(t, []string{"original"}, dest)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+
+	t.Log("✅ Original file preserved after encode failure")
+}
+
+func TestQuarantineJSON_MovesFileAside(t *testing.T) {
+	t.Log("🔍 TEST: Ensures QuarantineJSON renames the file with a .corrupt suffix")
+
+	path := tempFilePath(t, "bad")
+
+	err := os.WriteFile(path+".json", []byte("{invalid"), 0644)
+	assert.NoError(t, err)
+
+	target, err := utils.QuarantineJSON(path)
+	assert.NoError(t, err)
+	assert.Contains(t, target, "bad.json.corrupt-")
+
+	_, err = os.Stat(path + ".json")
+	assert.True(t, os.IsNotExist(err))
+
+	content, err := os.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "{invalid", string(content))
+
+	t.Log("✅ Corrupt file moved aside with its content intact")
+}
+
+func TestDiffFields_NestedAndSkipped(t *testing.T) {
+	t.Log("🔍 TEST: Ensures DiffFields walks nested objects and ignores skipped fields")
+
+	type inner struct {
+		Name string `json:"name"`
+	}
+	type entity struct {
+		Version int64    `json:"version"`
+		Price   float64  `json:"price"`
+		Tags    []string `json:"tags"`
+		Inner   inner    `json:"inner"`
+	}
+
+	changes, err := utils.DiffFields(
+		entity{Version: 1, Price: 10, Tags: []string{"a"}, Inner: inner{Name: "x"}},
+		entity{Version: 2, Price: 10, Tags: []string{"a", "b"}, Inner: inner{Name: "y"}},
+		"version",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []utils.FieldChange{
+		{Field: "inner.name", From: "x", To: "y"},
+		{Field: "tags", From: []any{"a"}, To: []any{"a", "b"}},
+	}, changes)
+
+	t.Log("✅ Only content changes are reported, with dotted paths")
+}